@@ -17,25 +17,57 @@ limitations under the License.
 package audit
 
 import (
+	"fmt"
 	"github.com/ExpediaGroup/flyte/json"
 	"time"
 )
 
 type Flow struct {
-	Name          string            `json:"name" bson:"name"`
-	UUID          string            `json:"uuid" bson:"uuid"`
-	CorrelationId string            `json:"correlationId" bson:"-"`
-	Steps         []Step            `json:"steps" bson:"steps,omitempty"`
-	Actions       map[string]Action `json:"actions" bson:"-"`
+	Name          string        `json:"name" bson:"name"`
+	UUID          string        `json:"uuid" bson:"uuid"`
+	CorrelationId string        `json:"correlationId" bson:"-"`
+	Parent        *ParentAction `json:"parent,omitempty" bson:"-"`
+	Execution     *Execution    `json:"execution,omitempty" bson:"-"`
+	Steps         []Step        `json:"steps" bson:"steps,omitempty"`
+	OnError       []Step        `json:"onError,omitempty" bson:"onError,omitempty"`
+	Finally       []Step        `json:"finally,omitempty" bson:"finally,omitempty"`
+	// template of the key the events of an execution are correlated by
+	CorrelationKey string `json:"correlationKey,omitempty" bson:"correlationKey,omitempty"`
+	// how many executions of the flow can be running at once
+	MaxConcurrentExecutions int `json:"maxConcurrentExecutions,omitempty" bson:"maxConcurrentExecutions,omitempty"`
+	// actions by step id, the repetitions of a step are under "<step id>#<repetition>" and the re-runs of a failed
+	// action under "<key of the failed action>@<id of the failed action>"
+	Actions map[string]Action `json:"actions" bson:"-"`
+	// actions created for the items of forEach steps, by step id
+	Iterations map[string][]Action `json:"iterations,omitempty" bson:"-"`
+}
+
+// status of the flow execution, QUEUED, RUNNING, SUCCEEDED, FAILED, CANCELLED or TIMED_OUT
+type Execution struct {
+	Status         string    `json:"status" bson:"status"`
+	CorrelationKey string    `json:"correlationKey,omitempty" bson:"correlationKey,omitempty"`
+	StartedAt      time.Time `json:"startedAt" bson:"startedAt"`
+	LastActivityAt time.Time `json:"lastActivityAt" bson:"lastActivityAt"`
+	TerminalStepId string    `json:"terminalStepId,omitempty" bson:"terminalStepId,omitempty"`
 }
 
 type Step struct {
 	Id        string            `json:"id" bson:"id"`
 	DependsOn []string          `json:"dependsOn,omitempty" bson:"dependsOn,omitempty"`
+	Join      string            `json:"join,omitempty" bson:"join,omitempty"`
 	Event     EventDef          `json:"event" bson:"event"`
 	Context   map[string]string `json:"context,omitempty" bson:"context,omitempty"`
 	Criteria  string            `json:"criteria,omitempty" bson:"criteria,omitempty"`
 	Command   Command           `json:"command" bson:"command"`
+
+	ForEach     string  `json:"forEach,omitempty" bson:"forEach,omitempty"`
+	ForEachJoin string  `json:"forEachJoin,omitempty" bson:"forEachJoin,omitempty"`
+	Repeat      *Repeat `json:"repeat,omitempty" bson:"repeat,omitempty"`
+}
+
+type Repeat struct {
+	Max   int    `json:"max,omitempty" bson:"max,omitempty"`
+	Until string `json:"until,omitempty" bson:"until,omitempty"`
 }
 
 type EventDef struct {
@@ -49,6 +81,23 @@ type Command struct {
 	PackName   string            `json:"packName" bson:"packName"`
 	PackLabels map[string]string `json:"packLabels,omitempty" bson:"packLabels,omitempty"`
 	Input      json.Json         `json:"input" bson:"input,omitempty"`
+	Timeout    *Timeout          `json:"timeout,omitempty" bson:"timeout,omitempty"`
+	Retry      *Retry            `json:"retry,omitempty" bson:"retry,omitempty"`
+	Priority   interface{}       `json:"priority,omitempty" bson:"priority,omitempty"`
+	// how many actions of the step can be in flight across the executions of the flow
+	MaxInFlight int `json:"maxInFlight,omitempty" bson:"maxInFlight,omitempty"`
+}
+
+type Timeout struct {
+	Take     string `json:"take,omitempty" bson:"take,omitempty"`
+	Complete string `json:"complete,omitempty" bson:"complete,omitempty"`
+}
+
+type Retry struct {
+	MaxAttempts int      `json:"maxAttempts" bson:"maxAttempts"`
+	Backoff     string   `json:"backoff,omitempty" bson:"backoff,omitempty"`
+	Delay       string   `json:"delay,omitempty" bson:"delay,omitempty"`
+	On          []string `json:"on,omitempty" bson:"on,omitempty"`
 }
 
 type Action struct {
@@ -59,17 +108,52 @@ type Action struct {
 	Input      json.Json         `json:"input,omitempty" bson:"input,omitempty"`
 	State      State             `json:"state" bson:"state"`
 	States     []State           `json:"states,omitempty" bson:"states"`
-
-	CorrelationId string `json:"correlationId" bson:"correlationId"`
-	FlowName      string `json:"flowName" bson:"flowName"`
-	FlowUUID      string `json:"flowUUID" bson:"flowUUID"`
-	StepId        string `json:"stepId" bson:"stepId"`
+	Timeout    *Timeout          `json:"timeout,omitempty" bson:"timeout,omitempty"`
+	ExpiresAt  *time.Time        `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	Retry      *Retry            `json:"retry,omitempty" bson:"retry,omitempty"`
+	Attempt    int               `json:"attempt,omitempty" bson:"attempt,omitempty"`
+	NotBefore  *time.Time        `json:"notBefore,omitempty" bson:"notBefore,omitempty"`
+	Priority   int               `json:"priority,omitempty" bson:"priority,omitempty"`
+
+	Cancellation *Cancellation `json:"cancellation,omitempty" bson:"cancellation,omitempty"`
+	ReplayOf     string        `json:"replayOf,omitempty" bson:"replayOf,omitempty"`
+	WakeAt       *time.Time    `json:"wakeAt,omitempty" bson:"wakeAt,omitempty"`
+
+	ChildCorrelationId string        `json:"childCorrelationId,omitempty" bson:"childCorrelationId,omitempty"`
+	Parent             *ParentAction `json:"parent,omitempty" bson:"parent,omitempty"`
+
+	ForEach   *ForEach   `json:"forEach,omitempty" bson:"forEach,omitempty"`
+	Iteration *Iteration `json:"iteration,omitempty" bson:"iteration,omitempty"`
+	// how many times the step had been executed before this action in the flow execution
+	Repetition int `json:"repetition,omitempty" bson:"repetition,omitempty"`
+	// set on the actions of onError and finally steps
+	Handler string `json:"handler,omitempty" bson:"handler,omitempty"`
+	// set on QUEUED actions, what they wait for, either "execution" or "command"
+	Queue string `json:"queue,omitempty" bson:"queue,omitempty"`
+
+	CorrelationId  string `json:"correlationId" bson:"correlationId"`
+	CorrelationKey string `json:"correlationKey,omitempty" bson:"correlationKey,omitempty"`
+	FlowName       string `json:"flowName" bson:"flowName"`
+	FlowUUID       string `json:"flowUUID" bson:"flowUUID"`
+	StepId         string `json:"stepId" bson:"stepId"`
 
 	Context map[string]string `json:"context,omitempty" bson:"context,omitempty"`
 	Trigger Event             `json:"trigger" bson:"trigger"`
 	Result  Event             `json:"result,omitempty" bson:"result,omitempty"`
 }
 
+func (a Action) key() string {
+	key := a.StepId
+	if a.Repetition != 0 {
+		key = fmt.Sprintf("%s#%d", key, a.Repetition)
+	}
+	if a.ReplayOf != "" {
+		// the re-run of a failed action does not hide it
+		key = fmt.Sprintf("%s@%s", key, a.ReplayOf)
+	}
+	return key
+}
+
 type Pack struct {
 	Id     string            `json:"id" bson:"_id"`
 	Name   string            `json:"name" bson:"name"`
@@ -81,6 +165,29 @@ type State struct {
 	Time  time.Time `json:"time" bson:"time"`
 }
 
+// the action that started a sub-flow execution
+type ParentAction struct {
+	ActionId      string `json:"actionId" bson:"actionId"`
+	CorrelationId string `json:"correlationId" bson:"correlationId"`
+	FlowName      string `json:"flowName" bson:"flowName"`
+}
+
+type ForEach struct {
+	Items int    `json:"items" bson:"items"`
+	Join  string `json:"join,omitempty" bson:"join,omitempty"`
+}
+
+type Iteration struct {
+	Index int         `json:"index" bson:"index"`
+	Item  interface{} `json:"item,omitempty" bson:"item,omitempty"`
+}
+
+type Cancellation struct {
+	By     string    `json:"by" bson:"by"`
+	Reason string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	Time   time.Time `json:"time" bson:"time"`
+}
+
 type Event struct {
 	Name       string    `json:"event" bson:"name"`
 	Pack       Pack      `json:"pack" bson:"pack"`
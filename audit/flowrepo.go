@@ -37,6 +37,9 @@ func (r flowMgoRepo) Find(filter flowsFilter) ([]Flow, error) {
 	}
 
 	flowsMap := groupActionsIntoFlows(actions)
+	if err := addExecutions(flowsMap); err != nil {
+		return nil, err
+	}
 
 	return sortFlows(ids, flowsMap), nil
 }
@@ -49,6 +52,9 @@ func (r flowMgoRepo) Get(correlationId string) (*Flow, error) {
 	}
 
 	flowsMap := groupActionsIntoFlows(actions)
+	if err := addExecutions(flowsMap); err != nil {
+		return nil, err
+	}
 
 	flow, ok := flowsMap[correlationId]
 	if !ok {
@@ -63,14 +69,24 @@ func findCorrelationIds(filter flowsFilter) ([]string, error) {
 	s := mongo.GetSession()
 	defer s.Close()
 
-	var bsonIds []bson.M
-	pipe := s.DB(mongo.DbName).C(mongo.AuditCollectionId).Pipe([]bson.M{
+	stages := []bson.M{
 		{"$match": filter.toQuery()},
 		{"$group": bson.M{"_id": "$correlationId", "time": bson.M{"$max": "$state.time"}}},
-		{"$sort": bson.M{"time": -1}},
-		{"$skip": filter.skip},
-		{"$limit": filter.limit},
-	})
+	}
+	if filter.status != "" {
+		stages = append(stages,
+			bson.M{"$lookup": bson.M{"from": mongo.ExecutionCollectionId, "localField": "_id", "foreignField": "_id", "as": "execution"}},
+			bson.M{"$match": bson.M{"execution.status": filter.status}},
+		)
+	}
+	stages = append(stages,
+		bson.M{"$sort": bson.M{"time": -1}},
+		bson.M{"$skip": filter.skip},
+		bson.M{"$limit": filter.limit},
+	)
+
+	var bsonIds []bson.M
+	pipe := s.DB(mongo.DbName).C(mongo.AuditCollectionId).Pipe(stages)
 	if err := pipe.All(&bsonIds); err != nil {
 		return nil, err
 	}
@@ -92,6 +108,7 @@ func findActionsByCorrelationIds(correlationIds []string) ([]Action, error) {
 	return actions, s.DB(mongo.DbName).
 		C(mongo.AuditCollectionId).
 		Find(bson.M{"correlationId": bson.M{"$in": correlationIds}}).
+		Sort("_id").
 		All(&actions)
 }
 
@@ -107,13 +124,58 @@ func groupActionsIntoFlows(actions []Action) map[string]Flow {
 			}
 			flow.Actions = map[string]Action{}
 			flow.CorrelationId = action.CorrelationId
+			flow.Parent = action.Parent
 			flowsMap[flow.CorrelationId] = *flow
 		}
-		flowsMap[action.CorrelationId].Actions[action.StepId] = action
+		if action.Iteration != nil {
+			flow := flowsMap[action.CorrelationId]
+			if flow.Iterations == nil {
+				flow.Iterations = map[string][]Action{}
+			}
+			flow.Iterations[action.StepId] = append(flow.Iterations[action.StepId], action)
+			flowsMap[action.CorrelationId] = flow
+			continue
+		}
+		flowsMap[action.CorrelationId].Actions[action.key()] = action
 	}
 	return flowsMap
 }
 
+// adds the status of the flow executions, which is saved separately from their actions
+func addExecutions(flowsMap map[string]Flow) error {
+
+	var ids []string
+	for id := range flowsMap {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	var executions []struct {
+		CorrelationId string `bson:"_id"`
+		Execution     `bson:",inline"`
+	}
+	err := s.DB(mongo.DbName).
+		C(mongo.ExecutionCollectionId).
+		Find(bson.M{"_id": bson.M{"$in": ids}}).
+		All(&executions)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range executions {
+		flow := flowsMap[e.CorrelationId]
+		execution := e.Execution
+		flow.Execution = &execution
+		flowsMap[e.CorrelationId] = flow
+	}
+	return nil
+}
+
 func getFlow(uuid string) (*Flow, error) {
 
 	s := mongo.GetSession()
@@ -143,6 +205,8 @@ type flowsFilter struct {
 	actionName       string
 	actionPackName   string
 	actionPackLabels map[string]string
+	status           string
+	correlationKey   string
 	skip             int
 	limit            int
 }
@@ -162,6 +226,9 @@ func (flt flowsFilter) toQuery() bson.M {
 	if flt.actionPackName != "" {
 		query["packName"] = flt.actionPackName
 	}
+	if flt.correlationKey != "" {
+		query["correlationKey"] = flt.correlationKey
+	}
 	for k, v := range flt.actionPackLabels {
 		query["packLabels."+k] = v
 	}
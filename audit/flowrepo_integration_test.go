@@ -222,6 +222,38 @@ func TestFindCorrelationIds_ShouldFilterByActionPackLabels(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestFindCorrelationIds_ShouldFilterByExecutionStatus(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	mongoT.Insert(t, mongo.AuditCollectionId, newActionT("flowA", "", "stepA", time.Now().Add(-time.Hour)))
+	mongoT.Insert(t, mongo.AuditCollectionId, newActionT("flowB", "", "stepA", time.Now()))
+	mongoT.Insert(t, mongo.AuditCollectionId, newActionT("flowC", "", "stepA", time.Now()))
+	mongoT.Insert(t, mongo.ExecutionCollectionId, bson.M{"_id": "flowA", "status": "FAILED"})
+	mongoT.Insert(t, mongo.ExecutionCollectionId, bson.M{"_id": "flowB", "status": "SUCCEEDED"})
+
+	got, err := findCorrelationIds(flowsFilter{status: "FAILED", limit: 50})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"flowA"}, got)
+}
+
+func TestFindCorrelationIds_ShouldFilterByCorrelationKey(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	for _, correlationId := range []string{"flowA", "flowB", "flowC"} {
+		a := newActionT(correlationId, "", "ask", time.Now())
+		if correlationId != "flowB" {
+			a.CorrelationKey = "INC-42"
+		}
+		mongoT.Insert(t, mongo.AuditCollectionId, a)
+	}
+
+	got, err := findCorrelationIds(flowsFilter{correlationKey: "INC-42", limit: 50})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"flowA", "flowC"}, got)
+}
+
 func TestFindCorrelationIds_ShouldSkipFirstNItems(t *testing.T) {
 
 	mongoT.DropDatabase(t)
@@ -347,6 +379,95 @@ func TestGetFlow_ShouldReturnFlowWithAllCorrelatedActions(t *testing.T) {
 	assert.Equal(t, want, *got)
 }
 
+func TestGetFlow_ShouldGroupIterationsOfForEachStep(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	step := newActionT("flowA", "Restart", "restart", time.Now().Add(-2*time.Hour))
+	step.FlowUUID = "defA"
+	step.States = []State{}
+	step.ForEach = &ForEach{Items: 2}
+	var iterations []Action
+	for i, host := range []string{"db1", "db2"} {
+		it := newActionT("flowA", "Restart", "restart", time.Now().Add(-time.Hour))
+		it.FlowUUID = "defA"
+		it.States = []State{}
+		it.Iteration = &Iteration{Index: i, Item: host}
+		iterations = append(iterations, it)
+	}
+	mongoT.Insert(t, mongo.AuditCollectionId, step)
+	mongoT.Insert(t, mongo.AuditCollectionId, iterations[0])
+	mongoT.Insert(t, mongo.AuditCollectionId, iterations[1])
+	mongoT.Insert(t, mongo.HistoryCollectionId, Flow{UUID: "defA", Steps: []Step{{Id: "restart"}}})
+
+	got, err := flowRepo.Get("flowA")
+	require.NoError(t, err)
+
+	want := Flow{UUID: "defA", CorrelationId: "flowA", Steps: []Step{{Id: "restart"}}}
+	want.Actions = map[string]Action{"restart": step}
+	want.Iterations = map[string][]Action{"restart": iterations}
+	assert.Equal(t, want, *got)
+}
+
+func TestGetFlow_ShouldReturnEveryRepetitionOfStep(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	first := newActionT("flowA", "Ask", "ask", time.Now().Add(-2*time.Hour))
+	first.FlowUUID = "defA"
+	first.States = []State{}
+	second := newActionT("flowA", "Ask", "ask", time.Now().Add(-time.Hour))
+	second.FlowUUID = "defA"
+	second.States = []State{}
+	second.Repetition = 1
+	mongoT.Insert(t, mongo.AuditCollectionId, first)
+	mongoT.Insert(t, mongo.AuditCollectionId, second)
+	mongoT.Insert(t, mongo.HistoryCollectionId, Flow{UUID: "defA", Steps: []Step{{Id: "ask"}}})
+
+	got, err := flowRepo.Get("flowA")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]Action{"ask": first, "ask#1": second}, got.Actions)
+}
+
+func TestGetFlow_ShouldReturnFailedActionAlongsideItsReplay(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	failed := newActionT("flowA", "Ask", "ask", time.Now().Add(-2*time.Hour))
+	failed.FlowUUID = "defA"
+	failed.States = []State{}
+	replay := newActionT("flowA", "Ask", "ask", time.Now().Add(-time.Hour))
+	replay.FlowUUID = "defA"
+	replay.States = []State{}
+	replay.ReplayOf = failed.Id
+	mongoT.Insert(t, mongo.AuditCollectionId, failed)
+	mongoT.Insert(t, mongo.AuditCollectionId, replay)
+	mongoT.Insert(t, mongo.HistoryCollectionId, Flow{UUID: "defA", Steps: []Step{{Id: "ask"}}})
+
+	got, err := flowRepo.Get("flowA")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]Action{"ask": failed, "ask@" + failed.Id: replay}, got.Actions)
+}
+
+func TestGetFlow_ShouldReturnFlowWithItsExecutionStatus(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	action := newActionT("flowA", "", "stepA", time.Now())
+	action.FlowUUID = "defA"
+	action.States = []State{}
+	mongoT.Insert(t, mongo.AuditCollectionId, action)
+	mongoT.Insert(t, mongo.HistoryCollectionId, Flow{UUID: "defA", Steps: []Step{{Id: "stepA"}}})
+	started := time.Now().Add(-time.Hour).Round(time.Millisecond).UTC()
+	mongoT.Insert(t, mongo.ExecutionCollectionId, bson.M{"_id": "flowA", "status": "SUCCEEDED", "startedAt": started, "lastActivityAt": action.State.Time, "terminalStepId": "stepA"})
+
+	got, err := flowRepo.Get("flowA")
+	require.NoError(t, err)
+
+	require.NotNil(t, got.Execution)
+	assert.Equal(t, "SUCCEEDED", got.Execution.Status)
+	assert.Equal(t, "stepA", got.Execution.TerminalStepId)
+	assert.True(t, started.Equal(got.Execution.StartedAt))
+}
+
 // --- helpers ---
 
 func newActionT(correlationId, actionName, stepId string, stateTime time.Time) Action {
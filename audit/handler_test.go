@@ -17,6 +17,7 @@ limitations under the License.
 package audit
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/ExpediaGroup/flyte/httputil"
 	"github.com/stretchr/testify/assert"
@@ -25,6 +26,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestGetFlows_ShouldReturnListOfFlowsWithLinks_WhenFlowsExist(t *testing.T) {
@@ -112,7 +114,7 @@ func TestGetFlows_ShouldExtractFilterParametersFromTheRequest(t *testing.T) {
 		},
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/v1/audit/flows?flowName=flowA&stepId=stepA&actionName=actionA&actionPackName=packA&actionPackLabels=env:dev,foo:bar&start=10&limit=10", nil)
+	req := httptest.NewRequest(http.MethodGet, "/v1/audit/flows?flowName=flowA&stepId=stepA&actionName=actionA&actionPackName=packA&actionPackLabels=env:dev,foo:bar&status=FAILED&correlationKey=INC-42&start=10&limit=10", nil)
 	w := httptest.NewRecorder()
 	GetFlows(w, req)
 
@@ -124,6 +126,8 @@ func TestGetFlows_ShouldExtractFilterParametersFromTheRequest(t *testing.T) {
 		actionName:       "actionA",
 		actionPackName:   "packA",
 		actionPackLabels: map[string]string{"env": "dev", "foo": "bar"},
+		status:           "FAILED",
+		correlationKey:   "INC-42",
 		skip:             10,
 		limit:            10,
 	}
@@ -201,6 +205,70 @@ func TestGetFlow_ShouldReturnFlowWithActions(t *testing.T) {
 	assert.Equal(t, expectedBody, string(body))
 }
 
+func TestGetFlow_ShouldLinkParentAndChildFlows(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		get: func(correlationId string) (*Flow, error) {
+			flow := &Flow{
+				Name:          "deploy",
+				CorrelationId: "child",
+				Parent:        &ParentAction{ActionId: "a1", CorrelationId: "parent", FlowName: "release"},
+				Steps:         []Step{{Id: "start"}, {Id: "deployDb"}},
+				Actions: map[string]Action{
+					"start":    {StepId: "start"},
+					"deployDb": {StepId: "deployDb", ChildCorrelationId: "grandchild"},
+				},
+			}
+			return flow, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/audit/flows/child?:flowName=child", nil)
+	httputil.SetProtocolAndHostIn(req)
+	w := httptest.NewRecorder()
+	GetFlow(w, req)
+
+	resp := w.Result()
+	var body struct {
+		Links []httputil.Link `json:"links"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []httputil.Link{
+		{Href: "http://example.com/v1/audit/flows/child", Rel: "self"},
+		{Href: "http://example.com/v1/audit/flows/parent", Rel: "parent"},
+		{Href: "http://example.com/v1/audit/flows/grandchild", Rel: "child"},
+	}, body.Links)
+}
+
+func TestGetFlow_ShouldReturnExecutionStatus(t *testing.T) {
+
+	defer resetFlowRepo()
+	started := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	flowRepo = mockFlowRepo{
+		get: func(correlationId string) (*Flow, error) {
+			execution := &Execution{Status: "FAILED", StartedAt: started, LastActivityAt: started.Add(time.Minute), TerminalStepId: "stepA"}
+			return &Flow{CorrelationId: correlationId, Execution: execution}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/audit/flows/flowA?:flowName=flowA", nil)
+	httputil.SetProtocolAndHostIn(req)
+	w := httptest.NewRecorder()
+	GetFlow(w, req)
+
+	resp := w.Result()
+	var body struct {
+		Execution json.RawMessage `json:"execution"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"status":"FAILED","startedAt":"2018-01-01T12:00:00Z","lastActivityAt":"2018-01-01T12:01:00Z","terminalStepId":"stepA"}`, string(body.Execution))
+}
+
 func TestGetFlow_ShouldReturn404ForNonExistingFlow(t *testing.T) {
 
 	defer resetFlowRepo()
@@ -21,6 +21,7 @@ import (
 	"github.com/ExpediaGroup/flyte/httputil"
 	"github.com/rs/zerolog/log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -29,9 +30,7 @@ func toFlowsResponse(r *http.Request, flows []Flow) flowsResponse {
 
 	fs := []flowResponse{}
 	for _, f := range flows {
-		link := httputil.UriBuilder(r).Path(flytepath.AuditFlowPath, f.CorrelationId).Build()
-		flowResponse := flowResponse{Flow: f, Links: []httputil.Link{{Href: link, Rel: "self"}}}
-		fs = append(fs, flowResponse)
+		fs = append(fs, toFlowResponse(r, f))
 	}
 
 	return flowsResponse{
@@ -46,7 +45,25 @@ func toFlowsResponse(r *http.Request, flows []Flow) flowsResponse {
 
 func toFlowResponse(r *http.Request, flow Flow) flowResponse {
 	link := httputil.UriBuilder(r).Path(flytepath.AuditFlowPath, flow.CorrelationId).Build()
-	return flowResponse{Flow: flow, Links: []httputil.Link{{Href: link, Rel: "self"}}}
+	links := []httputil.Link{{Href: link, Rel: "self"}}
+
+	// sub-flow executions link to the execution that started them and the other way round
+	if flow.Parent != nil {
+		link := httputil.UriBuilder(r).Path(flytepath.AuditFlowPath, flow.Parent.CorrelationId).Build()
+		links = append(links, httputil.Link{Href: link, Rel: "parent"})
+	}
+	var keys []string
+	for key := range flow.Actions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if a := flow.Actions[key]; a.ChildCorrelationId != "" {
+			link := httputil.UriBuilder(r).Path(flytepath.AuditFlowPath, a.ChildCorrelationId).Build()
+			links = append(links, httputil.Link{Href: link, Rel: "child"})
+		}
+	}
+	return flowResponse{Flow: flow, Links: links}
 }
 
 type flowsResponse struct {
@@ -87,6 +104,8 @@ func toFlowsFilter(r *http.Request) flowsFilter {
 		actionName:       r.URL.Query().Get("actionName"),
 		actionPackName:   r.URL.Query().Get("actionPackName"),
 		actionPackLabels: keyValuePair(r.URL.Query().Get("actionPackLabels")),
+		status:           r.URL.Query().Get("status"),
+		correlationKey:   r.URL.Query().Get("correlationKey"),
 		skip:             start,
 		limit:            limit,
 	}
@@ -118,11 +118,49 @@ func authHandlerFunc(h http.Handler, c policyClaims, v *oidc.IDTokenVerifier) ht
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
+			ctx := context.WithValue(req.Context(), userContextKey, userFrom(claims))
+			req = req.WithContext(context.WithValue(ctx, claimsContextKey, claims))
 		}
 		h.ServeHTTP(w, req)
 	}
 }
 
+type contextKey string
+
+const (
+	userContextKey   contextKey = "user"
+	claimsContextKey contextKey = "claims"
+)
+
+// User returns the user of an authorised request, or empty string when the request was not authorised.
+func User(req *http.Request) string {
+	user, _ := req.Context().Value(userContextKey).(string)
+	return user
+}
+
+// ClaimsFulfilled tells whether the token claims of an authorised request match any of the claims, the same way as the
+// claims of a path policy are matched. Only requests to paths with policy claims have their token verified,
+// any other request has no token claims and does not match, unless there are no claims to match.
+func ClaimsFulfilled(req *http.Request, claims map[string][]string) bool {
+	if len(claims) == 0 {
+		return true
+	}
+	tokenClaims, ok := req.Context().Value(claimsContextKey).(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	return policyClaims(claims).fulfilled(tokenClaims, getPathParams(req))
+}
+
+func userFrom(claims jwt.MapClaims) string {
+	for _, claim := range []string{"email", "preferred_username", "sub"} {
+		if v, ok := claims[claim].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 func getPathParams(req *http.Request) map[string]string {
 	pathParams := make(map[string]string)
 	for _, name := range vestigo.TrimmedParamNames(req) {
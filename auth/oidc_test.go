@@ -111,6 +111,71 @@ func TestShouldReturn200_WhenUserRequestsProtectedResourceWithValidIdTokenAndMat
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestShouldReturn200_WhenUserTriggersFlowWithValidIdTokenAndMatchingClaims(t *testing.T) {
+
+	handler, cleanupFunc := createTestAuthHandler(t, simpleHandler)
+	defer cleanupFunc()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://flyte/flows/rotate/executions", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "http://flyte/flows/rotate/executions", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", authenticIdToken))
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestShouldSetUserFromIdToken_WhenUserRequestsProtectedResourceWithValidIdTokenAndMatchingClaims(t *testing.T) {
+
+	var user string
+	handler, cleanupFunc := createTestAuthHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = User(r)
+	}))
+	defer cleanupFunc()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "http://flyte/packs/foo-pack", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", authenticIdToken))
+
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, "jdoe@email.com", user)
+}
+
+func TestUser_ShouldReturnEmptyUserForUnauthorisedRequest(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodGet, "http://flyte/packs", nil)
+
+	assert.Equal(t, "", User(req))
+}
+
+func TestClaimsFulfilled_ShouldMatchTokenClaimsOfAuthorisedRequest(t *testing.T) {
+
+	var packAdmin, flowWriter bool
+	handler, cleanupFunc := createTestAuthHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		packAdmin = ClaimsFulfilled(r, map[string][]string{"groups": {"flowwriter", "packadmin"}})
+		flowWriter = ClaimsFulfilled(r, map[string][]string{"groups": {"flowwriter"}, "email": {"jane@email.com"}})
+	}))
+	defer cleanupFunc()
+	req := httptest.NewRequest(http.MethodDelete, "http://flyte/packs/foo-pack", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", authenticIdToken))
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.True(t, packAdmin)
+	assert.False(t, flowWriter)
+}
+
+func TestClaimsFulfilled_ShouldNotMatchClaimsOfUnauthorisedRequest(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodGet, "http://flyte/packs", nil)
+
+	assert.False(t, ClaimsFulfilled(req, map[string][]string{"groups": {"packadmin"}}))
+	assert.True(t, ClaimsFulfilled(req, nil))
+}
+
 // -- mocks, test data and setup functions
 
 var simpleHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
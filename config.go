@@ -41,10 +41,23 @@ const (
 	shouldDeleteDeadPacksEnvName             = "FLYTE_SHOULD_DELETE_DEAD_PACKS"
 	deleteDeadPacksTimeEnvName               = "FLYTE_DELETE_DEAD_PACKS_AT_HH_COLON_MM"
 	packGracePeriodUntilDeadInSecondsEnvName = "FLYTE_PACK_GRACE_PERIOD_UNTIL_MARKED_DEAD_IN_SECONDS"
+	actionTimeoutCheckIntervalEnvName        = "FLYTE_ACTION_TIMEOUT_CHECK_INTERVAL_IN_SECONDS"
+	actionLeaseEnvName                       = "FLYTE_ACTION_LEASE_IN_SECONDS"
+	actionLeaseCheckIntervalEnvName          = "FLYTE_ACTION_LEASE_CHECK_INTERVAL_IN_SECONDS"
+	actionFlowFairnessEnvName                = "FLYTE_ACTION_FLOW_FAIRNESS"
+	scheduleCheckIntervalEnvName             = "FLYTE_SCHEDULE_CHECK_INTERVAL_IN_SECONDS"
+	builtinCheckIntervalEnvName              = "FLYTE_BUILTIN_COMMAND_CHECK_INTERVAL_IN_SECONDS"
+	queueCheckIntervalEnvName                = "FLYTE_QUEUE_CHECK_INTERVAL_IN_SECONDS"
+	httpRequestAllowedHostsEnvName           = "FLYTE_HTTP_REQUEST_ALLOWED_HOSTS"
 	logLevelEnvName                          = "LOGLEVEL"
 	defaultDeleteDeadPacksTime               = "23:00"
 	oneWeekInSeconds                         = 604800
 	oneYearInSeconds                         = 31557600
+	defaultActionTimeoutCheckInterval        = 30
+	defaultActionLeaseCheckInterval          = 10
+	defaultScheduleCheckInterval             = 10
+	defaultBuiltinCheckInterval              = 5
+	defaultQueueCheckInterval                = 30
 )
 
 type Config struct {
@@ -59,6 +72,14 @@ type Config struct {
 	ShouldDeleteDeadPacks             bool
 	DeleteDeadPacksTime               string
 	PackGracePeriodUntilDeadInSeconds int
+	ActionTimeoutCheckIntervalSeconds int
+	ActionLeaseInSeconds              int
+	ActionLeaseCheckIntervalSeconds   int
+	ActionFlowFairness                bool
+	ScheduleCheckIntervalSeconds      int
+	BuiltinCheckIntervalSeconds       int
+	QueueCheckIntervalSeconds         int
+	HttpRequestAllowedHosts           []string
 	LogLevel                          zerolog.Level
 }
 
@@ -78,6 +99,14 @@ func NewConfig() Config {
 	c.ShouldDeleteDeadPacks = getBoolEnvVarWithDefault(shouldDeleteDeadPacksEnvName, false)
 	c.DeleteDeadPacksTime = getDeleteDeadPacksTimeEnvVarWithDefault(deleteDeadPacksTimeEnvName, defaultDeleteDeadPacksTime)
 	c.PackGracePeriodUntilDeadInSeconds = getIntEnvVarWithDefault(packGracePeriodUntilDeadInSecondsEnvName, oneWeekInSeconds)
+	c.ActionTimeoutCheckIntervalSeconds = getIntEnvVarWithDefault(actionTimeoutCheckIntervalEnvName, defaultActionTimeoutCheckInterval)
+	c.ActionLeaseInSeconds = getIntEnvVarWithDefault(actionLeaseEnvName, 0)
+	c.ActionLeaseCheckIntervalSeconds = getIntEnvVarWithDefault(actionLeaseCheckIntervalEnvName, defaultActionLeaseCheckInterval)
+	c.ActionFlowFairness = getBoolEnvVarWithDefault(actionFlowFairnessEnvName, false)
+	c.ScheduleCheckIntervalSeconds = getIntEnvVarWithDefault(scheduleCheckIntervalEnvName, defaultScheduleCheckInterval)
+	c.BuiltinCheckIntervalSeconds = getIntEnvVarWithDefault(builtinCheckIntervalEnvName, defaultBuiltinCheckInterval)
+	c.QueueCheckIntervalSeconds = getIntEnvVarWithDefault(queueCheckIntervalEnvName, defaultQueueCheckInterval)
+	c.HttpRequestAllowedHosts = getListEnvVar(httpRequestAllowedHostsEnvName)
 	return c
 }
 
@@ -125,6 +154,17 @@ func getBoolEnvVarWithDefault(name string, defaultVal bool) bool {
 	return boolVal
 }
 
+// comma separated values, blank ones are ignored
+func getListEnvVar(name string) []string {
+	var list []string
+	for _, v := range strings.Split(getEnvVar(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 func getPathVar(envName string) string {
 	path := getEnvVar(envName)
 	if path != "" && !fileExists(path) {
@@ -174,6 +214,10 @@ func (c Config) requireTLS() bool {
 	return c.TLSCertPath != "" && c.TLSKeyPath != ""
 }
 
+func (c Config) requireActionLeases() bool {
+	return c.ActionLeaseInSeconds > 0
+}
+
 func (c Config) requireAuth() bool {
 	return c.AuthPolicyPath != "" && c.OidcIssuerURL != "" && c.OidcIssuerClientID != ""
 }
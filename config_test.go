@@ -41,6 +41,14 @@ func newflyteEnvVars() envVars {
 		shouldDeleteDeadPacksEnvName:             "false",
 		deleteDeadPacksTimeEnvName:               "10:00",
 		packGracePeriodUntilDeadInSecondsEnvName: "500000",
+		actionTimeoutCheckIntervalEnvName:        "5",
+		actionLeaseEnvName:                       "60",
+		actionLeaseCheckIntervalEnvName:          "15",
+		actionFlowFairnessEnvName:                "true",
+		scheduleCheckIntervalEnvName:             "20",
+		builtinCheckIntervalEnvName:              "2",
+		queueCheckIntervalEnvName:                "40",
+		httpRequestAllowedHostsEnvName:           "api.example.com, *.example.org,",
 	}
 }
 
@@ -64,6 +72,14 @@ func TestConfigShouldReadFlyteEnvVars(t *testing.T) {
 	assert.Equal(t, false, c.ShouldDeleteDeadPacks)
 	assert.Equal(t, "10:00", c.DeleteDeadPacksTime)
 	assert.Equal(t, 500000, c.PackGracePeriodUntilDeadInSeconds)
+	assert.Equal(t, 5, c.ActionTimeoutCheckIntervalSeconds)
+	assert.Equal(t, 60, c.ActionLeaseInSeconds)
+	assert.Equal(t, 15, c.ActionLeaseCheckIntervalSeconds)
+	assert.True(t, c.ActionFlowFairness)
+	assert.Equal(t, 20, c.ScheduleCheckIntervalSeconds)
+	assert.Equal(t, 2, c.BuiltinCheckIntervalSeconds)
+	assert.Equal(t, 40, c.QueueCheckIntervalSeconds)
+	assert.Equal(t, []string{"api.example.com", "*.example.org"}, c.HttpRequestAllowedHosts)
 }
 
 func TestConfigShouldDefaultMongoHostIfNotSetAsEnvVar(t *testing.T) {
@@ -304,3 +320,89 @@ func TestConfigShouldSetDefaultPackGracePeriodUntilDeadInSeconds(t *testing.T) {
 	// default flyte pack grace period in seconds
 	assert.Equal(t, oneWeekInSeconds, c.PackGracePeriodUntilDeadInSeconds)
 }
+
+func TestConfigShouldSetDefaultActionTimeoutCheckInterval(t *testing.T) {
+	defer func(oldFileExists func(string) bool) { fileExists = oldFileExists }(fileExists)
+	fileExists = func(string) bool { return true }
+
+	flyteEnvVars := newflyteEnvVars()
+	delete(flyteEnvVars, actionTimeoutCheckIntervalEnvName)
+	defer func(oldGetEnv func(string) (string, bool)) { lookupEnv = oldGetEnv }(lookupEnv)
+	lookupEnv = flyteEnvVars.lookupEnv
+
+	c := NewConfig()
+
+	assert.Equal(t, defaultActionTimeoutCheckInterval, c.ActionTimeoutCheckIntervalSeconds)
+}
+
+func TestConfigShouldSetDefaultScheduleCheckInterval(t *testing.T) {
+	defer func(oldFileExists func(string) bool) { fileExists = oldFileExists }(fileExists)
+	fileExists = func(string) bool { return true }
+
+	flyteEnvVars := newflyteEnvVars()
+	delete(flyteEnvVars, scheduleCheckIntervalEnvName)
+	defer func(oldGetEnv func(string) (string, bool)) { lookupEnv = oldGetEnv }(lookupEnv)
+	lookupEnv = flyteEnvVars.lookupEnv
+
+	c := NewConfig()
+
+	assert.Equal(t, defaultScheduleCheckInterval, c.ScheduleCheckIntervalSeconds)
+}
+
+func TestConfigShouldSetDefaultBuiltinCheckInterval(t *testing.T) {
+	defer func(oldFileExists func(string) bool) { fileExists = oldFileExists }(fileExists)
+	fileExists = func(string) bool { return true }
+
+	flyteEnvVars := newflyteEnvVars()
+	delete(flyteEnvVars, builtinCheckIntervalEnvName)
+	defer func(oldGetEnv func(string) (string, bool)) { lookupEnv = oldGetEnv }(lookupEnv)
+	lookupEnv = flyteEnvVars.lookupEnv
+
+	c := NewConfig()
+
+	assert.Equal(t, defaultBuiltinCheckInterval, c.BuiltinCheckIntervalSeconds)
+}
+
+func TestConfigShouldSetDefaultQueueCheckInterval(t *testing.T) {
+	defer func(oldFileExists func(string) bool) { fileExists = oldFileExists }(fileExists)
+	fileExists = func(string) bool { return true }
+
+	flyteEnvVars := newflyteEnvVars()
+	delete(flyteEnvVars, queueCheckIntervalEnvName)
+	defer func(oldGetEnv func(string) (string, bool)) { lookupEnv = oldGetEnv }(lookupEnv)
+	lookupEnv = flyteEnvVars.lookupEnv
+
+	c := NewConfig()
+
+	assert.Equal(t, defaultQueueCheckInterval, c.QueueCheckIntervalSeconds)
+}
+
+func TestConfigShouldAllowAnyHttpRequestHostByDefault(t *testing.T) {
+	defer func(oldFileExists func(string) bool) { fileExists = oldFileExists }(fileExists)
+	fileExists = func(string) bool { return true }
+
+	flyteEnvVars := newflyteEnvVars()
+	delete(flyteEnvVars, httpRequestAllowedHostsEnvName)
+	defer func(oldGetEnv func(string) (string, bool)) { lookupEnv = oldGetEnv }(lookupEnv)
+	lookupEnv = flyteEnvVars.lookupEnv
+
+	c := NewConfig()
+
+	assert.Empty(t, c.HttpRequestAllowedHosts)
+}
+
+func TestConfigShouldDisableActionLeasesByDefault(t *testing.T) {
+	defer func(oldFileExists func(string) bool) { fileExists = oldFileExists }(fileExists)
+	fileExists = func(string) bool { return true }
+
+	flyteEnvVars := newflyteEnvVars()
+	delete(flyteEnvVars, actionLeaseEnvName)
+	delete(flyteEnvVars, actionLeaseCheckIntervalEnvName)
+	defer func(oldGetEnv func(string) (string, bool)) { lookupEnv = oldGetEnv }(lookupEnv)
+	lookupEnv = flyteEnvVars.lookupEnv
+
+	c := NewConfig()
+
+	assert.False(t, c.requireActionLeases())
+	assert.Equal(t, defaultActionLeaseCheckInterval, c.ActionLeaseCheckIntervalSeconds)
+}
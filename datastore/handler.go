@@ -18,6 +18,7 @@ package datastore
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ExpediaGroup/flyte/httputil"
 	"github.com/husobee/vestigo"
@@ -119,3 +120,22 @@ func GetDataStoreValue(key string) (interface{}, error) {
 	}
 	return value, nil
 }
+
+// Stores the item, returns true when an existing item has been replaced.
+func StoreDataStoreValue(item DataItem) (updated bool, err error) {
+
+	if item.Key == "" {
+		return false, errors.New("data store item key is empty")
+	}
+	return datastoreRepo.Store(item)
+}
+
+// Removes the item, returns false when there is no item with the key.
+func RemoveDataStoreValue(key string) (removed bool, err error) {
+
+	err = datastoreRepo.Remove(key)
+	if err == dataItemNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
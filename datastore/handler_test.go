@@ -292,6 +292,47 @@ func TestGetDataStoreValue_ShouldReturnError_WhenThereIsIssueGettingItem(t *test
 	assert.EqualError(t, err, "cannot find datastore item key=error_item: unexpected error")
 }
 
+func TestStoreDataStoreValue_ShouldStoreItem(t *testing.T) {
+
+	defer resetDatastoreRepo()
+	var stored DataItem
+	datastoreRepo = mockDatastoreRepo{
+		store: func(item DataItem) (bool, error) {
+			stored = item
+			return true, nil
+		},
+	}
+
+	item := DataItem{Key: "counter", ContentType: "text/plain", Value: []byte("1")}
+	updated, err := StoreDataStoreValue(item)
+
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, item, stored)
+}
+
+func TestStoreDataStoreValue_ShouldReturnErrorForEmptyKey(t *testing.T) {
+
+	_, err := StoreDataStoreValue(DataItem{Value: []byte("1")})
+
+	assert.EqualError(t, err, "data store item key is empty")
+}
+
+func TestRemoveDataStoreValue_ShouldReturnFalseWhenItemDoesNotExist(t *testing.T) {
+
+	defer resetDatastoreRepo()
+	datastoreRepo = mockDatastoreRepo{
+		remove: func(key string) error {
+			return dataItemNotFound
+		},
+	}
+
+	removed, err := RemoveDataStoreValue("counter")
+
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
+
 func TestStoreItem_ShouldCreateNewItem(t *testing.T) {
 	defer resetDatastoreRepo()
 	var actualItem DataItem
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"github.com/ExpediaGroup/flyte/json"
 	"github.com/rs/zerolog/log"
+	"gopkg.in/mgo.v2/bson"
 	"time"
 )
 
@@ -32,7 +33,57 @@ type Action struct {
 	Input      json.Json         `bson:"input,omitempty"`
 	State      State             `bson:"state"`
 	States     []State           `bson:"states"`
-	prevState  State             `bson:"_"`
+	Timeout    Timeout           `bson:"timeout,omitempty"`
+	ExpiresAt  time.Time         `bson:"expiresAt,omitempty"`
+	Retry      Retry             `bson:"retry,omitempty"`
+	Attempt    int               `bson:"attempt,omitempty"`
+	NotBefore  time.Time         `bson:"notBefore,omitempty"`
+	Priority   int               `bson:"priority,omitempty"`
+
+	// PackLabels as "key=value" strings, set by the repository so labels can be matched in mongo
+	PackLabelList []string `bson:"packLabelList,omitempty"`
+	// PackLabelList joined into one string, so the actions a pack can handle can be found through an index
+	PackLabelKey string `bson:"packLabelKey"`
+
+	// set when the flow execution the action belongs to has been cancelled
+	Cancellation *Cancellation `bson:"cancellation,omitempty"`
+
+	// id of the failed action this action re-runs
+	ReplayOf string `bson:"replayOf,omitempty"`
+
+	// when flyte runs a command of the built-in pack, e.g. when a Wait ends
+	WakeAt time.Time `bson:"wakeAt,omitempty"`
+
+	// correlation id of the sub-flow execution started by the action
+	ChildCorrelationId string `bson:"childCorrelationId,omitempty"`
+	// set on the actions of a sub-flow execution, refers to the action that started it
+	Parent *ParentAction `bson:"parent,omitempty"`
+
+	// set on the action of a forEach step and on the actions it creates for each item
+	ForEach    *ForEach   `bson:"forEach,omitempty"`
+	Iteration  *Iteration `bson:"iteration,omitempty"`
+	iterations []Action   `bson:"-"`
+
+	// how many times the step had been executed before this action in the flow execution, see Repeat
+	Repetition int `bson:"repetition,omitempty"`
+
+	// resolved correlation key of the flow execution, see Flow.CorrelationKey
+	CorrelationKey string `bson:"correlationKey,omitempty"`
+
+	// set on the actions of onError and finally steps, either "onError" or "finally"
+	Handler string `bson:"handler,omitempty"`
+
+	// how many actions of the step can be in flight across the executions of the flow, see Command.MaxInFlight
+	MaxInFlight int `bson:"maxInFlight,omitempty"`
+	// set on a QUEUED action, what it waits for, either "execution" or "command"
+	Queue string `bson:"queue,omitempty"`
+
+	LeaseExpiresAt time.Time `bson:"leaseExpiresAt,omitempty"`
+	// issued to the pack that took the action, only its holder can extend the lease or complete the action
+	LeaseToken   string `bson:"leaseToken,omitempty"`
+	Redeliveries int    `bson:"redeliveries,omitempty"`
+	prevState    State  `bson:"_"`
+	prevLease    *lease `bson:"-"`
 
 	CorrelationId string `bson:"correlationId"`
 	FlowName      string `bson:"flowName"`
@@ -50,42 +101,188 @@ func (a *Action) take() error {
 		return fmt.Errorf("action is not in %s state, cannot set to %s", stateNew, statePending)
 	}
 	a.setState(statePending)
-	err := actionRepo.Update(*a)
-	if err != nil {
-		return err
+	a.ExpiresAt = a.Timeout.completeDeadline(a.State.Time)
+	if leaseDuration > 0 {
+		a.LeaseExpiresAt = a.State.Time.Add(leaseDuration)
+		a.LeaseToken = bson.NewObjectId().Hex()
 	}
-	err = auditRepo.Update(*a)
-	if err != nil {
-		log.Err(err).Msgf("Error updating audit for action=%+v", *a)
+	return a.update()
+}
+
+// the lease an action was read with, an update guarded by it fails when the lease has been extended, or the action
+// taken again, in the meantime
+type lease struct {
+	token     string
+	expiresAt time.Time
+}
+
+func (a *Action) guardLease() {
+	a.prevLease = &lease{token: a.LeaseToken, expiresAt: a.LeaseExpiresAt}
+}
+
+// Checks that the pack completing the action, or extending its lease, holds its current lease. Actions taken without
+// a lease can be completed without a token.
+func (a Action) checkLease(token string) error {
+	if a.LeaseToken == "" {
+		return nil
+	}
+	if a.LeaseToken != token {
+		return ActionLeaseLostErr
 	}
 	return nil
 }
 
+// extends the lease of a taken action
+func (a *Action) heartbeat(token string) error {
+
+	if a.State.Value == stateCancelled {
+		return ActionCancelledErr
+	}
+	if a.State.Value != statePending {
+		return ActionNotPendingErr
+	}
+	if leaseDuration == 0 || a.LeaseExpiresAt.IsZero() {
+		return ActionNotLeasedErr
+	}
+	if err := a.checkLease(token); err != nil {
+		return err
+	}
+	// the state does not change, but the update still has to be guarded by it and by the lease
+	a.prevState = a.State
+	a.guardLease()
+	a.LeaseExpiresAt = currentTime().Add(leaseDuration)
+	return a.update()
+}
+
+// returns a taken action, whose lease has expired, back to the NEW state so it can be taken again
+func (a *Action) requeue() error {
+
+	if a.State.Value != statePending {
+		return fmt.Errorf("action is not in %s state, cannot set to %s", statePending, stateNew)
+	}
+	if !a.LeaseExpiresAt.IsZero() {
+		// the lease may be extended by a heartbeat, or the action completed, before it is requeued
+		a.guardLease()
+	}
+	a.setState(stateNew)
+	a.Redeliveries++
+	a.LeaseExpiresAt = time.Time{}
+	a.LeaseToken = ""
+	a.ExpiresAt = a.Timeout.takeDeadline(a.State.Time)
+	return a.update()
+}
+
 func (a *Action) finish(e Event) error {
 
+	if a.State.Value == stateCancelled {
+		return ActionCancelledErr
+	}
 	if a.State.Value != statePending {
 		return fmt.Errorf("action is not in %s state", statePending)
 	}
 
+	resultState := stateSuccess
 	if e.isFatal() {
-		a.setState(stateFatal)
-	} else {
-		a.setState(stateSuccess)
+		resultState = stateFatal
+	}
+	if a.Retry.shouldRetry(e, a.Attempt) {
+		return a.retry(e, resultState)
 	}
+
+	a.setState(resultState)
 	a.Result = e
-	err := actionRepo.Update(*a)
-	if err != nil {
+	a.ExpiresAt = time.Time{}
+	a.LeaseExpiresAt = time.Time{}
+	a.LeaseToken = ""
+	return a.update()
+}
+
+// expire moves an action that has not been taken or completed in time to the TIMEOUT state
+// and sets a synthetic TIMEOUT event, coming from the action's pack, as its result.
+func (a *Action) expire() error {
+
+	if a.State.Value != stateNew && a.State.Value != statePending {
+		return fmt.Errorf("action is not in %s or %s state, cannot set to %s", stateNew, statePending, stateTimeout)
+	}
+	now := time.Now().UTC()
+	result := Event{
+		Name:       timeoutEventName,
+		Pack:       Pack{Name: a.PackName, Labels: a.PackLabels},
+		CreatedAt:  now,
+		ReceivedAt: now,
+	}
+	if a.Retry.shouldRetry(result, a.Attempt) {
+		return a.retry(result, stateTimeout)
+	}
+
+	a.setState(stateTimeout)
+	a.Result = result
+	a.ExpiresAt = time.Time{}
+	a.LeaseExpiresAt = time.Time{}
+	a.LeaseToken = ""
+	return a.update()
+}
+
+// cancels an action that has not finished yet, a pack that has taken it cannot complete it anymore
+func (a *Action) cancel(c Cancellation) error {
+
+	if !a.isCancellable() {
+		return fmt.Errorf("action is not in %s, %s or %s state, cannot set to %s", stateQueued, stateNew, statePending, stateCancelled)
+	}
+	a.setState(stateCancelled)
+	a.Cancellation = &c
+	a.ExpiresAt = time.Time{}
+	a.LeaseExpiresAt = time.Time{}
+	a.LeaseToken = ""
+	return a.update()
+}
+
+// retry records the failed attempt in the states history and puts the action back to the NEW state,
+// so it can be taken again once the retry delay has passed. The result of the failed attempt is kept
+// on the action but it is not handed over to the flow.
+func (a *Action) retry(result Event, resultState string) error {
+
+	prevState := a.State
+	a.setState(resultState)
+	a.setState(stateNew)
+	a.prevState = prevState
+
+	a.Result = result
+	a.LeaseExpiresAt = time.Time{}
+	a.LeaseToken = ""
+	a.NotBefore = a.State.Time.Add(a.Retry.delay(a.Attempt))
+	a.ExpiresAt = a.Timeout.takeDeadline(a.NotBefore)
+	a.Attempt++
+	if a.isBuiltin() {
+		a.WakeAt = a.NotBefore
+	}
+	if err := a.update(); err != nil {
 		return err
 	}
-	err = auditRepo.Update(*a)
-	if err != nil {
+	newActions.notify()
+	return nil
+}
+
+func (a Action) isRetrying() bool {
+	return a.State.Value == stateNew && a.Attempt > 1
+}
+
+func (a *Action) update() error {
+	if err := actionRepo.Update(*a); err != nil {
+		return err
+	}
+	if err := auditRepo.Update(*a); err != nil {
 		log.Err(err).Msgf("Error updating audit for action=%+v", *a)
 	}
 	return nil
 }
 
+func (a Action) isCancellable() bool {
+	return a.State.Value == stateQueued || a.State.Value == stateNew || a.State.Value == statePending
+}
+
 func (a Action) hasFinished() bool {
-	return a.State.Value == stateSuccess || a.State.Value == stateFatal
+	return a.State.Value == stateSuccess || a.State.Value == stateFatal || a.State.Value == stateTimeout
 }
 
 func (a *Action) setState(state string) {
@@ -99,11 +296,26 @@ type State struct {
 	Time  time.Time `bson:"time"`
 }
 
+type ParentAction struct {
+	ActionId      string `bson:"actionId"`
+	CorrelationId string `bson:"correlationId"`
+	FlowName      string `bson:"flowName"`
+}
+
+type Cancellation struct {
+	By     string    `bson:"by"`
+	Reason string    `bson:"reason,omitempty"`
+	Time   time.Time `bson:"time"`
+}
+
 const (
-	stateNew     = "NEW"
-	statePending = "PENDING"
-	stateSuccess = "SUCCESS"
-	stateFatal   = "FATAL"
+	stateQueued    = "QUEUED"
+	stateNew       = "NEW"
+	statePending   = "PENDING"
+	stateSuccess   = "SUCCESS"
+	stateFatal     = "FATAL"
+	stateTimeout   = "TIMEOUT"
+	stateCancelled = "CANCELLED"
 )
 
 type Event struct {
@@ -118,14 +330,25 @@ func (e Event) isFatal() bool {
 	return e.Name == fatalEventName
 }
 
-const fatalEventName = "FATAL"
+const (
+	fatalEventName   = "FATAL"
+	timeoutEventName = "TIMEOUT"
+)
 
 type ActionRepository interface {
 	Add(action Action) error
 	Get(actionId string) (*Action, error)
 	Update(action Action) error
 	FindNew(pack Pack, name string) (*Action, error)
+	FindAllNew(pack Pack, name string, limit int) ([]Action, error)
+	FindExpired(now time.Time) ([]Action, error)
+	FindLeaseExpired(now time.Time) ([]Action, error)
 	FindCorrelated(correlationId string) ([]Action, error)
+	FindBuiltinDue(now time.Time) ([]Action, error)
+	FindQueued(flowName, stepId, queue string) (*Action, error)
+	FindAllQueued() ([]Action, error)
+	CountInFlight(flowName, stepId string) (int, error)
+	CountRunningExecutions(flowName string) (int, error)
 }
 
 var actionRepo ActionRepository = actionMgoRepo{}
@@ -138,3 +361,7 @@ type AuditRepository interface {
 var auditRepo AuditRepository = auditMgoRepo{}
 
 var ActionNotFoundErr = errors.New("action not found")
+var ActionNotPendingErr = errors.New("action is not pending")
+var ActionCancelledErr = errors.New("action has been cancelled")
+var ActionLeaseLostErr = errors.New("action is leased to another pack")
+var ActionNotLeasedErr = errors.New("action has not been taken with a lease")
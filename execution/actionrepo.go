@@ -17,10 +17,14 @@ limitations under the License.
 package execution
 
 import (
-	"github.com/ExpediaGroup/flyte/collections"
 	"github.com/ExpediaGroup/flyte/mongo"
+	"github.com/rs/zerolog/log"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 )
 
 type actionMgoRepo struct{}
@@ -30,6 +34,8 @@ func (actionMgoRepo) Add(action Action) error {
 	s := mongo.GetSession()
 	defer s.Close()
 
+	action.PackLabelList = packLabelList(action.PackLabels)
+	action.PackLabelKey = packLabelKey(action.PackLabelList)
 	return s.DB(mongo.DbName).C(mongo.ActionCollectionId).Insert(action)
 }
 
@@ -42,37 +48,197 @@ func (actionMgoRepo) FindCorrelated(correlationId string) ([]Action, error) {
 	return actions, s.DB(mongo.DbName).
 		C(mongo.ActionCollectionId).
 		Find(bson.M{"correlationId": correlationId}).
-		Select(bson.M{"_id": 1, "stepId": 1, "state": 1}).
+		Select(bson.M{"_id": 1, "stepId": 1, "state": 1, "cancellation": 1, "result": 1, "forEach": 1, "iteration": 1, "repetition": 1, "handler": 1, "flowName": 1, "maxInFlight": 1, "queue": 1}).
+		Sort("_id").
 		All(&actions)
 }
 
-func (actionMgoRepo) FindNew(pack Pack, name string) (*Action, error) {
+func (r actionMgoRepo) FindNew(pack Pack, name string) (*Action, error) {
+
+	actions, err := r.FindAllNew(pack, name, 1)
+	if err != nil || len(actions) == 0 {
+		return nil, err
+	}
+	return &actions[0], nil
+}
+
+// finds up to limit (0 is no limit) new actions the pack can handle,
+// highest priority first and oldest first within the same priority
+func (actionMgoRepo) FindAllNew(pack Pack, name string, limit int) ([]Action, error) {
 
 	s := mongo.GetSession()
 	defer s.Close()
 
-	query := bson.M{"packName": pack.Name, "state.value": stateNew}
+	var actions []Action
+	return actions, findNewQuery(s, pack, name, limit).All(&actions)
+}
+
+// packs with more labels than this match the labels of the actions without the actionNew index, they would have too
+// many label combinations to look up
+const maxIndexedPackLabels = 8
+
+func findNewQuery(s *mgo.Session, pack Pack, name string, limit int) *mgo.Query {
+
+	query := bson.M{
+		"packName":    pack.Name,
+		"state.value": stateNew,
+		// actions waiting to be retried are not available until their retry delay has passed
+		"$or": []bson.M{
+			{"notBefore": bson.M{"$exists": false}},
+			{"notBefore": bson.M{"$lte": time.Now().UTC()}},
+		},
+	}
+	// the pack must have all the labels of the action, i.e. the action labels are one of the combinations of the pack
+	// labels, or no action label is missing from the pack labels
+	labels := packLabelList(pack.Labels)
+	if len(labels) <= maxIndexedPackLabels {
+		query["packLabelKey"] = bson.M{"$in": packLabelKeys(labels)}
+	} else {
+		query["packLabelList"] = bson.M{"$not": bson.M{"$elemMatch": bson.M{"$nin": labels}}}
+	}
 	if name != "" {
 		query["name"] = name
 	}
 
+	return s.DB(mongo.DbName).
+		C(mongo.ActionCollectionId).
+		Find(query).
+		Sort("-priority", "state.time").
+		Limit(limit)
+}
+
+func (actionMgoRepo) FindExpired(now time.Time) ([]Action, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	query := bson.M{
+		"state.value": bson.M{"$in": []string{stateNew, statePending}},
+		"expiresAt":   bson.M{"$lte": now},
+	}
+
 	var actions []Action
-	err := s.DB(mongo.DbName).
+	return actions, s.DB(mongo.DbName).
+		C(mongo.ActionCollectionId).
+		Find(query).
+		All(&actions)
+}
+
+func (actionMgoRepo) FindLeaseExpired(now time.Time) ([]Action, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	query := bson.M{
+		"state.value":    statePending,
+		"leaseExpiresAt": bson.M{"$lte": now},
+	}
+
+	var actions []Action
+	return actions, s.DB(mongo.DbName).
+		C(mongo.ActionCollectionId).
+		Find(query).
+		All(&actions)
+}
+
+func (actionMgoRepo) FindBuiltinDue(now time.Time) ([]Action, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	query := bson.M{
+		"packName":    builtinPackName,
+		"state.value": bson.M{"$in": []string{stateNew, statePending}},
+		"wakeAt":      bson.M{"$lte": now},
+	}
+
+	var actions []Action
+	return actions, s.DB(mongo.DbName).
 		C(mongo.ActionCollectionId).
 		Find(query).
-		Sort("state.time").
 		All(&actions)
+}
+
+// finds the queued action of the flow, highest priority first and oldest first within the same priority, waiting in
+// the queue of the step (any step when empty)
+func (actionMgoRepo) FindQueued(flowName, stepId, queue string) (*Action, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	query := bson.M{
+		"flowName":    flowName,
+		"state.value": stateQueued,
+		"queue":       queue,
+	}
+	if stepId != "" {
+		query["stepId"] = stepId
+	}
+
+	var action Action
+	err := s.DB(mongo.DbName).
+		C(mongo.ActionCollectionId).
+		Find(query).
+		Sort("-priority", "state.time").
+		One(&action)
 	if err == mgo.ErrNotFound {
 		return nil, nil
 	}
+	return &action, err
+}
 
-	for _, a := range actions {
-		if collections.ContainsAll(pack.Labels, a.PackLabels) {
-			return &a, nil
-		}
-	}
+// finds all the queued actions, only with what they wait for
+func (actionMgoRepo) FindAllQueued() ([]Action, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	var actions []Action
+	return actions, s.DB(mongo.DbName).
+		C(mongo.ActionCollectionId).
+		Find(bson.M{"state.value": stateQueued}).
+		Select(bson.M{"_id": 1, "flowName": 1, "stepId": 1, "state": 1, "queue": 1}).
+		All(&actions)
+}
+
+// counts the actions of the step that are in flight, i.e. new or pending, across the executions of the flow.
+// The action of a forEach step is not run, only its iterations are counted.
+func (actionMgoRepo) CountInFlight(flowName, stepId string) (int, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	return s.DB(mongo.DbName).
+		C(mongo.ActionCollectionId).
+		Find(bson.M{
+			"flowName":    flowName,
+			"stepId":      stepId,
+			"state.value": bson.M{"$in": []string{stateNew, statePending}},
+			"forEach":     bson.M{"$exists": false},
+		}).
+		Count()
+}
+
+// counts the executions of the flow that have an action in flight or waiting for its command, queued executions
+// are not running
+func (actionMgoRepo) CountRunningExecutions(flowName string) (int, error) {
 
-	return nil, nil
+	s := mongo.GetSession()
+	defer s.Close()
+
+	var correlationIds []string
+	err := s.DB(mongo.DbName).
+		C(mongo.ActionCollectionId).
+		Find(bson.M{
+			"flowName": flowName,
+			"forEach":  bson.M{"$exists": false},
+			"$or": []bson.M{
+				{"state.value": bson.M{"$in": []string{stateNew, statePending}}},
+				{"state.value": stateQueued, "queue": queueCommand},
+			},
+		}).
+		Distinct("correlationId", &correlationIds)
+	return len(correlationIds), err
 }
 
 func (actionMgoRepo) Get(actionId string) (*Action, error) {
@@ -96,6 +262,79 @@ func (actionMgoRepo) Update(action Action) error {
 	s := mongo.GetSession()
 	defer s.Close()
 
-	return s.DB(mongo.DbName).C(mongo.ActionCollectionId).
-		Update(bson.M{"_id": action.Id, "state.value": action.prevState.Value}, action)
+	selector := bson.M{"_id": action.Id, "state.value": action.prevState.Value}
+	if l := action.prevLease; l != nil {
+		// fails when the lease has been extended, or the action taken again, since it was read
+		selector["leaseExpiresAt"] = l.expiresAt
+		selector["leaseToken"] = l.token
+		if l.token == "" {
+			// taken before lease tokens were issued
+			selector["leaseToken"] = bson.M{"$exists": false}
+		}
+	}
+
+	action.PackLabelList = packLabelList(action.PackLabels)
+	action.PackLabelKey = packLabelKey(action.PackLabelList)
+	return s.DB(mongo.DbName).C(mongo.ActionCollectionId).Update(selector, action)
+}
+
+// pack labels as "key=value" strings, so the labels an action requires can be matched against pack labels in a query
+func packLabelList(labels map[string]string) []string {
+	list := []string{}
+	for k, v := range labels {
+		list = append(list, url.QueryEscape(k)+"="+url.QueryEscape(v))
+	}
+	sort.Strings(list)
+	return list
+}
+
+// the commas of the labels are escaped, so they cannot be confused with the separator
+func packLabelKey(list []string) string {
+	return strings.Join(list, ",")
+}
+
+// keys of all the combinations of the sorted labels, including no labels at all
+func packLabelKeys(list []string) []string {
+	keys := []string{}
+	for n := 0; n < 1<<uint(len(list)); n++ {
+		var combination []string
+		for i, l := range list {
+			if n&(1<<uint(i)) != 0 {
+				combination = append(combination, l)
+			}
+		}
+		keys = append(keys, packLabelKey(combination))
+	}
+	return keys
+}
+
+// Adds the pack label list and key to new and pending actions created before they were introduced, otherwise they would
+// be available to any pack regardless of its labels, or to no pack at all.
+func MigrateActionPackLabels() {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	c := s.DB(mongo.DbName).C(mongo.ActionCollectionId)
+	iter := c.Find(bson.M{
+		"state.value":  bson.M{"$in": []string{stateNew, statePending}},
+		"packLabelKey": bson.M{"$exists": false},
+	}).Iter()
+
+	migrated := 0
+	var a Action
+	for iter.Next(&a) {
+		list := packLabelList(a.PackLabels)
+		if err := c.UpdateId(a.Id, bson.M{"$set": bson.M{"packLabelList": list, "packLabelKey": packLabelKey(list)}}); err != nil {
+			log.Err(err).Msgf("Error migrating pack labels of actionId=%s", a.Id)
+			continue
+		}
+		migrated++
+	}
+	if err := iter.Close(); err != nil {
+		log.Err(err).Msg("Error migrating action pack labels")
+	}
+	if migrated > 0 {
+		log.Info().Msgf("Migrated pack labels of %d actions", migrated)
+	}
 }
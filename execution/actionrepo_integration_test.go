@@ -19,6 +19,7 @@ limitations under the License.
 package execution
 
 import (
+	"fmt"
 	"github.com/ExpediaGroup/flyte/mongo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -89,6 +90,173 @@ func TestFindNew_ShouldReturnOldestActionWhichCanBeHandledByPack(t *testing.T) {
 	assert.Equal(t, want, *got)
 }
 
+func TestFindAllNew_ShouldReturnAllNewActionsWhichCanBeHandledByPackOldestFirst(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Now()
+	mongoT.Insert(t, mongo.ActionCollectionId, newPackActionT("packA", "1", "actionA", stateNew, now))
+	mongoT.Insert(t, mongo.ActionCollectionId, newPackActionT("packA", "2", "actionA", stateNew, now.Add(-1*time.Hour)))
+	mongoT.Insert(t, mongo.ActionCollectionId, newPackActionT("packA", "3", "actionA", statePending, now.Add(-2*time.Hour)))
+	otherLabels := newPackActionT("packA", "4", "actionA", stateNew, now.Add(-3*time.Hour))
+	otherLabels.PackLabels = map[string]string{"env": "prod"}
+	require.NoError(t, actionRepo.Add(otherLabels))
+	mongoT.Insert(t, mongo.ActionCollectionId, newPackActionT("packB", "5", "actionA", stateNew, now.Add(-4*time.Hour)))
+
+	got, err := actionRepo.FindAllNew(Pack{Name: "packA", Labels: map[string]string{"env": "dev"}}, "", 0)
+	require.NoError(t, err)
+
+	require.Len(t, got, 2)
+	assert.Equal(t, "2", got[0].Id)
+	assert.Equal(t, "1", got[1].Id)
+}
+
+func TestFindAllNew_ShouldReturnHigherPriorityActionsFirst(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Now()
+	mongoT.Insert(t, mongo.ActionCollectionId, newPackActionT("packA", "1", "actionA", stateNew, now.Add(-1*time.Hour)))
+	urgent := newPackActionT("packA", "2", "actionA", stateNew, now)
+	urgent.Priority = 10
+	mongoT.Insert(t, mongo.ActionCollectionId, urgent)
+	important := newPackActionT("packA", "3", "actionA", stateNew, now.Add(-1*time.Minute))
+	important.Priority = 5
+	mongoT.Insert(t, mongo.ActionCollectionId, important)
+
+	got, err := actionRepo.FindAllNew(Pack{Name: "packA"}, "", 0)
+	require.NoError(t, err)
+
+	require.Len(t, got, 3)
+	assert.Equal(t, []string{"2", "3", "1"}, []string{got[0].Id, got[1].Id, got[2].Id})
+}
+
+func TestFindNew_ShouldReturnActionWhoseLabelsAreSubsetOfPackLabels(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Now()
+	otherEnv := newPackActionT("packA", "1", "actionA", stateNew, now.Add(-3*time.Hour))
+	otherEnv.PackLabels = map[string]string{"env": "prod"}
+	require.NoError(t, actionRepo.Add(otherEnv))
+	moreLabels := newPackActionT("packA", "2", "actionA", stateNew, now.Add(-2*time.Hour))
+	moreLabels.PackLabels = map[string]string{"env": "dev", "region": "eu", "zone": "a"}
+	require.NoError(t, actionRepo.Add(moreLabels))
+	want := newPackActionT("packA", "3", "actionA", stateNew, now.Add(-1*time.Hour))
+	want.PackLabels = map[string]string{"env": "dev", "region": "eu"}
+	require.NoError(t, actionRepo.Add(want))
+
+	got, err := actionRepo.FindNew(Pack{Name: "packA", Labels: map[string]string{"env": "dev", "region": "eu", "team": "x"}}, "")
+	require.NoError(t, err)
+
+	require.NotNil(t, got)
+	assert.Equal(t, "3", got.Id)
+}
+
+func TestFindNew_ShouldNotReturnLabelledActionToPackWithoutLabels(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	labelled := newPackActionT("packA", "1", "actionA", stateNew, time.Now())
+	labelled.PackLabels = map[string]string{"env": "dev"}
+	require.NoError(t, actionRepo.Add(labelled))
+
+	got, err := actionRepo.FindNew(Pack{Name: "packA"}, "")
+	require.NoError(t, err)
+
+	assert.Nil(t, got)
+}
+
+func TestMigrateActionPackLabels_ShouldAddPackLabelListToExistingActions(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	legacy := newPackActionT("packA", "1", "actionA", stateNew, time.Now())
+	legacy.PackLabels = map[string]string{"env": "prod"}
+	mongoT.Insert(t, mongo.ActionCollectionId, legacy)
+	unlabelled := newPackActionT("packA", "2", "actionA", stateNew, time.Now())
+	mongoT.Insert(t, mongo.ActionCollectionId, unlabelled)
+	s := mongoT.GetSession().Copy()
+	defer s.Close()
+	_, err := s.DB(mongo.DbName).C(mongo.ActionCollectionId).UpdateAll(nil, bson.M{"$unset": bson.M{"packLabelKey": ""}})
+	require.NoError(t, err)
+
+	MigrateActionPackLabels()
+
+	var got Action
+	mongoT.FindOneT(t, mongo.ActionCollectionId, bson.M{"_id": "1"}, &got)
+	assert.Equal(t, []string{"env=prod"}, got.PackLabelList)
+	assert.Equal(t, "env=prod", got.PackLabelKey)
+
+	found, err := actionRepo.FindNew(Pack{Name: "packA", Labels: map[string]string{"env": "dev"}}, "")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "2", found.Id)
+}
+
+func TestFindNew_ShouldReturnActionWhoseLabelsAreSubsetOfPackLabels_WhenPackHasManyLabels(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	labels := map[string]string{}
+	for i := 0; i <= maxIndexedPackLabels; i++ {
+		labels[fmt.Sprintf("label%d", i)] = "x"
+	}
+	want := newPackActionT("packA", "1", "actionA", stateNew, time.Now())
+	want.PackLabels = map[string]string{"label0": "x", "label5": "x"}
+	require.NoError(t, actionRepo.Add(want))
+
+	got, err := actionRepo.FindNew(Pack{Name: "packA", Labels: labels}, "")
+	require.NoError(t, err)
+
+	require.NotNil(t, got)
+	assert.Equal(t, "1", got.Id)
+}
+
+// go test -tags integration -run none -bench FindNew ./execution
+func BenchmarkFindNew_With50kBacklogForOtherLabels(b *testing.B) {
+
+	s := mongoT.GetSession().Copy()
+	defer s.Close()
+	require.NoError(b, s.DB(mongo.DbName).DropDatabase())
+	mongo.InitSession(mongoT.GetUrl(), ttl)
+
+	now := time.Now()
+	bulk := s.DB(mongo.DbName).C(mongo.ActionCollectionId).Bulk()
+	for i := 0; i < 50000; i++ {
+		a := newPackActionT("packA", bson.NewObjectId().Hex(), "actionA", stateNew, now.Add(time.Duration(i-50000)*time.Second))
+		a.PackLabels = map[string]string{"env": "prod"}
+		a.PackLabelList = packLabelList(a.PackLabels)
+		a.PackLabelKey = packLabelKey(a.PackLabelList)
+		bulk.Insert(a)
+	}
+	_, err := bulk.Run()
+	require.NoError(b, err)
+
+	want := newPackActionT("packA", "dev", "actionA", stateNew, now)
+	want.PackLabels = map[string]string{"env": "dev"}
+	require.NoError(b, actionRepo.Add(want))
+
+	pack := Pack{Name: "packA", Labels: map[string]string{"env": "dev"}}
+
+	// the backlog for the other labels must not be scanned to find the action
+	var explain struct {
+		ExecutionStats struct {
+			TotalKeysExamined int `bson:"totalKeysExamined"`
+			TotalDocsExamined int `bson:"totalDocsExamined"`
+		} `bson:"executionStats"`
+	}
+	require.NoError(b, findNewQuery(s, pack, "", 1).Explain(&explain))
+	keys, docs := explain.ExecutionStats.TotalKeysExamined, explain.ExecutionStats.TotalDocsExamined
+	if docs > 10 || keys > 10 {
+		b.Fatalf("finding the action examined keys=%d docs=%d", keys, docs)
+	}
+	b.ReportMetric(float64(keys), "keys/op")
+	b.ReportMetric(float64(docs), "docs/op")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		got, err := actionRepo.FindNew(pack, "")
+		if err != nil || got == nil || got.Id != "dev" {
+			b.Fatalf("unexpected action=%v err=%v", got, err)
+		}
+	}
+}
+
 func TestFindNew_ShouldReturnNilWhenThereIsNoNewActionsForAPack(t *testing.T) {
 
 	mongoT.DropDatabase(t)
@@ -198,6 +366,24 @@ func TestFindCorrelated_ShouldReturnAllCorrelatedActions(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestFindCorrelated_ShouldReturnActionsInOrderOfCreation(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	for _, id := range []string{"5c0a7f2e", "5c0a7f1e", "5c0a7f3e"} {
+		action := newActionT(id, "actionA", stateFatal, time.Now())
+		action.CorrelationId = "correlated"
+		mongoT.Insert(t, mongo.ActionCollectionId, action)
+	}
+
+	got, err := actionRepo.FindCorrelated("correlated")
+	require.NoError(t, err)
+
+	require.Len(t, got, 3)
+	assert.Equal(t, "5c0a7f1e", got[0].Id)
+	assert.Equal(t, "5c0a7f2e", got[1].Id)
+	assert.Equal(t, "5c0a7f3e", got[2].Id)
+}
+
 func TestFindCorrelated_ShouldReturnNilIfThereAreNoCorrelatedActions(t *testing.T) {
 
 	mongoT.DropDatabase(t)
@@ -266,6 +452,46 @@ func TestUpdate_ShouldFailToFindAndUpdateActionWhenPreviousStateIsIncorrect(t *t
 	assert.Equal(t, mgo.ErrNotFound, err)
 }
 
+func TestUpdate_ShouldFailToUpdateActionWhoseLeaseHasChanged(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	action := newActionT("1", "actionA", statePending, now)
+	action.LeaseExpiresAt = now.Add(time.Minute)
+	action.LeaseToken = "token"
+	mongoT.Insert(t, mongo.ActionCollectionId, action)
+
+	// a heartbeat extended the lease after the action has been read
+	extended := action
+	extended.prevState = action.State
+	extended.guardLease()
+	extended.LeaseExpiresAt = now.Add(2 * time.Minute)
+	require.NoError(t, actionRepo.Update(extended))
+
+	action.prevState = action.State
+	action.guardLease()
+	action.State.Value = stateNew
+	assert.Equal(t, mgo.ErrNotFound, actionRepo.Update(action))
+
+	var got Action
+	mongoT.FindOneT(t, mongo.ActionCollectionId, bson.M{"_id": "1"}, &got)
+	assert.Equal(t, statePending, got.State.Value)
+}
+
+func TestRequeue_ShouldRequeueActionTakenWithoutLease(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	action := newActionT("1", "actionA", statePending, time.Now())
+	mongoT.Insert(t, mongo.ActionCollectionId, action)
+
+	require.NoError(t, action.requeue())
+
+	var got Action
+	mongoT.FindOneT(t, mongo.ActionCollectionId, bson.M{"_id": "1"}, &got)
+	assert.Equal(t, stateNew, got.State.Value)
+	assert.Equal(t, 1, got.Redeliveries)
+}
+
 func TestUpdate_ShouldFailToFindAndUpdateActionForIncorrectId(t *testing.T) {
 
 	mongoT.DropDatabase(t)
@@ -278,6 +504,204 @@ func TestUpdate_ShouldFailToFindAndUpdateActionForIncorrectId(t *testing.T) {
 	assert.Equal(t, mgo.ErrNotFound, err)
 }
 
+func TestFindNew_ShouldSkipActionsWaitingForRetryDelay(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	waiting := newPackActionT("packA", "1", "actionA", stateNew, time.Now().Add(-1*time.Hour))
+	waiting.NotBefore = time.Now().Add(1 * time.Hour).Round(time.Millisecond)
+	mongoT.Insert(t, mongo.ActionCollectionId, waiting)
+	want := newPackActionT("packA", "2", "actionA", stateNew, time.Now())
+	want.NotBefore = time.Now().Add(-1 * time.Minute).Round(time.Millisecond)
+	mongoT.Insert(t, mongo.ActionCollectionId, want)
+
+	got, err := actionRepo.FindNew(Pack{Name: "packA"}, "")
+	require.NoError(t, err)
+
+	require.NotNil(t, got)
+	assert.Equal(t, "2", got.Id)
+}
+
+func TestFindExpired_ShouldReturnNewAndPendingActionsPastTheirDeadline(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	notTaken := newActionT("1", "actionA", stateNew, now)
+	notTaken.ExpiresAt = now.Add(-1 * time.Minute)
+	mongoT.Insert(t, mongo.ActionCollectionId, notTaken)
+
+	notCompleted := newActionT("2", "actionA", statePending, now)
+	notCompleted.ExpiresAt = now
+	mongoT.Insert(t, mongo.ActionCollectionId, notCompleted)
+
+	notExpired := newActionT("3", "actionA", stateNew, now)
+	notExpired.ExpiresAt = now.Add(1 * time.Minute)
+	mongoT.Insert(t, mongo.ActionCollectionId, notExpired)
+
+	withoutTimeout := newActionT("4", "actionA", stateNew, now.Add(-1*time.Hour))
+	mongoT.Insert(t, mongo.ActionCollectionId, withoutTimeout)
+
+	finished := newActionT("5", "actionA", stateSuccess, now)
+	finished.ExpiresAt = now.Add(-1 * time.Minute)
+	mongoT.Insert(t, mongo.ActionCollectionId, finished)
+
+	got, err := actionRepo.FindExpired(now)
+	require.NoError(t, err)
+
+	require.Len(t, got, 2)
+	var ids []string
+	for _, a := range got {
+		ids = append(ids, a.Id)
+	}
+	assert.ElementsMatch(t, []string{"1", "2"}, ids)
+}
+
+func TestFindLeaseExpired_ShouldReturnPendingActionsPastTheirLease(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	expired := newActionT("1", "actionA", statePending, now)
+	expired.LeaseExpiresAt = now.Add(-1 * time.Minute)
+	mongoT.Insert(t, mongo.ActionCollectionId, expired)
+
+	notExpired := newActionT("2", "actionA", statePending, now)
+	notExpired.LeaseExpiresAt = now.Add(1 * time.Minute)
+	mongoT.Insert(t, mongo.ActionCollectionId, notExpired)
+
+	withoutLease := newActionT("3", "actionA", statePending, now.Add(-1*time.Hour))
+	mongoT.Insert(t, mongo.ActionCollectionId, withoutLease)
+
+	finished := newActionT("4", "actionA", stateSuccess, now)
+	finished.LeaseExpiresAt = now.Add(-1 * time.Minute)
+	mongoT.Insert(t, mongo.ActionCollectionId, finished)
+
+	got, err := actionRepo.FindLeaseExpired(now)
+	require.NoError(t, err)
+
+	require.Len(t, got, 1)
+	assert.Equal(t, "1", got[0].Id)
+}
+
+func TestFindBuiltinDue_ShouldReturnUnfinishedBuiltinActionsPastTheirWakeTime(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	due := newPackActionT(builtinPackName, "1", waitCommandName, statePending, now)
+	due.WakeAt = now.Add(-1 * time.Minute)
+	mongoT.Insert(t, mongo.ActionCollectionId, due)
+
+	retried := newPackActionT(builtinPackName, "2", waitCommandName, stateNew, now)
+	retried.WakeAt = now
+	mongoT.Insert(t, mongo.ActionCollectionId, retried)
+
+	notDue := newPackActionT(builtinPackName, "3", waitCommandName, statePending, now)
+	notDue.WakeAt = now.Add(1 * time.Minute)
+	mongoT.Insert(t, mongo.ActionCollectionId, notDue)
+
+	finished := newPackActionT(builtinPackName, "4", waitCommandName, stateSuccess, now)
+	finished.WakeAt = now.Add(-1 * time.Minute)
+	mongoT.Insert(t, mongo.ActionCollectionId, finished)
+
+	otherPack := newPackActionT("packA", "5", waitCommandName, statePending, now)
+	otherPack.WakeAt = now.Add(-1 * time.Minute)
+	mongoT.Insert(t, mongo.ActionCollectionId, otherPack)
+
+	got, err := actionRepo.FindBuiltinDue(now)
+	require.NoError(t, err)
+
+	require.Len(t, got, 2)
+	assert.ElementsMatch(t, []string{"1", "2"}, []string{got[0].Id, got[1].Id})
+}
+
+func TestFindQueued_ShouldReturnHighestPriorityOldestActionOfTheQueue(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	insertQueuedActionT(t, "1", "page", queueCommand, now.Add(-2*time.Minute), 0)
+	insertQueuedActionT(t, "2", "page", queueCommand, now.Add(-1*time.Minute), 10)
+	insertQueuedActionT(t, "3", "page", queueCommand, now.Add(-3*time.Minute), 10)
+	insertQueuedActionT(t, "4", "notify", queueCommand, now.Add(-4*time.Minute), 10)
+	insertQueuedActionT(t, "5", "page", queueExecution, now.Add(-4*time.Minute), 10)
+
+	got, err := actionRepo.FindQueued("alerts", "page", queueCommand)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "3", got.Id)
+
+	got, err = actionRepo.FindQueued("alerts", "", queueExecution)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "5", got.Id)
+
+	got, err = actionRepo.FindQueued("other", "", queueExecution)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestCountInFlight_ShouldCountNewAndPendingActionsOfTheStep(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	for i, state := range []string{stateNew, statePending, stateSuccess, stateQueued} {
+		a := newActionT(fmt.Sprint(i), "actionA", state, now)
+		a.FlowName = "alerts"
+		a.StepId = "page"
+		mongoT.Insert(t, mongo.ActionCollectionId, a)
+	}
+	otherStep := newActionT("4", "actionA", stateNew, now)
+	otherStep.FlowName = "alerts"
+	otherStep.StepId = "notify"
+	mongoT.Insert(t, mongo.ActionCollectionId, otherStep)
+	forEach := newActionT("5", "actionA", statePending, now)
+	forEach.FlowName = "alerts"
+	forEach.StepId = "page"
+	forEach.ForEach = &ForEach{Items: 2}
+	mongoT.Insert(t, mongo.ActionCollectionId, forEach)
+
+	got, err := actionRepo.CountInFlight("alerts", "page")
+	require.NoError(t, err)
+	assert.Equal(t, 2, got)
+}
+
+func TestCountRunningExecutions_ShouldCountExecutionsWithActionsInFlightOrWaitingForTheirCommand(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	running := map[string]string{"1": "execA", "2": "execA", "3": "execB"}
+	for id, correlationId := range running {
+		a := newActionT(id, "actionA", statePending, now)
+		a.FlowName = "alerts"
+		a.CorrelationId = correlationId
+		mongoT.Insert(t, mongo.ActionCollectionId, a)
+	}
+	insertQueuedActionT(t, "4", "page", queueCommand, now, 0)
+	insertQueuedActionT(t, "5", "page", queueExecution, now, 0)
+	finished := newActionT("6", "actionA", stateSuccess, now)
+	finished.FlowName = "alerts"
+	finished.CorrelationId = "execE"
+	mongoT.Insert(t, mongo.ActionCollectionId, finished)
+
+	got, err := actionRepo.CountRunningExecutions("alerts")
+	require.NoError(t, err)
+	assert.Equal(t, 3, got)
+}
+
+// queued action of the "alerts" flow, each in its own flow execution
+func insertQueuedActionT(t *testing.T, id, stepId, queue string, stateTime time.Time, priority int) {
+	a := newActionT(id, "actionA", stateQueued, stateTime)
+	a.FlowName = "alerts"
+	a.StepId = stepId
+	a.CorrelationId = "exec" + id
+	a.Queue = queue
+	a.Priority = priority
+	mongoT.Insert(t, mongo.ActionCollectionId, a)
+}
+
 func newActionT(id, name, state string, stateTime time.Time) Action {
 	return Action{
 		Id:    id,
@@ -0,0 +1,58 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPackLabelList_ShouldReturnSortedKeyValuePairs(t *testing.T) {
+
+	got := packLabelList(map[string]string{"region": "eu", "env": "dev"})
+
+	assert.Equal(t, []string{"env=dev", "region=eu"}, got)
+}
+
+func TestPackLabelList_ShouldEscapeKeysAndValues(t *testing.T) {
+
+	got := packLabelList(map[string]string{"a=b": "c", "a": "b=c"})
+
+	assert.Equal(t, []string{"a%3Db=c", "a=b%3Dc"}, got)
+}
+
+func TestPackLabelList_ShouldReturnEmptyListForNoLabels(t *testing.T) {
+
+	assert.Empty(t, packLabelList(nil))
+}
+
+func TestPackLabelKeys_ShouldReturnKeysOfAllLabelCombinations(t *testing.T) {
+
+	got := packLabelKeys([]string{"env=dev", "region=eu", "zone=a"})
+
+	assert.ElementsMatch(t, []string{"", "env=dev", "region=eu", "zone=a", "env=dev,region=eu", "env=dev,zone=a",
+		"region=eu,zone=a", "env=dev,region=eu,zone=a"}, got)
+}
+
+func TestPackLabelKeys_ShouldMatchKeyOfActionLabels(t *testing.T) {
+
+	pack := packLabelList(map[string]string{"region": "eu", "env": "dev", "team": "x,y"})
+	action := packLabelList(map[string]string{"team": "x,y", "env": "dev"})
+
+	assert.Contains(t, packLabelKeys(pack), packLabelKey(action))
+	assert.NotContains(t, packLabelKeys(pack), packLabelKey(packLabelList(map[string]string{"team": "x"})))
+}
@@ -18,24 +18,63 @@ package execution
 
 import (
 	"github.com/ExpediaGroup/flyte/mongo"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 type auditMgoRepo struct{}
 
-func (auditMgoRepo) Add(action Action) error {
+func (r auditMgoRepo) Add(action Action) error {
 
 	s := mongo.GetSession()
 	defer s.Close()
 
-	return s.DB(mongo.DbName).C(mongo.AuditCollectionId).Insert(action)
+	if err := s.DB(mongo.DbName).C(mongo.AuditCollectionId).Insert(action); err != nil {
+		return err
+	}
+	return r.saveRecord(s, action, nil)
 }
 
-func (auditMgoRepo) Update(action Action) error {
+func (r auditMgoRepo) Update(action Action) error {
 
 	s := mongo.GetSession()
 	defer s.Close()
 
-	return s.DB(mongo.DbName).C(mongo.AuditCollectionId).
-		Update(bson.M{"_id": action.Id, "state.value": action.prevState.Value}, action)
+	// the audit before the update is returned, the record is changed by the difference
+	var prev Action
+	_, err := s.DB(mongo.DbName).C(mongo.AuditCollectionId).
+		Find(bson.M{"_id": action.Id, "state.value": action.prevState.Value}).
+		Apply(mgo.Change{Update: action}, &prev)
+	if err != nil {
+		return err
+	}
+	return r.saveRecord(s, action, &prev)
+}
+
+// Applies the change of the action to the record of its flow execution, then sets the status. Only the latest change
+// sets it, it has seen all the changes made before it.
+func (auditMgoRepo) saveRecord(s *mgo.Session, action Action, prev *Action) error {
+
+	c := s.DB(mongo.DbName).C(mongo.ExecutionCollectionId)
+	change := mgo.Change{Update: recordUpdate(action, prev), Upsert: true, ReturnNew: true}
+	var record Record
+	_, err := c.FindId(action.CorrelationId).Apply(change, &record)
+	if mgo.IsDup(err) {
+		// the record has been inserted by a concurrent change, it is updated now
+		_, err = c.FindId(action.CorrelationId).Apply(change, &record)
+	}
+	if err != nil {
+		return err
+	}
+
+	record.setStatus()
+	set := bson.M{"$set": bson.M{"status": record.Status, "terminalStepId": record.TerminalStepId}}
+	if record.TerminalStepId == "" {
+		set = bson.M{"$set": bson.M{"status": record.Status}, "$unset": bson.M{"terminalStepId": ""}}
+	}
+	err = c.Update(bson.M{"_id": record.CorrelationId, "version": record.Version}, set)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
 }
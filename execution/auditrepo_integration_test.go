@@ -101,3 +101,60 @@ func TestAuditUpdate_ShouldFailToFindAndUpdateActionForIncorrectId(t *testing.T)
 	assert.Error(t, err)
 	assert.Equal(t, mgo.ErrNotFound, err)
 }
+
+func TestAuditUpdate_ShouldSaveRecordOfFlowExecution(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	start := time.Now().Add(-time.Minute).Round(time.Millisecond)
+	action := Action{Id: "1", StepId: "stepA", CorrelationId: "flowA", FlowName: "deploy", FlowUUID: "deployV1", State: State{Value: stateNew, Time: start}}
+	action.States = []State{action.State}
+	require.NoError(t, auditRepo.Add(action))
+
+	action.setState(stateSuccess)
+	action.State.Time = action.State.Time.Round(time.Millisecond)
+	require.NoError(t, auditRepo.Update(action))
+
+	var got Record
+	mongoT.FindOneT(t, mongo.ExecutionCollectionId, bson.M{"_id": "flowA"}, &got)
+	assert.Equal(t, "deploy", got.FlowName)
+	assert.Equal(t, "deployV1", got.FlowUUID)
+	assert.Equal(t, executionSucceeded, got.Status)
+	assert.Equal(t, "stepA", got.TerminalStepId)
+	assert.True(t, start.Equal(got.StartedAt))
+	assert.True(t, action.State.Time.Equal(got.LastActivityAt))
+}
+
+func TestAuditUpdate_ShouldKeepRecordOverAllActionsOfFlowExecution(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	start := time.Now().Add(-time.Hour).Round(time.Millisecond)
+	first := Action{Id: "1", StepId: "check", CorrelationId: "flowA", State: State{Value: stateNew, Time: start}}
+	first.States = []State{first.State}
+	require.NoError(t, auditRepo.Add(first))
+	first.setState(stateSuccess)
+	first.State.Time = start.Add(time.Minute)
+	require.NoError(t, auditRepo.Update(first))
+
+	// the step is repeated, the action of the repetition replaces the first one in the flow
+	repeated := Action{Id: "2", StepId: "check", CorrelationId: "flowA", State: State{Value: stateNew, Time: start.Add(2 * time.Minute)}}
+	repeated.States = []State{repeated.State}
+	require.NoError(t, auditRepo.Add(repeated))
+
+	var got Record
+	mongoT.FindOneT(t, mongo.ExecutionCollectionId, bson.M{"_id": "flowA"}, &got)
+	assert.Equal(t, executionRunning, got.Status)
+	assert.Empty(t, got.TerminalStepId)
+	assert.True(t, start.Equal(got.StartedAt))
+	assert.True(t, start.Add(2*time.Minute).Equal(got.LastActivityAt))
+
+	repeated.setState(stateFatal)
+	repeated.State.Time = start.Add(3 * time.Minute)
+	require.NoError(t, auditRepo.Update(repeated))
+
+	mongoT.FindOneT(t, mongo.ExecutionCollectionId, bson.M{"_id": "flowA"}, &got)
+	assert.Equal(t, executionFailed, got.Status)
+	assert.Equal(t, "check", got.TerminalStepId)
+	assert.Equal(t, 0, got.Unfinished)
+	assert.True(t, start.Equal(got.StartedAt))
+	assert.True(t, start.Add(3*time.Minute).Equal(got.LastActivityAt))
+}
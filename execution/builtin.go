@@ -0,0 +1,240 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	encodingjson "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ExpediaGroup/flyte/flow"
+	"github.com/ExpediaGroup/flyte/json"
+	"github.com/jasonlvhit/gocron"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// flyte sends events and executes commands of this pack itself, no pack can be registered under this name
+const builtinPackName = flow.BuiltinPackName
+
+const (
+	waitCommandName        = "Wait"
+	waitedEventName        = "Waited"
+	httpRequestCommandName = "HttpRequest"
+	httpResponseEventName  = "HttpResponse"
+	httpErrorEventName     = "HttpError"
+)
+
+// how long a built-in action can be running before it is assumed to be lost with the instance running it
+const builtinRunTimeout = 10 * time.Minute
+
+func (a Action) isBuiltin() bool {
+	return a.PackName == builtinPackName
+}
+
+// Built-in actions are run by flyte itself once they are due. The due time is stored on the action,
+// so it survives a restart and the action is run by any flyte instance.
+func (a *Action) scheduleBuiltin() {
+
+	a.WakeAt = a.State.Time
+	if a.Name == waitCommandName {
+		// invalid input is reported when the command runs, straight away. The wait counts from when the action was
+		// created, the same as when it runs, even if it has been queued
+		if until, err := waitUntil(a.Input, a.createdAt()); err == nil {
+			a.WakeAt = until
+		}
+	}
+}
+
+func (a Action) createdAt() time.Time {
+	if len(a.States) == 0 {
+		return a.State.Time
+	}
+	return a.States[0].Time
+}
+
+// takes a due built-in action, only one flyte instance succeeds in taking it
+func (a *Action) takeBuiltin() error {
+
+	if a.State.Value != stateNew {
+		return fmt.Errorf("action is not in %s state, cannot set to %s", stateNew, statePending)
+	}
+	a.setState(statePending)
+	a.ExpiresAt = a.Timeout.completeDeadline(a.State.Time)
+	a.WakeAt = a.State.Time.Add(builtinRunTimeout)
+	return a.update()
+}
+
+// runs the command of a built-in action and returns its result, unless the action is completed later
+func (a *Action) runBuiltin() (result Event, completed bool) {
+
+	switch a.Name {
+	case waitCommandName:
+		until, err := waitUntil(a.Input, a.createdAt())
+		if err != nil {
+			return builtinFatalEvent(err), true
+		}
+		return builtinEvent(waitedEventName, map[string]interface{}{"until": until.UTC().Format(time.RFC3339)}), true
+	case httpRequestCommandName:
+		return httpRequest(a.Input), true
+	case datastorePutCommandName:
+		return datastorePut(a.Input), true
+	case datastoreDeleteCommandName:
+		return datastoreDelete(a.Input), true
+	case startSubflowCommandName:
+		return a.startSubflow()
+	case approvalCommandName:
+		return a.awaitApproval()
+	default:
+		return builtinFatalEvent(fmt.Errorf("unknown command=%s of pack=%s", a.Name, builtinPackName)), true
+	}
+}
+
+type waitInput struct {
+	Duration string `json:"duration"`
+	Until    string `json:"until"`
+}
+
+// Wait input has either a duration in go format, e.g. "10m", or an RFC3339 time to wait until.
+func waitUntil(input json.Json, from time.Time) (time.Time, error) {
+
+	var in waitInput
+	if err := decodeBuiltinInput(input, &in); err != nil {
+		return time.Time{}, err
+	}
+
+	switch {
+	case in.Duration != "" && in.Until != "":
+		return time.Time{}, errors.New("wait input has both duration and until")
+	case in.Duration != "":
+		d, err := time.ParseDuration(in.Duration)
+		if err != nil || d < 0 {
+			return time.Time{}, fmt.Errorf("invalid wait duration=%q", in.Duration)
+		}
+		return from.Add(d), nil
+	case in.Until != "":
+		until, err := time.Parse(time.RFC3339, in.Until)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid wait until=%q: %v", in.Until, err)
+		}
+		return until.UTC(), nil
+	default:
+		return time.Time{}, errors.New("wait input has neither duration nor until")
+	}
+}
+
+// input read from mongo is a bson map, so it is converted through json
+func decodeBuiltinInput(input json.Json, v interface{}) error {
+	b, err := encodingjson.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("invalid input=%v: %v", input, err)
+	}
+	if err := encodingjson.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("invalid input=%s: %v", b, err)
+	}
+	return nil
+}
+
+func builtinEvent(name string, payload json.Json) Event {
+	now := currentTime()
+	return Event{
+		Name:       name,
+		Pack:       Pack{Name: builtinPackName},
+		Payload:    payload,
+		CreatedAt:  now,
+		ReceivedAt: now,
+	}
+}
+
+func builtinFatalEvent(err error) Event {
+	return builtinEvent(fatalEventName, map[string]interface{}{"error": err.Error()})
+}
+
+// Checks every interval for built-in actions that are due and runs them.
+func ScheduleBuiltinCommands(intervalInSeconds int) (*gocron.Scheduler, chan bool) {
+	s := gocron.NewScheduler()
+	s.Every(uint64(intervalInSeconds)).Seconds().Do(runBuiltinActions)
+	sc := s.Start()
+	return s, sc
+}
+
+func runBuiltinActions() {
+
+	actions, err := actionRepo.FindBuiltinDue(currentTime())
+	if err != nil {
+		log.Err(err).Msg("Error finding due built-in actions")
+		return
+	}
+
+	for _, a := range actions {
+		if a.State.Value == statePending {
+			// the instance running the action has stopped before completing it, so it is run again
+			if err := a.requeue(); err != nil {
+				log.Err(err).Msgf("Error re-queueing built-in actionId=%s", a.Id)
+			}
+			continue
+		}
+
+		// the check does not wait for the actions, a long running one would hold up the next checks
+		runBuiltinNow(a)
+	}
+}
+
+// runs a built-in action on its own goroutine, also used as soon as an action is created instead of waiting for the next check
+var runBuiltinNow func(a Action)
+
+func init() {
+	// not initialised in the declaration, a sub-flow started by a built-in action adds actions which refers back to it
+	runBuiltinNow = runBuiltinNowFn
+}
+
+func runBuiltinNowFn(a Action) {
+	go runBuiltinAction(a)
+}
+
+func runBuiltinAction(a Action) {
+
+	// another flyte instance may have taken or cancelled the action in the meantime, in which case take fails
+	if err := a.takeBuiltin(); err != nil {
+		log.Debug().Msgf("Cannot take built-in actionId=%s: %v", a.Id, err)
+		return
+	}
+
+	result, completed := a.runBuiltin()
+	if !completed {
+		return
+	}
+	if err := a.finish(result); err != nil {
+		log.Err(err).Msgf("Error completing built-in actionId=%s", a.Id)
+		return
+	}
+
+	log.Info().
+		Str("ActionId", a.Id).
+		Str("CorrelationId", a.CorrelationId).
+		Str("FlowName", a.FlowName).
+		Str("ActionName", a.Name).
+		Str("StepId", a.StepId).
+		Str("State", a.State.Value).
+		Str("ResultEvent", a.Result.Name).
+		Int("Attempt", a.Attempt).
+		Msg("Built-in action completed")
+
+	if a.isRetrying() {
+		return
+	}
+	flowSvc.HandleAction(a)
+}
@@ -0,0 +1,317 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/ExpediaGroup/flyte/flow"
+	"github.com/ExpediaGroup/flyte/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBuiltinCommands_ShouldBeTheOnesFlowsCanUse(t *testing.T) {
+
+	commands := map[string]bool{}
+	for _, name := range []string{waitCommandName, httpRequestCommandName, datastorePutCommandName,
+		datastoreDeleteCommandName, startSubflowCommandName, approvalCommandName} {
+		commands[name] = true
+	}
+
+	assert.Equal(t, flow.BuiltinCommandNames, commands)
+}
+
+func TestWaitUntil_ShouldAcceptDurationOrUntilTime(t *testing.T) {
+
+	from := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	until, err := waitUntil(map[string]interface{}{"duration": "10m"}, from)
+	require.NoError(t, err)
+	assert.Equal(t, from.Add(10*time.Minute), until)
+
+	// input read from mongo
+	until, err = waitUntil(bson.M{"until": "2018-01-01T15:30:00+02:00"}, from)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 1, 1, 13, 30, 0, 0, time.UTC), until)
+}
+
+func TestWaitUntil_ShouldReturnErrorForInvalidInput(t *testing.T) {
+
+	cases := map[string]interface{}{
+		"no input":       nil,
+		"not an object":  "10m",
+		"neither":        map[string]interface{}{},
+		"both":           map[string]interface{}{"duration": "10m", "until": "2018-01-01T15:30:00Z"},
+		"bad duration":   map[string]interface{}{"duration": "ten minutes"},
+		"negative":       map[string]interface{}{"duration": "-10m"},
+		"bad until time": map[string]interface{}{"until": "tomorrow"},
+	}
+	for name, input := range cases {
+		_, err := waitUntil(input, time.Now())
+
+		assert.Error(t, err, name)
+	}
+}
+
+func TestFlowHandleEvent_ShouldScheduleWaitActionForItsEnd(t *testing.T) {
+
+	//Given
+	defer resetStepExecutor()
+	stepExecutor = func(s Step, e Event, ctx map[string]string) (*Action, error) {
+		a := newBuiltinActionT("wait", waitCommandName, map[string]interface{}{"duration": "10m"})
+		return &a, nil
+	}
+
+	defer resetActionRepo()
+	var added Action
+	actionRepo = mockActionRepo{add: func(a Action) error {
+		added = a
+		return nil
+	}}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+	defer resetRunBuiltinNow()
+	runBuiltinNow = func(a Action) {
+		t.Fatal("Should not get here")
+	}
+
+	flow := newFlowT(newStepT("wait", "MessageReceived", "Slack"))
+
+	//When
+	flow.HandleEvent(Event{Name: "MessageReceived", Pack: Pack{Name: "Slack"}})
+
+	//Then
+	assert.Equal(t, stateNew, added.State.Value)
+	assert.Equal(t, added.State.Time.Add(10*time.Minute), added.WakeAt)
+}
+
+func TestFlowHandleEvent_ShouldRunBuiltinActionStraightAwayWhenItIsDue(t *testing.T) {
+
+	//Given
+	defer resetStepExecutor()
+	stepExecutor = func(s Step, e Event, ctx map[string]string) (*Action, error) {
+		a := newBuiltinActionT("request", httpRequestCommandName, map[string]interface{}{"url": "http://example.com"})
+		return &a, nil
+	}
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{add: func(a Action) error { return nil }}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+	defer resetRunBuiltinNow()
+	var run []Action
+	runBuiltinNow = func(a Action) {
+		run = append(run, a)
+	}
+
+	flow := newFlowT(newStepT("request", "MessageReceived", "Slack"))
+
+	//When
+	flow.HandleEvent(Event{Name: "MessageReceived", Pack: Pack{Name: "Slack"}})
+
+	//Then
+	require.Len(t, run, 1)
+	assert.Equal(t, "request", run[0].Id)
+	assert.Equal(t, run[0].State.Time, run[0].WakeAt)
+}
+
+func TestRunBuiltinActions_ShouldRunDueActionsAndHandleThem(t *testing.T) {
+
+	//Given
+	defer resetCurrentTime()
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+
+	defer resetActionRepo()
+	var findTime time.Time
+	actionRepo = mockActionRepo{
+		findBuiltinDue: func(t time.Time) ([]Action, error) {
+			findTime = t
+			waited := newBuiltinActionT("waited", waitCommandName, bson.M{"duration": "1m"})
+			waited.States[0].Time = now.Add(-time.Minute)
+			return []Action{
+				waited,
+				newBuiltinActionT("invalid", waitCommandName, bson.M{"duration": "1 minute"}),
+				newBuiltinActionT("unknown", "Sleep", nil),
+			}, nil
+		},
+		update: func(a Action) error { return nil },
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	defer resetFlowService()
+	var mu sync.Mutex
+	handled := map[string]Action{}
+	flowSvc = mockFlowService{
+		handleAction: func(a Action) {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[a.Id] = a
+		},
+	}
+
+	defer resetRunBuiltinNow()
+	runBuiltinNow = runBuiltinAction
+
+	//When
+	runBuiltinActions()
+
+	//Then
+	assert.Equal(t, now, findTime)
+	require.Len(t, handled, 3)
+
+	waited := handled["waited"]
+	assert.Equal(t, []string{stateNew, statePending, stateSuccess}, stateValues(waited.States))
+	assert.Equal(t, waitedEventName, waited.Result.Name)
+	assert.Equal(t, Pack{Name: builtinPackName}, waited.Result.Pack)
+	assert.Equal(t, map[string]interface{}{"until": "2018-01-01T12:00:00Z"}, waited.Result.Payload)
+
+	invalid := handled["invalid"]
+	assert.Equal(t, stateFatal, invalid.State.Value)
+	assert.Equal(t, fatalEventName, invalid.Result.Name)
+	assert.Contains(t, invalid.Result.Payload.(map[string]interface{})["error"], "invalid wait duration")
+
+	unknown := handled["unknown"]
+	assert.Equal(t, stateFatal, unknown.State.Value)
+	assert.Equal(t, "unknown command=Sleep of pack=Flyte", unknown.Result.Payload.(map[string]interface{})["error"])
+}
+
+func TestRunBuiltinActions_ShouldNotWaitForActionsToFinish(t *testing.T) {
+
+	//Given
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findBuiltinDue: func(t time.Time) ([]Action, error) {
+			return []Action{newBuiltinActionT("waited", waitCommandName, bson.M{"duration": "0s"})}, nil
+		},
+		update: func(a Action) error { return nil },
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	defer resetFlowService()
+	release := make(chan struct{})
+	handled := make(chan Action, 1)
+	flowSvc = mockFlowService{
+		handleAction: func(a Action) {
+			<-release
+			handled <- a
+		},
+	}
+
+	//When
+	returned := make(chan struct{})
+	go func() {
+		runBuiltinActions()
+		close(returned)
+	}()
+
+	//Then
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("check has waited for the action to finish")
+	}
+	close(release)
+	select {
+	case a := <-handled:
+		assert.Equal(t, "waited", a.Id)
+	case <-time.After(time.Second):
+		t.Fatal("action has not been run")
+	}
+}
+
+func TestRunBuiltinActions_ShouldRequeueActionLeftPendingByStoppedInstance(t *testing.T) {
+
+	//Given
+	defer resetActionRepo()
+	var updated Action
+	actionRepo = mockActionRepo{
+		findBuiltinDue: func(t time.Time) ([]Action, error) {
+			a := newBuiltinActionT("lost", httpRequestCommandName, nil)
+			a.setState(statePending)
+			return []Action{a}, nil
+		},
+		update: func(a Action) error {
+			updated = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	defer resetFlowService()
+	flowSvc = mockFlowService{
+		handleAction: func(a Action) {
+			t.Fatal("Should not get here")
+		},
+	}
+
+	//When
+	runBuiltinActions()
+
+	//Then
+	assert.Equal(t, stateNew, updated.State.Value)
+	assert.Equal(t, 1, updated.Redeliveries)
+}
+
+func TestRunBuiltinAction_ShouldNotRunActionTakenByAnotherInstance(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		update: func(a Action) error {
+			return mgo.ErrNotFound
+		},
+	}
+
+	defer resetFlowService()
+	flowSvc = mockFlowService{
+		handleAction: func(a Action) {
+			t.Fatal("Should not get here")
+		},
+	}
+
+	runBuiltinAction(newBuiltinActionT("taken", httpRequestCommandName, nil))
+}
+
+func newBuiltinActionT(id, name string, input json.Json) Action {
+	state := State{Value: stateNew, Time: time.Now().UTC()}
+	return Action{
+		Id:       id,
+		Name:     name,
+		PackName: builtinPackName,
+		Input:    input,
+		State:    state,
+		States:   []State{state},
+		Attempt:  1,
+	}
+}
+
+func stateValues(states []State) []string {
+	var values []string
+	for _, s := range states {
+		values = append(values, s.Value)
+	}
+	return values
+}
+
+func resetRunBuiltinNow() { runBuiltinNow = runBuiltinNowFn }
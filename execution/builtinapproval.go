@@ -0,0 +1,207 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ExpediaGroup/flyte/auth"
+	"github.com/husobee/vestigo"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/mgo.v2"
+	"net/http"
+	"time"
+)
+
+const (
+	approvalCommandName = "Approval"
+	approvedEventName   = "Approved"
+	rejectedEventName   = "Rejected"
+
+	approveDecision = "approve"
+	rejectDecision  = "reject"
+)
+
+var (
+	NotApprovalErr        = errors.New("action is not an approval")
+	NotApproverErr        = errors.New("caller is not an approver")
+	ApprovalNotAwaitedErr = errors.New("action is not awaiting approval")
+)
+
+// Approvers are claims in the same format as the claims of the auth policy, e.g. {"groups": ["release-managers"]}.
+// An approval without approvers cannot be decided by anyone, it fails straight away.
+type approvalInput struct {
+	Approvers map[string][]string `json:"approvers"`
+}
+
+// Parks the action until it is approved or rejected, see DecideApproval. The action stays PENDING and is not
+// run again by any instance, it only times out with the complete timeout of the command.
+func (a *Action) awaitApproval() (Event, bool) {
+
+	var in approvalInput
+	if err := decodeBuiltinInput(a.Input, &in); err != nil {
+		return builtinFatalEvent(err), true
+	}
+	if len(in.Approvers) == 0 {
+		return builtinFatalEvent(errors.New("approval has no approvers")), true
+	}
+
+	a.WakeAt = time.Time{}
+	a.prevState = a.State
+	if err := a.update(); err != nil {
+		log.Err(err).Msgf("Error parking approval actionId=%s", a.Id)
+		return Event{}, false
+	}
+
+	log.Info().
+		Str("ActionId", a.Id).
+		Str("CorrelationId", a.CorrelationId).
+		Str("FlowName", a.FlowName).
+		Str("StepId", a.StepId).
+		Msg("Approval requested")
+	return Event{}, false
+}
+
+// the action has been taken and parked, so it is PENDING without a time to run it again
+func (a Action) isAwaitingApproval() bool {
+	return a.State.Value == statePending && a.WakeAt.IsZero()
+}
+
+type approvalRequest struct {
+	Decision string `json:"decision"`
+	Comment  string `json:"comment"`
+}
+
+type decision struct {
+	Approved bool
+	Comment  string
+	By       string
+}
+
+func DecideApproval(w http.ResponseWriter, r *http.Request) {
+
+	actionId := vestigo.Param(r, "actionId")
+
+	req := approvalRequest{}
+	if r.Body == nil {
+		log.Info().Msgf("Approval request for actionId=%s has no body", actionId)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Err(err).Msgf("Cannot read approval request for actionId=%s", actionId)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Decision != approveDecision && req.Decision != rejectDecision {
+		log.Info().Msgf("Invalid decision=%q for actionId=%s, it is either %s or %s", req.Decision, actionId, approveDecision, rejectDecision)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	d := decision{Approved: req.Decision == approveDecision, Comment: req.Comment, By: decidedBy(r)}
+	isApprover := func(approvers map[string][]string) bool {
+		return auth.ClaimsFulfilled(r, approvers)
+	}
+	action, err := decideApproval(actionId, d, isApprover)
+	if err != nil {
+		switch err {
+		case ActionNotFoundErr, NotApprovalErr:
+			log.Info().Msgf("Approval actionId=%s not found: %v", actionId, err)
+			w.WriteHeader(http.StatusNotFound)
+		case NotApproverErr:
+			log.Info().Msgf("User=%s is not an approver of actionId=%s", d.By, actionId)
+			w.WriteHeader(http.StatusForbidden)
+		case ActionCancelledErr:
+			log.Info().Msgf("Approval actionId=%s has been cancelled", actionId)
+			w.WriteHeader(http.StatusGone)
+		case ApprovalNotAwaitedErr, mgo.ErrNotFound:
+			log.Info().Msgf("Approval actionId=%s is not awaiting approval", actionId)
+			w.WriteHeader(http.StatusConflict)
+		default:
+			log.Err(err).Msgf("Error deciding approval actionId=%s", actionId)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Info().
+		Str("ActionId", action.Id).
+		Str("CorrelationId", action.CorrelationId).
+		Str("FlowName", action.FlowName).
+		Str("StepId", action.StepId).
+		Str("ResultEvent", action.Result.Name).
+		Str("DecidedBy", d.By).
+		Msg("Approval decided")
+
+	if !action.isRetrying() {
+		go flowSvc.HandleAction(*action)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func decidedBy(r *http.Request) string {
+	if user := auth.User(r); user != "" {
+		return user
+	}
+	return "anonymous"
+}
+
+var decideApproval = decideApprovalFn
+
+// completes the approval action with an Approved or Rejected event, unless the caller is not one of its approvers
+func decideApprovalFn(actionId string, d decision, isApprover func(approvers map[string][]string) bool) (*Action, error) {
+
+	action, err := actionRepo.Get(actionId)
+	if err != nil {
+		return nil, err
+	}
+	if !action.isBuiltin() || action.Name != approvalCommandName {
+		return nil, NotApprovalErr
+	}
+
+	var in approvalInput
+	if err := decodeBuiltinInput(action.Input, &in); err != nil {
+		return nil, err
+	}
+	// without approvers the claims are fulfilled by any caller, even one without a token
+	if len(in.Approvers) == 0 || !isApprover(in.Approvers) {
+		return nil, NotApproverErr
+	}
+
+	if action.State.Value == stateCancelled {
+		return nil, ActionCancelledErr
+	}
+	if !action.isAwaitingApproval() {
+		return nil, ApprovalNotAwaitedErr
+	}
+
+	// another request may have decided in the meantime, in which case the update finds no pending action
+	if err := action.finish(approvalResult(d)); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+func approvalResult(d decision) Event {
+	name := rejectedEventName
+	if d.Approved {
+		name = approvedEventName
+	}
+	return builtinEvent(name, map[string]interface{}{"comment": d.Comment, "by": d.By})
+}
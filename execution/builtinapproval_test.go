@@ -0,0 +1,243 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunBuiltin_ShouldParkApprovalAction(t *testing.T) {
+
+	//Given
+	defer resetActionRepo()
+	var updated Action
+	actionRepo = mockActionRepo{update: func(a Action) error {
+		updated = a
+		return nil
+	}}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	a := newBuiltinActionT("approval", approvalCommandName, bson.M{"approvers": bson.M{"groups": []interface{}{"release-managers"}}})
+	a.setState(statePending)
+	a.WakeAt = a.State.Time.Add(builtinRunTimeout)
+
+	//When
+	_, completed := a.runBuiltin()
+
+	//Then
+	assert.False(t, completed)
+	assert.True(t, updated.WakeAt.IsZero())
+	assert.Equal(t, statePending, updated.State.Value)
+	assert.True(t, updated.isAwaitingApproval())
+}
+
+func TestRunBuiltin_ShouldFailApprovalWithInvalidApprovers(t *testing.T) {
+
+	a := newBuiltinActionT("approval", approvalCommandName, bson.M{"approvers": []interface{}{"jdoe"}})
+
+	result, completed := a.runBuiltin()
+
+	assert.True(t, completed)
+	assert.Equal(t, fatalEventName, result.Name)
+}
+
+func TestRunBuiltin_ShouldFailApprovalWithoutApprovers(t *testing.T) {
+
+	a := newBuiltinActionT("approval", approvalCommandName, bson.M{"approvers": bson.M{}})
+
+	result, completed := a.runBuiltin()
+
+	assert.True(t, completed)
+	assert.Equal(t, fatalEventName, result.Name)
+}
+
+func TestDecideApproval_ShouldCompleteActionWithDecision(t *testing.T) {
+
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	cases := map[bool]string{true: approvedEventName, false: rejectedEventName}
+	for approved, eventName := range cases {
+		//Given
+		var updated Action
+		setupApprovalActionRepoT(newAwaitingApprovalT(), &updated)
+
+		//When
+		var approvers map[string][]string
+		got, err := decideApprovalFn("approval", decision{Approved: approved, Comment: "ship it", By: "jdoe"}, func(a map[string][]string) bool {
+			approvers = a
+			return true
+		})
+
+		//Then
+		require.NoError(t, err, eventName)
+		assert.Equal(t, map[string][]string{"groups": {"release-managers"}}, approvers)
+		assert.Equal(t, stateSuccess, updated.State.Value, eventName)
+		assert.Equal(t, eventName, updated.Result.Name)
+		assert.Equal(t, builtinPackName, updated.Result.Pack.Name)
+		assert.Equal(t, map[string]interface{}{"comment": "ship it", "by": "jdoe"}, updated.Result.Payload)
+		assert.Equal(t, updated, *got)
+	}
+}
+
+func TestDecideApproval_ShouldReturnErrors(t *testing.T) {
+
+	notApproval := newAwaitingApprovalT()
+	notApproval.Name = waitCommandName
+	taken := newAwaitingApprovalT()
+	taken.WakeAt = time.Now().Add(builtinRunTimeout)
+	finished := newAwaitingApprovalT()
+	finished.setState(stateSuccess)
+	cancelled := newAwaitingApprovalT()
+	cancelled.setState(stateCancelled)
+	withoutApprovers := newAwaitingApprovalT()
+	withoutApprovers.Input = bson.M{}
+
+	cases := []struct {
+		name       string
+		action     Action
+		isApprover bool
+		want       error
+	}{
+		{name: "not an approval", action: notApproval, isApprover: true, want: NotApprovalErr},
+		{name: "not an approver", action: newAwaitingApprovalT(), want: NotApproverErr},
+		{name: "no approvers", action: withoutApprovers, isApprover: true, want: NotApproverErr},
+		{name: "not parked yet", action: taken, isApprover: true, want: ApprovalNotAwaitedErr},
+		{name: "already decided", action: finished, isApprover: true, want: ApprovalNotAwaitedErr},
+		{name: "cancelled", action: cancelled, isApprover: true, want: ActionCancelledErr},
+	}
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	for _, c := range cases {
+		action := c.action
+		actionRepo = mockActionRepo{
+			get: func(actionId string) (*Action, error) { return &action, nil },
+			update: func(a Action) error {
+				t.Fatalf("Should not get here, %s", c.name)
+				return nil
+			},
+		}
+
+		_, err := decideApprovalFn("approval", decision{Approved: true}, func(map[string][]string) bool { return c.isApprover })
+
+		assert.Equal(t, c.want, err, c.name)
+	}
+}
+
+func TestDecideApprovalHandler_ShouldReturn202AndHandleAction(t *testing.T) {
+
+	//Given
+	defer resetDecideApproval()
+	var actual decision
+	decideApproval = func(actionId string, d decision, isApprover func(map[string][]string) bool) (*Action, error) {
+		require.Equal(t, "abc", actionId)
+		actual = d
+		// there is no token, so the caller has none of the claims of the approvers
+		assert.False(t, isApprover(map[string][]string{"groups": {"release-managers"}}))
+		return &Action{Id: actionId, Result: Event{Name: approvedEventName}}, nil
+	}
+	defer resetFlowService()
+	handled := make(chan Action, 1)
+	flowSvc = mockFlowService{handleAction: func(a Action) { handled <- a }}
+
+	//When
+	w := httptest.NewRecorder()
+	DecideApproval(w, httptest.NewRequest(http.MethodPost, "/v1/approvals/abc?:actionId=abc",
+		strings.NewReader(`{"decision": "approve", "comment": "ship it"}`)))
+
+	//Then
+	assert.Equal(t, http.StatusAccepted, w.Result().StatusCode)
+	assert.Equal(t, decision{Approved: true, Comment: "ship it", By: "anonymous"}, actual)
+	select {
+	case a := <-handled:
+		assert.Equal(t, "abc", a.Id)
+	case <-time.After(time.Second):
+		t.Fatal("action was not handled")
+	}
+}
+
+func TestDecideApprovalHandler_ShouldReturn400ForInvalidRequest(t *testing.T) {
+
+	defer resetDecideApproval()
+	decideApproval = func(actionId string, d decision, isApprover func(map[string][]string) bool) (*Action, error) {
+		t.Fatal("Should not get here")
+		return nil, nil
+	}
+
+	for _, body := range []string{"{", `{"decision": "maybe"}`, `{"comment": "ship it"}`} {
+		w := httptest.NewRecorder()
+		DecideApproval(w, httptest.NewRequest(http.MethodPost, "/v1/approvals/abc?:actionId=abc", strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, body)
+	}
+}
+
+func TestDecideApprovalHandler_ShouldMapErrorsToStatusCodes(t *testing.T) {
+
+	defer resetDecideApproval()
+	cases := map[error]int{
+		ActionNotFoundErr:     http.StatusNotFound,
+		NotApprovalErr:        http.StatusNotFound,
+		NotApproverErr:        http.StatusForbidden,
+		ActionCancelledErr:    http.StatusGone,
+		ApprovalNotAwaitedErr: http.StatusConflict,
+		mgo.ErrNotFound:       http.StatusConflict,
+		errors.New("db err"):  http.StatusInternalServerError,
+	}
+	for err, status := range cases {
+		decideApproval = func(actionId string, d decision, isApprover func(map[string][]string) bool) (*Action, error) {
+			return nil, err
+		}
+
+		w := httptest.NewRecorder()
+		DecideApproval(w, httptest.NewRequest(http.MethodPost, "/v1/approvals/abc?:actionId=abc", strings.NewReader(`{"decision": "reject"}`)))
+
+		assert.Equal(t, status, w.Result().StatusCode, err.Error())
+	}
+}
+
+// approval action taken and parked by a flyte instance
+func newAwaitingApprovalT() Action {
+	a := newBuiltinActionT("approval", approvalCommandName, bson.M{"approvers": bson.M{"groups": []interface{}{"release-managers"}}})
+	a.setState(statePending)
+	return a
+}
+
+func setupApprovalActionRepoT(stored Action, updated *Action) {
+	actionRepo = mockActionRepo{
+		get: func(actionId string) (*Action, error) {
+			a := stored
+			return &a, nil
+		},
+		update: func(a Action) error {
+			*updated = a
+			return nil
+		},
+	}
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+}
+
+func resetDecideApproval() { decideApproval = decideApprovalFn }
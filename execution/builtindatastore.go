@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	encodingjson "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ExpediaGroup/flyte/datastore"
+	"github.com/ExpediaGroup/flyte/httputil"
+	"github.com/ExpediaGroup/flyte/json"
+)
+
+const (
+	datastorePutCommandName    = "DatastorePut"
+	datastoreDeleteCommandName = "DatastoreDelete"
+	datastoreItemStoredEvent   = "DatastoreItemStored"
+	datastoreItemDeletedEvent  = "DatastoreItemDeleted"
+	defaultDatastoreTextType   = "text/plain"
+)
+
+var storeDatastoreItem = datastore.StoreDataStoreValue
+
+var removeDatastoreItem = datastore.RemoveDataStoreValue
+
+type datastoreInput struct {
+	Key         string    `json:"key"`
+	ContentType string    `json:"contentType"`
+	Description string    `json:"description"`
+	Value       json.Json `json:"value"`
+}
+
+// Stores the value under the key. A string value is stored as it is, as text/plain by default,
+// anything else as json, so it can be read with the datastore template function.
+func datastorePut(input json.Json) Event {
+
+	in, err := decodeDatastoreInput(input)
+	if err != nil {
+		return builtinFatalEvent(err)
+	}
+
+	item := datastore.DataItem{Key: in.Key, ContentType: in.ContentType, Description: in.Description}
+	switch v := in.Value.(type) {
+	case nil:
+		return builtinFatalEvent(fmt.Errorf("datastore input for key=%s has no value", in.Key))
+	case string:
+		item.Value = []byte(v)
+		if item.ContentType == "" {
+			item.ContentType = defaultDatastoreTextType
+		}
+	default:
+		if item.Value, err = encodingjson.Marshal(v); err != nil {
+			return builtinFatalEvent(fmt.Errorf("invalid datastore value for key=%s: %v", in.Key, err))
+		}
+		if item.ContentType == "" {
+			item.ContentType = httputil.MediaTypeJson
+		}
+	}
+
+	updated, err := storeDatastoreItem(item)
+	if err != nil {
+		return builtinFatalEvent(fmt.Errorf("cannot store datastore item key=%s: %v", in.Key, err))
+	}
+	return builtinEvent(datastoreItemStoredEvent, map[string]interface{}{"key": in.Key, "updated": updated})
+}
+
+func datastoreDelete(input json.Json) Event {
+
+	in, err := decodeDatastoreInput(input)
+	if err != nil {
+		return builtinFatalEvent(err)
+	}
+
+	removed, err := removeDatastoreItem(in.Key)
+	if err != nil {
+		return builtinFatalEvent(fmt.Errorf("cannot delete datastore item key=%s: %v", in.Key, err))
+	}
+	return builtinEvent(datastoreItemDeletedEvent, map[string]interface{}{"key": in.Key, "deleted": removed})
+}
+
+func decodeDatastoreInput(input json.Json) (datastoreInput, error) {
+
+	var in datastoreInput
+	if err := decodeBuiltinInput(input, &in); err != nil {
+		return in, err
+	}
+	if in.Key == "" {
+		return in, errors.New("datastore input has no key")
+	}
+	return in, nil
+}
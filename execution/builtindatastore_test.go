@@ -0,0 +1,110 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"errors"
+	"github.com/ExpediaGroup/flyte/datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+)
+
+func TestDatastorePut_ShouldStoreStringValueAsText(t *testing.T) {
+
+	defer resetDatastoreItemFns()
+	var stored datastore.DataItem
+	storeDatastoreItem = func(item datastore.DataItem) (bool, error) {
+		stored = item
+		return true, nil
+	}
+
+	e := datastorePut(bson.M{"key": "lastBuild", "value": "1234", "description": "last build id"})
+
+	assert.Equal(t, datastore.DataItem{Key: "lastBuild", ContentType: "text/plain", Description: "last build id", Value: []byte("1234")}, stored)
+	assert.Equal(t, datastoreItemStoredEvent, e.Name)
+	assert.Equal(t, Pack{Name: builtinPackName}, e.Pack)
+	assert.Equal(t, map[string]interface{}{"key": "lastBuild", "updated": true}, e.Payload)
+}
+
+func TestDatastorePut_ShouldStoreOtherValuesAsJson(t *testing.T) {
+
+	defer resetDatastoreItemFns()
+	var stored datastore.DataItem
+	storeDatastoreItem = func(item datastore.DataItem) (bool, error) {
+		stored = item
+		return false, nil
+	}
+
+	e := datastorePut(bson.M{"key": "rota", "value": bson.M{"primary": "alice"}})
+
+	assert.Equal(t, "application/json", stored.ContentType)
+	assert.JSONEq(t, `{"primary": "alice"}`, string(stored.Value))
+	assert.Equal(t, map[string]interface{}{"key": "rota", "updated": false}, e.Payload)
+}
+
+func TestDatastorePut_ShouldCompleteWithFatalEventOnError(t *testing.T) {
+
+	defer resetDatastoreItemFns()
+	storeDatastoreItem = func(item datastore.DataItem) (bool, error) {
+		return false, errors.New("mongo is down")
+	}
+
+	cases := map[string]interface{}{
+		"no key":      bson.M{"value": "1"},
+		"no value":    bson.M{"key": "counter"},
+		"store fails": bson.M{"key": "counter", "value": "1"},
+	}
+	for name, input := range cases {
+		e := datastorePut(input)
+
+		assert.Equal(t, fatalEventName, e.Name, name)
+	}
+}
+
+func TestDatastoreDelete_ShouldRemoveItem(t *testing.T) {
+
+	defer resetDatastoreItemFns()
+	var removedKey string
+	removeDatastoreItem = func(key string) (bool, error) {
+		removedKey = key
+		return true, nil
+	}
+
+	e := datastoreDelete(bson.M{"key": "lastBuild"})
+
+	assert.Equal(t, "lastBuild", removedKey)
+	require.Equal(t, datastoreItemDeletedEvent, e.Name)
+	assert.Equal(t, map[string]interface{}{"key": "lastBuild", "deleted": true}, e.Payload)
+}
+
+func TestDatastoreDelete_ShouldCompleteWithFatalEventOnError(t *testing.T) {
+
+	defer resetDatastoreItemFns()
+	removeDatastoreItem = func(key string) (bool, error) {
+		return false, errors.New("mongo is down")
+	}
+
+	assert.Equal(t, fatalEventName, datastoreDelete(bson.M{}).Name)
+	assert.Equal(t, fatalEventName, datastoreDelete(bson.M{"key": "lastBuild"}).Name)
+}
+
+func resetDatastoreItemFns() {
+	storeDatastoreItem = datastore.StoreDataStoreValue
+	removeDatastoreItem = datastore.RemoveDataStoreValue
+}
@@ -0,0 +1,254 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"bytes"
+	"context"
+	encodingjson "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ExpediaGroup/flyte/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultHttpRequestTimeout = 30 * time.Second
+	maxHttpRequestTimeout     = 5 * time.Minute
+	// larger response bodies are truncated, the result is stored in mongo with the action
+	maxHttpResponseBodyBytes = 1 << 20
+)
+
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialHttpRequest,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	},
+	CheckRedirect: checkHttpRequestRedirect,
+}
+
+// hosts HttpRequest actions may call, any host when empty. Only listed hosts may resolve to a private network address.
+var httpRequestAllowedHosts []string
+
+// Restricts HttpRequest actions to the hosts, e.g. "api.example.com", or "*.example.com" for any of its sub-domains.
+func AllowHttpRequestHosts(hosts []string) {
+	httpRequestAllowedHosts = hosts
+}
+
+// loopback, link-local and unspecified addresses are refused as well
+var privateNetworks = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+type httpRequestInput struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    json.Json         `json:"body"`
+	Timeout string            `json:"timeout"`
+}
+
+// Performs the request described by the input. A response with a 2xx status completes the action with a HttpResponse
+// event, any other status or a failed request with a HttpError event. Invalid input results in a FATAL event.
+func httpRequest(input json.Json) Event {
+
+	var in httpRequestInput
+	if err := decodeBuiltinInput(input, &in); err != nil {
+		return builtinFatalEvent(err)
+	}
+	req, timeout, err := in.toRequest()
+	if err != nil {
+		return builtinFatalEvent(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return builtinEvent(httpErrorEventName, map[string]interface{}{"error": err.Error()})
+	}
+	defer resp.Body.Close()
+
+	payload, err := httpResponsePayload(resp)
+	if err != nil {
+		return builtinEvent(httpErrorEventName, map[string]interface{}{"status": resp.StatusCode, "error": err.Error()})
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return builtinEvent(httpErrorEventName, payload)
+	}
+	return builtinEvent(httpResponseEventName, payload)
+}
+
+func (in httpRequestInput) toRequest() (*http.Request, time.Duration, error) {
+
+	if in.URL == "" {
+		return nil, 0, errors.New("http request input has no url")
+	}
+	method := strings.ToUpper(in.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	timeout := defaultHttpRequestTimeout
+	if in.Timeout != "" {
+		d, err := time.ParseDuration(in.Timeout)
+		if err != nil || d <= 0 || d > maxHttpRequestTimeout {
+			return nil, 0, fmt.Errorf("invalid http request timeout=%q, it must be a positive duration up to %s", in.Timeout, maxHttpRequestTimeout)
+		}
+		timeout = d
+	}
+
+	// a string body is sent as it is, anything else as json
+	var body io.Reader
+	isJsonBody := false
+	switch b := in.Body.(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(b)
+	default:
+		j, err := encodingjson.Marshal(b)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid http request body: %v", err)
+		}
+		body = bytes.NewReader(j)
+		isJsonBody = true
+	}
+
+	req, err := http.NewRequest(method, in.URL, body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid http request: %v", err)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, 0, fmt.Errorf("invalid http request url=%q, scheme must be http or https", in.URL)
+	}
+	if !isHttpRequestHostAllowed(req.URL.Hostname()) {
+		return nil, 0, fmt.Errorf("http request to host=%q is not allowed", req.URL.Hostname())
+	}
+	for k, v := range in.Headers {
+		req.Header.Set(k, v)
+	}
+	if isJsonBody && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, timeout, nil
+}
+
+// the body of a json response is parsed, so its fields can be used in templates
+func httpResponsePayload(resp *http.Response) (map[string]interface{}, error) {
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHttpResponseBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error reading http response body: %v", err)
+	}
+
+	headers := map[string]string{}
+	for k, v := range resp.Header {
+		headers[k] = strings.Join(v, ", ")
+	}
+
+	var body json.Json = string(b)
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		var parsed json.Json
+		if err := encodingjson.Unmarshal(b, &parsed); err == nil {
+			body = parsed
+		}
+	}
+
+	return map[string]interface{}{
+		"status":  resp.StatusCode,
+		"headers": headers,
+		"body":    body,
+	}, nil
+}
+
+func checkHttpRequestRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !isHttpRequestHostAllowed(req.URL.Hostname()) {
+		return fmt.Errorf("http request redirected to host=%q is not allowed", req.URL.Hostname())
+	}
+	return nil
+}
+
+// The address is checked once it is resolved, so a host name that is not listed cannot be used to call a private network.
+func dialHttpRequest(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if host, _, err := net.SplitHostPort(addr); err != nil || !isHttpRequestHostListed(host) {
+		d.Control = refusePrivateAddress
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isPrivateAddress(ip) {
+		return fmt.Errorf("http request to private network address=%s is not allowed", host)
+	}
+	return nil
+}
+
+func isHttpRequestHostAllowed(host string) bool {
+	return len(httpRequestAllowedHosts) == 0 || isHttpRequestHostListed(host)
+}
+
+func isHttpRequestHostListed(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range httpRequestAllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPrivateAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, n)
+	}
+	return networks
+}
@@ -0,0 +1,222 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHttpRequest_ShouldCompleteWithHttpResponseEvent(t *testing.T) {
+
+	defer resetHttpRequestAllowedHosts()
+	httpRequestAllowedHosts = []string{"127.0.0.1"}
+	//Given
+	var method, contentType, auth, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		contentType = r.Header.Get("Content-Type")
+		auth = r.Header.Get("Authorization")
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "abc")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "123", "tags": ["a"]}`))
+	}))
+	defer server.Close()
+
+	// input read from mongo
+	input := bson.M{
+		"method":  "post",
+		"url":     server.URL + "/deployments",
+		"headers": bson.M{"Authorization": "Bearer token"},
+		"body":    bson.M{"service": "db"},
+	}
+
+	//When
+	e := httpRequest(input)
+
+	//Then
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, "Bearer token", auth)
+	assert.JSONEq(t, `{"service": "db"}`, body)
+
+	require.Equal(t, httpResponseEventName, e.Name)
+	assert.Equal(t, Pack{Name: builtinPackName}, e.Pack)
+	payload := e.Payload.(map[string]interface{})
+	assert.Equal(t, http.StatusCreated, payload["status"])
+	assert.Equal(t, "abc", payload["headers"].(map[string]string)["X-Request-Id"])
+	assert.Equal(t, map[string]interface{}{"id": "123", "tags": []interface{}{"a"}}, payload["body"])
+}
+
+func TestHttpRequest_ShouldSendStringBodyAsItIs(t *testing.T) {
+
+	defer resetHttpRequestAllowedHosts()
+	httpRequestAllowedHosts = []string{"127.0.0.1"}
+	var method, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	e := httpRequest(map[string]interface{}{"method": "PUT", "url": server.URL, "body": "a=b"})
+
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "a=b", body)
+	require.Equal(t, httpResponseEventName, e.Name)
+	assert.Equal(t, "done", e.Payload.(map[string]interface{})["body"])
+}
+
+func TestHttpRequest_ShouldCompleteWithHttpErrorEventForNon2xxStatus(t *testing.T) {
+
+	defer resetHttpRequestAllowedHosts()
+	httpRequestAllowedHosts = []string{"127.0.0.1"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("try later"))
+	}))
+	defer server.Close()
+
+	e := httpRequest(map[string]interface{}{"url": server.URL})
+
+	require.Equal(t, httpErrorEventName, e.Name)
+	payload := e.Payload.(map[string]interface{})
+	assert.Equal(t, http.StatusServiceUnavailable, payload["status"])
+	assert.Equal(t, "try later", payload["body"])
+}
+
+func TestHttpRequest_ShouldCompleteWithHttpErrorEventWhenRequestFails(t *testing.T) {
+
+	defer resetHttpRequestAllowedHosts()
+	httpRequestAllowedHosts = []string{"127.0.0.1"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	e := httpRequest(map[string]interface{}{"url": server.URL})
+
+	require.Equal(t, httpErrorEventName, e.Name)
+	assert.Contains(t, e.Payload.(map[string]interface{})["error"], "connection refused")
+}
+
+func TestHttpRequest_ShouldCompleteWithFatalEventForInvalidInput(t *testing.T) {
+
+	cases := map[string]interface{}{
+		"no input":       nil,
+		"no url":         map[string]interface{}{"method": "GET"},
+		"invalid scheme": map[string]interface{}{"url": "ftp://example.com"},
+		"invalid method": map[string]interface{}{"url": "http://example.com", "method": "GE T"},
+		"bad timeout":    map[string]interface{}{"url": "http://example.com", "timeout": "soon"},
+		"long timeout":   map[string]interface{}{"url": "http://example.com", "timeout": "1h"},
+	}
+	defer resetHttpRequestAllowedHosts()
+	httpRequestAllowedHosts = []string{"example.com"}
+	cases["host not allowed"] = map[string]interface{}{"url": "http://other.example.com"}
+	for name, input := range cases {
+		e := httpRequest(input)
+
+		assert.Equal(t, fatalEventName, e.Name, name)
+	}
+}
+
+func TestHttpRequest_ShouldRefusePrivateNetworkAddressOfHostNotAllowed(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("Should not get here")
+	}))
+	defer server.Close()
+
+	e := httpRequest(map[string]interface{}{"url": server.URL})
+
+	require.Equal(t, httpErrorEventName, e.Name)
+	assert.Contains(t, e.Payload.(map[string]interface{})["error"], "http request to private network address=127.0.0.1 is not allowed")
+}
+
+func TestHttpRequest_ShouldNotFollowRedirectToHostNotAllowed(t *testing.T) {
+
+	defer resetHttpRequestAllowedHosts()
+	httpRequestAllowedHosts = []string{"127.0.0.1"}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "127.0.0.1"+server.URL[len("http://127.0.0.1"):] {
+			t.Fatal("Should not get here")
+		}
+		u, _ := url.Parse(server.URL)
+		http.Redirect(w, r, "http://localhost:"+u.Port(), http.StatusFound)
+	}))
+	defer server.Close()
+
+	e := httpRequest(map[string]interface{}{"url": server.URL})
+
+	require.Equal(t, httpErrorEventName, e.Name)
+	assert.Contains(t, e.Payload.(map[string]interface{})["error"], `http request redirected to host="localhost" is not allowed`)
+}
+
+func TestIsHttpRequestHostAllowed_ShouldMatchListedHostsAndTheirSubDomains(t *testing.T) {
+
+	defer resetHttpRequestAllowedHosts()
+	httpRequestAllowedHosts = []string{"api.example.com", "*.example.org"}
+
+	assert.True(t, isHttpRequestHostAllowed("api.example.com"))
+	assert.True(t, isHttpRequestHostAllowed("API.Example.com"))
+	assert.True(t, isHttpRequestHostAllowed("hooks.example.org"))
+	assert.False(t, isHttpRequestHostAllowed("example.org"))
+	assert.False(t, isHttpRequestHostAllowed("example.com"))
+	assert.False(t, isHttpRequestHostAllowed("api.example.com.evil.com"))
+}
+
+func TestIsHttpRequestHostAllowed_ShouldAllowAnyHostWhenNoneIsListed(t *testing.T) {
+
+	assert.True(t, isHttpRequestHostAllowed("example.com"))
+	assert.False(t, isHttpRequestHostListed("example.com"))
+}
+
+func TestIsPrivateAddress(t *testing.T) {
+
+	cases := map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"::1":              true,
+		"fd00::1":          true,
+		"fe80::1":          true,
+		"::ffff:127.0.0.1": true,
+		"93.184.216.34":    false,
+		"172.32.0.1":       false,
+		"2606:2800::1":     false,
+	}
+	for ip, private := range cases {
+		assert.Equal(t, private, isPrivateAddress(net.ParseIP(ip)), ip)
+	}
+}
+
+func resetHttpRequestAllowedHosts() { httpRequestAllowedHosts = nil }
@@ -0,0 +1,163 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ExpediaGroup/flyte/json"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+const (
+	startSubflowCommandName   = "StartSubflow"
+	subflowStartedEventName   = "SubflowStarted"
+	subflowSucceededEventName = "SubflowSucceeded"
+	subflowFailedEventName    = "SubflowFailed"
+)
+
+type subflowInput struct {
+	Flow    string    `json:"flow"`
+	Payload json.Json `json:"payload"`
+}
+
+// Starts a new execution of the flow named in the input with a SubflowStarted event carrying the input payload.
+// The action stays PENDING until the sub-flow execution finishes, see completeParentAction.
+func (a *Action) startSubflow() (Event, bool) {
+
+	var in subflowInput
+	if err := decodeBuiltinInput(a.Input, &in); err != nil {
+		return builtinFatalEvent(err), true
+	}
+	if in.Flow == "" {
+		return builtinFatalEvent(errors.New("sub-flow input has no flow")), true
+	}
+	flow, err := flowRepo.Get(in.Flow)
+	if err != nil {
+		return builtinFatalEvent(fmt.Errorf("cannot get flow=%s: %v", in.Flow, err)), true
+	}
+
+	// the child is linked before it starts, so it can always find the action when it finishes
+	a.ChildCorrelationId = bson.NewObjectId().Hex()
+	a.WakeAt = time.Time{}
+	a.prevState = a.State
+	if err := a.update(); err != nil {
+		log.Err(err).Msgf("Error linking sub-flow=%s to actionId=%s", in.Flow, a.Id)
+		return Event{}, false
+	}
+
+	flow.correlationId = a.ChildCorrelationId
+	flow.context = map[string]string{}
+	flow.actions = map[string]Action{}
+	flow.parent = &ParentAction{ActionId: a.Id, CorrelationId: a.CorrelationId, FlowName: a.FlowName}
+	flow.HandleEvent(builtinEvent(subflowStartedEventName, in.Payload))
+
+	if len(flow.actions) == 0 {
+		return builtinFatalEvent(fmt.Errorf("flow=%s has no step started by event=%s", in.Flow, subflowStartedEventName)), true
+	}
+
+	log.Info().
+		Str("ActionId", a.Id).
+		Str("CorrelationId", a.CorrelationId).
+		Str("FlowName", a.FlowName).
+		Str("SubflowName", in.Flow).
+		Str("SubflowCorrelationId", a.ChildCorrelationId).
+		Msg("Sub-flow started")
+	return Event{}, false
+}
+
+var completeParentAction = completeParentActionFn
+
+// Completes the action that started the sub-flow execution once none of its actions is running anymore.
+// The outcome depends on the action that finished last, its result is passed to the parent flow.
+func completeParentActionFn(child Flow) {
+
+	// the actions are read again, so at least one of the instances finishing the last actions at the same time sees them all finished
+	actions, err := actionRepo.FindCorrelated(child.correlationId)
+	if err != nil {
+		log.Err(err).Msgf("Error checking if sub-flow correlationId=%s has finished", child.correlationId)
+		return
+	}
+	var last *Action
+	for i, a := range actions {
+		if !a.hasFinished() {
+			return
+		}
+		// the outcome of a forEach step is the action of the step, not its last iteration,
+		// and onError and finally steps do not change the outcome
+		if a.Iteration != nil || a.Handler != "" {
+			continue
+		}
+		if last == nil || a.State.Time.After(last.State.Time) {
+			last = &actions[i]
+		}
+	}
+	if last == nil {
+		return
+	}
+
+	finalAction, err := actionRepo.Get(last.Id)
+	if err != nil {
+		log.Err(err).Msgf("Error getting final actionId=%s of sub-flow correlationId=%s", last.Id, child.correlationId)
+		return
+	}
+	parent, err := actionRepo.Get(child.parent.ActionId)
+	if err != nil {
+		log.Err(err).Msgf("Error getting parent actionId=%s of sub-flow correlationId=%s", child.parent.ActionId, child.correlationId)
+		return
+	}
+
+	// another instance may have completed the parent action already, in which case finish fails
+	if err := parent.finish(subflowResult(child, *finalAction)); err != nil {
+		log.Debug().Msgf("Cannot complete parent actionId=%s: %v", parent.Id, err)
+		return
+	}
+
+	log.Info().
+		Str("ActionId", parent.Id).
+		Str("CorrelationId", parent.CorrelationId).
+		Str("FlowName", parent.FlowName).
+		Str("SubflowName", child.Name).
+		Str("SubflowCorrelationId", child.correlationId).
+		Str("ResultEvent", parent.Result.Name).
+		Msg("Sub-flow finished")
+
+	if parent.isRetrying() {
+		return
+	}
+	flowSvc.HandleAction(*parent)
+}
+
+func subflowResult(child Flow, final Action) Event {
+
+	name := subflowSucceededEventName
+	if final.State.Value != stateSuccess {
+		name = subflowFailedEventName
+	}
+	return builtinEvent(name, map[string]interface{}{
+		"flowName":      child.Name,
+		"correlationId": child.correlationId,
+		"stepId":        final.StepId,
+		"event": map[string]interface{}{
+			"name":     final.Result.Name,
+			"packName": final.Result.Pack.Name,
+			"payload":  final.Result.Payload,
+		},
+	})
+}
@@ -0,0 +1,182 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+	"time"
+)
+
+func TestStartSubflow_ShouldStartChildExecutionLinkedToAction(t *testing.T) {
+
+	//Given
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		get: func(name string) (*Flow, error) {
+			require.Equal(t, "open_incident", name)
+			f := newFlowT(newStepT("create_ticket", subflowStartedEventName, builtinPackName))
+			f.Name = name
+			return &f, nil
+		},
+	}
+	defer resetStepExecutor()
+	rec := setupStepExecutorWithAction(nil)
+
+	defer resetActionRepo()
+	var updated, added Action
+	actionRepo = mockActionRepo{
+		update: func(a Action) error {
+			updated = a
+			return nil
+		},
+		add: func(a Action) error {
+			added = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		add:    func(a Action) error { return nil },
+		update: func(a Action) error { return nil },
+	}
+
+	parent := newBuiltinActionT("parent", startSubflowCommandName, bson.M{"flow": "open_incident", "payload": bson.M{"severity": "high"}})
+	parent.CorrelationId = "parentFlow"
+	parent.FlowName = "deploy"
+	parent.setState(statePending)
+	parent.WakeAt = time.Now()
+
+	//When
+	_, completed := parent.runBuiltin()
+
+	//Then
+	assert.False(t, completed)
+	assert.NotEmpty(t, updated.ChildCorrelationId)
+	assert.True(t, updated.WakeAt.IsZero())
+	assert.Equal(t, statePending, updated.prevState.Value)
+
+	require.Len(t, rec.calls, 1)
+	assert.Equal(t, subflowStartedEventName, rec.calls[0].event.Name)
+	assert.Equal(t, map[string]interface{}{"severity": "high"}, rec.calls[0].event.Payload)
+	assert.Equal(t, updated.ChildCorrelationId, added.CorrelationId)
+	assert.Equal(t, &ParentAction{ActionId: "parent", CorrelationId: "parentFlow", FlowName: "deploy"}, added.Parent)
+}
+
+func TestStartSubflow_ShouldFailWhenChildIsNotStarted(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		get: func(name string) (*Flow, error) {
+			if name == "missing" {
+				return nil, FlowNotFoundErr
+			}
+			f := newFlowT(newStepT("create_ticket", "MessageReceived", "Slack"))
+			return &f, nil
+		},
+	}
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{update: func(a Action) error { return nil }}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	for _, input := range []bson.M{{}, {"flow": "missing"}, {"flow": "open_incident"}} {
+		a := newBuiltinActionT("parent", startSubflowCommandName, input)
+		a.setState(statePending)
+
+		result, completed := a.runBuiltin()
+
+		assert.True(t, completed, "%v", input)
+		assert.Equal(t, fatalEventName, result.Name, "%v", input)
+	}
+}
+
+func TestCompleteParentAction_ShouldFinishParentWithOutcomeOfFinalAction(t *testing.T) {
+
+	//Given
+	now := time.Now().UTC()
+	stored := map[string]*Action{
+		"first": {Id: "first", StepId: "create_ticket", State: State{Value: stateSuccess, Time: now.Add(-time.Minute)}},
+		"final": {Id: "final", StepId: "page", State: State{Value: stateFatal, Time: now},
+			Result: Event{Name: "FATAL", Pack: Pack{Name: "PagerDuty"}, Payload: map[string]interface{}{"error": "no one on call"}}},
+		"parent": {Id: "parent", CorrelationId: "parentFlow", State: State{Value: statePending}, ChildCorrelationId: "childFlow"},
+	}
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			require.Equal(t, "childFlow", correlationId)
+			return []Action{{Id: "first", State: stored["first"].State}, {Id: "final", State: stored["final"].State}}, nil
+		},
+		get: func(actionId string) (*Action, error) {
+			a := *stored[actionId]
+			return &a, nil
+		},
+		update: func(a Action) error { return nil },
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	defer resetFlowService()
+	var handled []Action
+	flowSvc = mockFlowService{
+		handleAction: func(a Action) {
+			handled = append(handled, a)
+		},
+	}
+
+	child := Flow{Name: "open_incident", correlationId: "childFlow", parent: &ParentAction{ActionId: "parent"}}
+
+	//When
+	completeParentActionFn(child)
+
+	//Then
+	require.Len(t, handled, 1)
+	assert.Equal(t, "parent", handled[0].Id)
+	assert.Equal(t, stateSuccess, handled[0].State.Value)
+	assert.Equal(t, subflowFailedEventName, handled[0].Result.Name)
+	assert.Equal(t, Pack{Name: builtinPackName}, handled[0].Result.Pack)
+	assert.Equal(t, map[string]interface{}{
+		"flowName":      "open_incident",
+		"correlationId": "childFlow",
+		"stepId":        "page",
+		"event": map[string]interface{}{
+			"name":     "FATAL",
+			"packName": "PagerDuty",
+			"payload":  map[string]interface{}{"error": "no one on call"},
+		},
+	}, handled[0].Result.Payload)
+}
+
+func TestCompleteParentAction_ShouldDoNothingWhileSubflowIsRunning(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			return []Action{{Id: "first", State: State{Value: stateSuccess}}, {Id: "second", State: State{Value: statePending}}}, nil
+		},
+		get: func(actionId string) (*Action, error) {
+			t.Fatal("Should not get here")
+			return nil, nil
+		},
+	}
+
+	completeParentActionFn(Flow{correlationId: "childFlow", parent: &ParentAction{ActionId: "parent"}})
+}
+
+func resetCompleteParentAction() { completeParentAction = completeParentActionFn }
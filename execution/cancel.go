@@ -0,0 +1,189 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ExpediaGroup/flyte/auth"
+	"github.com/husobee/vestigo"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/mgo.v2"
+	"io"
+	"net/http"
+)
+
+var FlowNotRunningErr = errors.New("flow has no actions left to cancel")
+
+const flowCancelledEventName = "FlowCancelled"
+
+// how many times cancelling an action is attempted when its state changes concurrently (e.g. it is taken)
+const cancelAttempts = 3
+
+type cancelRequest struct {
+	Reason      string `json:"reason"`
+	CancelledBy string `json:"cancelledBy"`
+}
+
+func CancelFlow(w http.ResponseWriter, r *http.Request) {
+
+	correlationId := vestigo.Param(r, "correlationId")
+
+	req := cancelRequest{}
+	if r.Body != nil {
+		defer r.Body.Close()
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			log.Err(err).Msgf("Cannot read cancel request for correlationId=%s", correlationId)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	c := Cancellation{By: cancelledBy(r, req), Reason: req.Reason, Time: currentTime()}
+	cancelled, err := cancelFlow(correlationId, c)
+	if err != nil {
+		switch err {
+		case FlowNotFoundErr:
+			log.Info().Msgf("Flow correlationId=%s not found", correlationId)
+			w.WriteHeader(http.StatusNotFound)
+		case FlowNotRunningErr:
+			log.Info().Msgf("Flow correlationId=%s has no actions left to cancel", correlationId)
+			w.WriteHeader(http.StatusConflict)
+		default:
+			log.Err(err).Msgf("Error cancelling flow correlationId=%s", correlationId)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Info().
+		Str("CorrelationId", correlationId).
+		Str("CancelledBy", c.By).
+		Str("Reason", c.Reason).
+		Int("CancelledActions", cancelled).
+		Msg("Flow cancelled")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// the authenticated user takes precedence over the one sent in the request
+func cancelledBy(r *http.Request, req cancelRequest) string {
+	if user := auth.User(r); user != "" {
+		return user
+	}
+	if req.CancelledBy != "" {
+		return req.CancelledBy
+	}
+	return "anonymous"
+}
+
+var cancelFlow = cancelFlowFn
+
+// cancels all the actions of the flow execution that have not finished yet, returns how many were cancelled
+func cancelFlowFn(correlationId string, c Cancellation) (int, error) {
+
+	actions, err := actionRepo.FindCorrelated(correlationId)
+	if err != nil {
+		return 0, err
+	}
+	if len(actions) == 0 {
+		return 0, FlowNotFoundErr
+	}
+
+	cancelled := 0
+	for _, a := range actions {
+		if !a.isCancellable() {
+			continue
+		}
+		ok, err := cancelAction(a.Id, c)
+		if err != nil {
+			return cancelled, err
+		}
+		if ok {
+			cancelled++
+		}
+	}
+
+	if cancelled == 0 {
+		return 0, FlowNotRunningErr
+	}
+	releaseCancelled(actions)
+	executeFinallyOfCancelled(correlationId, actions[0].Id, c)
+	return cancelled, nil
+}
+
+// The finally steps of the cancelled flow execution are executed with a FlowCancelled event of the built-in pack, its
+// onError steps are not.
+func executeFinallyOfCancelled(correlationId, actionId string, c Cancellation) {
+
+	// the correlated actions have only the fields needed to execute the flow, it is found by a whole action
+	a, err := actionRepo.Get(actionId)
+	if err != nil {
+		log.Err(err).Msgf("Error executing finally steps of cancelled flow correlationId=%s", correlationId)
+		return
+	}
+	flow, err := flowRepo.GetByAction(*a)
+	if err != nil {
+		log.Err(err).Msgf("Error executing finally steps of cancelled flow correlationId=%s", correlationId)
+		return
+	} else if flow == nil {
+		log.Error().Msgf("Error executing finally steps of cancelled flow correlationId=%s: flow not found", correlationId)
+		return
+	}
+	flow.executeHandlerSteps(builtinEvent(flowCancelledEventName, map[string]interface{}{"by": c.By, "reason": c.Reason}))
+}
+
+// The cancelled actions leave room for queued actions of their steps. Queued flow executions are released by the check
+// of the queued actions, the flow definition with their limit is not at hand.
+func releaseCancelled(actions []Action) {
+	released := map[string]bool{}
+	for _, a := range actions {
+		if a.MaxInFlight > 0 && !released[a.StepId] {
+			released[a.StepId] = true
+			releaseCommandQueue(a.FlowName, a.StepId)
+		}
+	}
+}
+
+// cancels the action unless it finishes first, the state update is retried when the action changes in the meantime
+func cancelAction(actionId string, c Cancellation) (bool, error) {
+
+	for i := 0; i < cancelAttempts; i++ {
+		action, err := actionRepo.Get(actionId)
+		if err != nil {
+			return false, err
+		}
+		if !action.isCancellable() {
+			return false, nil
+		}
+
+		err = action.cancel(c)
+		if err == nil {
+			if action.ChildCorrelationId != "" {
+				// the sub-flow execution started by the action is cancelled with it
+				if _, err := cancelFlowFn(action.ChildCorrelationId, c); err != nil && err != FlowNotRunningErr {
+					log.Err(err).Msgf("Error cancelling sub-flow correlationId=%s of actionId=%s", action.ChildCorrelationId, action.Id)
+				}
+			}
+			return true, nil
+		}
+		if err != mgo.ErrNotFound {
+			return false, err
+		}
+	}
+	return false, fmt.Errorf("actionId=%s kept changing state while being cancelled", actionId)
+}
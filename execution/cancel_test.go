@@ -0,0 +1,358 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCancelFlow_ShouldCancelNewAndPendingActions(t *testing.T) {
+
+	//Given
+	defer resetFlowRepo()
+	setupCancelledFlowRepoT()
+	defer resetActionRepo()
+	stored := map[string]*Action{
+		"new":     {Id: "new", State: State{Value: stateNew}},
+		"pending": {Id: "pending", State: State{Value: statePending}, LeaseExpiresAt: time.Now()},
+		"success": {Id: "success", State: State{Value: stateSuccess}},
+	}
+	updated := map[string]Action{}
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			require.Equal(t, "abc", correlationId)
+			return []Action{*stored["new"], *stored["pending"], *stored["success"]}, nil
+		},
+		get: func(actionId string) (*Action, error) {
+			a := *stored[actionId]
+			return &a, nil
+		},
+		update: func(a Action) error {
+			updated[a.Id] = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	audited := map[string]Action{}
+	auditRepo = mockAuditRepo{
+		update: func(a Action) error {
+			audited[a.Id] = a
+			return nil
+		},
+	}
+
+	//When
+	c := Cancellation{By: "jdoe", Reason: "wrong input", Time: time.Now()}
+	cancelled, err := cancelFlowFn("abc", c)
+
+	//Then
+	require.NoError(t, err)
+	assert.Equal(t, 2, cancelled)
+	require.Len(t, updated, 2)
+	for _, id := range []string{"new", "pending"} {
+		assert.Equal(t, stateCancelled, updated[id].State.Value)
+		assert.Equal(t, &c, updated[id].Cancellation)
+		assert.True(t, updated[id].LeaseExpiresAt.IsZero())
+		assert.Equal(t, updated[id], audited[id])
+	}
+}
+
+func TestCancelFlow_ShouldCancelSubflowStartedByCancelledAction(t *testing.T) {
+
+	//Given
+	defer resetFlowRepo()
+	setupCancelledFlowRepoT()
+	defer resetActionRepo()
+	stored := map[string]*Action{
+		"parent": {Id: "parent", State: State{Value: statePending}, ChildCorrelationId: "child"},
+		"child":  {Id: "child", State: State{Value: statePending}},
+	}
+	correlated := map[string][]Action{"abc": {*stored["parent"]}, "child": {*stored["child"]}}
+	updated := map[string]Action{}
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			return correlated[correlationId], nil
+		},
+		get: func(actionId string) (*Action, error) {
+			a := *stored[actionId]
+			return &a, nil
+		},
+		update: func(a Action) error {
+			updated[a.Id] = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	//When
+	cancelled, err := cancelFlowFn("abc", Cancellation{By: "jdoe", Time: time.Now()})
+
+	//Then
+	require.NoError(t, err)
+	assert.Equal(t, 1, cancelled)
+	assert.Equal(t, stateCancelled, updated["parent"].State.Value)
+	assert.Equal(t, stateCancelled, updated["child"].State.Value)
+}
+
+func TestCancelFlow_ShouldReturnFlowNotFoundErrWhenThereAreNoActions(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			return nil, nil
+		},
+	}
+
+	_, err := cancelFlowFn("abc", Cancellation{})
+
+	assert.Equal(t, FlowNotFoundErr, err)
+}
+
+func TestCancelFlow_ShouldReturnFlowNotRunningErrWhenAllActionsHaveFinished(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			return []Action{{Id: "a", State: State{Value: stateSuccess}}, {Id: "b", State: State{Value: stateCancelled}}}, nil
+		},
+	}
+
+	_, err := cancelFlowFn("abc", Cancellation{})
+
+	assert.Equal(t, FlowNotRunningErr, err)
+}
+
+func TestCancelFlow_ShouldRetryWhenActionIsTakenWhileBeingCancelled(t *testing.T) {
+
+	//Given
+	defer resetFlowRepo()
+	setupCancelledFlowRepoT()
+	defer resetActionRepo()
+	gets := 0
+	var cancelledAction Action
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			return []Action{{Id: "a", State: State{Value: stateNew}}}, nil
+		},
+		get: func(actionId string) (*Action, error) {
+			gets++
+			if gets == 1 {
+				return &Action{Id: actionId, State: State{Value: stateNew}}, nil
+			}
+			return &Action{Id: actionId, State: State{Value: statePending}}, nil
+		},
+		update: func(a Action) error {
+			if a.prevState.Value == stateNew {
+				return mgo.ErrNotFound
+			}
+			cancelledAction = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	//When
+	cancelled, err := cancelFlowFn("abc", Cancellation{By: "jdoe"})
+
+	//Then
+	require.NoError(t, err)
+	assert.Equal(t, 1, cancelled)
+	// twice to cancel the action, then once more to find the flow to execute its finally steps
+	assert.Equal(t, 3, gets)
+	assert.Equal(t, stateCancelled, cancelledAction.State.Value)
+	assert.Equal(t, statePending, cancelledAction.prevState.Value)
+}
+
+func TestCancelFlow_ShouldNotCancelActionWhichFinishedInTheMeantime(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			return []Action{{Id: "a", State: State{Value: statePending}}}, nil
+		},
+		get: func(actionId string) (*Action, error) {
+			return &Action{Id: actionId, State: State{Value: stateSuccess}}, nil
+		},
+		update: func(a Action) error {
+			t.Fatal("Should not get here")
+			return nil
+		},
+	}
+
+	_, err := cancelFlowFn("abc", Cancellation{})
+
+	assert.Equal(t, FlowNotRunningErr, err)
+}
+
+func TestCancelFlow_ShouldExecuteFinallyStepsOfCancelledFlow(t *testing.T) {
+
+	//Given
+	defer resetActionRepo()
+	stored := Action{Id: "deploy", StepId: "deploy", CorrelationId: "abc", FlowUUID: "123", State: State{Value: statePending}}
+	var added []Action
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			// only some of the fields of the correlated actions are read
+			return []Action{{Id: stored.Id, StepId: stored.StepId, State: stored.State}}, nil
+		},
+		get: func(actionId string) (*Action, error) {
+			a := stored
+			return &a, nil
+		},
+		update: func(a Action) error {
+			stored = a
+			return nil
+		},
+		add: func(a Action) error {
+			added = append(added, a)
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		add:    func(a Action) error { return nil },
+		update: func(a Action) error { return nil },
+	}
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		getByAction: func(a Action) (*Flow, error) {
+			require.Equal(t, "abc", a.CorrelationId)
+			require.Equal(t, "123", a.FlowUUID)
+			flow := newHandlerFlowWithActionT(stored)
+			return &flow, nil
+		},
+	}
+
+	//When
+	_, err := cancelFlowFn("abc", Cancellation{By: "jdoe", Reason: "wrong input"})
+
+	//Then
+	require.NoError(t, err)
+	require.Len(t, added, 1)
+	assert.Equal(t, "clean_up", added[0].StepId)
+	assert.Equal(t, finallyHandler, added[0].Handler)
+	assert.Equal(t, flowCancelledEventName, added[0].Input)
+	assert.Equal(t, Pack{Name: builtinPackName}, added[0].Trigger.Pack)
+	assert.Equal(t, map[string]interface{}{"by": "jdoe", "reason": "wrong input"}, added[0].Trigger.Payload)
+}
+
+func TestCompleteAction_ShouldReturnActionCancelledErrForCancelledAction(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		get: func(actionId string) (*Action, error) {
+			return &Action{Id: actionId, State: State{Value: stateCancelled}}, nil
+		},
+		update: func(a Action) error {
+			t.Fatal("Should not get here")
+			return nil
+		},
+	}
+
+	_, err := Pack{}.CompleteAction("a", "", Event{Name: "resultEvent"})
+
+	assert.Equal(t, ActionCancelledErr, err)
+}
+
+func TestCancelFlowHandler_ShouldReturn204AndRecordWhoCancelled(t *testing.T) {
+
+	//Given
+	defer resetCancelFlow()
+	var actual Cancellation
+	cancelFlow = func(correlationId string, c Cancellation) (int, error) {
+		require.Equal(t, "abc", correlationId)
+		actual = c
+		return 2, nil
+	}
+
+	//When
+	w := httptest.NewRecorder()
+	CancelFlow(w, httptest.NewRequest(http.MethodPost, "/v1/audit/flows/abc/cancel?:correlationId=abc",
+		strings.NewReader(`{"reason": "wrong input", "cancelledBy": "jdoe"}`)))
+
+	//Then
+	assert.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+	assert.Equal(t, "jdoe", actual.By)
+	assert.Equal(t, "wrong input", actual.Reason)
+	assert.WithinDuration(t, time.Now(), actual.Time, 10*time.Second)
+}
+
+func TestCancelFlowHandler_ShouldRecordAnonymousWhenBodyIsEmpty(t *testing.T) {
+
+	defer resetCancelFlow()
+	var actual Cancellation
+	cancelFlow = func(correlationId string, c Cancellation) (int, error) {
+		actual = c
+		return 1, nil
+	}
+
+	w := httptest.NewRecorder()
+	CancelFlow(w, httptest.NewRequest(http.MethodPost, "/v1/audit/flows/abc/cancel?:correlationId=abc", nil))
+
+	assert.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+	assert.Equal(t, "anonymous", actual.By)
+}
+
+func TestCancelFlowHandler_ShouldReturn400ForInvalidBody(t *testing.T) {
+
+	defer resetCancelFlow()
+	cancelFlow = func(correlationId string, c Cancellation) (int, error) {
+		t.Fatal("Should not get here")
+		return 0, nil
+	}
+
+	w := httptest.NewRecorder()
+	CancelFlow(w, httptest.NewRequest(http.MethodPost, "/v1/audit/flows/abc/cancel?:correlationId=abc", strings.NewReader("{")))
+
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestCancelFlowHandler_ShouldMapErrorsToStatusCodes(t *testing.T) {
+
+	defer resetCancelFlow()
+	cases := map[error]int{
+		FlowNotFoundErr:      http.StatusNotFound,
+		FlowNotRunningErr:    http.StatusConflict,
+		errors.New("db err"): http.StatusInternalServerError,
+	}
+	for err, status := range cases {
+		cancelFlow = func(correlationId string, c Cancellation) (int, error) {
+			return 0, err
+		}
+
+		w := httptest.NewRecorder()
+		CancelFlow(w, httptest.NewRequest(http.MethodPost, "/v1/audit/flows/abc/cancel?:correlationId=abc", nil))
+
+		assert.Equal(t, status, w.Result().StatusCode, err.Error())
+	}
+}
+
+func resetCancelFlow() { cancelFlow = cancelFlowFn }
+
+func setupCancelledFlowRepoT() {
+	flowRepo = mockFlowRepo{getByAction: func(a Action) (*Flow, error) { return &Flow{}, nil }}
+}
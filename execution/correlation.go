@@ -0,0 +1,54 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"fmt"
+	"github.com/ExpediaGroup/flyte/template"
+	"github.com/rs/zerolog/log"
+)
+
+// correlationKeyOf resolves the CorrelationKey template of the flow with the event. An event without a key,
+// e.g. because its payload does not have the field the template refers to, is not correlated by key.
+func (f Flow) correlationKeyOf(e Event) string {
+
+	if f.CorrelationKey == "" {
+		return ""
+	}
+	key, err := template.Resolve(f.CorrelationKey, templateContext(e, nil))
+	if err != nil {
+		log.Err(err).Msgf("Error resolving correlation key of flow=%s with event=%+v", f.Name, e)
+		return ""
+	}
+	return fmt.Sprint(key)
+}
+
+// An event is routed into the execution with the same correlation key only when one of its steps can handle it,
+// otherwise the event may start a new execution, which then takes the key over.
+func (f Flow) acceptsEvent(e Event) bool {
+	return !f.isCancelled() && len(f.candidateSteps(e)) > 0
+}
+
+// whether the event starts a step that does not depend on any other step, i.e. a new execution of the flow
+func (f Flow) isStartedBy(e Event) bool {
+	for _, step := range f.Steps {
+		if len(step.DependsOn) == 0 && step.Event.Name == e.Name && step.Event.PackName == e.Pack.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCorrelationKeyOf(t *testing.T) {
+
+	e := Event{Name: "IncidentOpened", Payload: map[string]interface{}{"incidentId": "INC-42", "count": 3}}
+
+	cases := map[string]struct {
+		correlationKey string
+		want           string
+	}{
+		"no correlation key":  {},
+		"resolved":            {correlationKey: "{{ Event.Payload.incidentId }}", want: "INC-42"},
+		"resolved to number":  {correlationKey: "{{ Event.Payload.count }}", want: "3"},
+		"missing in payload":  {correlationKey: "{{ Event.Payload.missing }}"},
+		"invalid template":    {correlationKey: "{{ Event.Payload| }}"},
+		"combined with event": {correlationKey: "{{ Event.Name }}-{{ Event.Payload.incidentId }}", want: "IncidentOpened-INC-42"},
+	}
+	for name, c := range cases {
+		flow := Flow{CorrelationKey: c.correlationKey}
+
+		assert.Equal(t, c.want, flow.correlationKeyOf(e), name)
+	}
+}
+
+func TestAcceptsEvent_ShouldAcceptEventHandledByAnyStep(t *testing.T) {
+
+	approve := newStepT("approve", "ApprovalReceived", "Slack")
+	approve.DependsOn = []string{"ask"}
+	flow := newFlowT(newStepT("ask", "IncidentOpened", "Monitor"), approve)
+	flow.actions["ask"] = Action{StepId: "ask", State: State{Value: stateSuccess}}
+
+	assert.True(t, flow.acceptsEvent(Event{Name: "ApprovalReceived", Pack: Pack{Name: "Slack"}}))
+	// the step the execution was started by has already been executed
+	assert.False(t, flow.acceptsEvent(Event{Name: "IncidentOpened", Pack: Pack{Name: "Monitor"}}))
+}
+
+func TestAcceptsEvent_ShouldNotAcceptEventInCancelledExecution(t *testing.T) {
+
+	approve := newStepT("approve", "ApprovalReceived", "Slack")
+	approve.DependsOn = []string{"ask"}
+	flow := newFlowT(newStepT("ask", "IncidentOpened", "Monitor"), approve)
+	flow.actions["ask"] = Action{StepId: "ask", State: State{Value: stateCancelled}, Cancellation: &Cancellation{By: "jdoe"}}
+
+	assert.False(t, flow.acceptsEvent(Event{Name: "ApprovalReceived", Pack: Pack{Name: "Slack"}}))
+}
+
+func TestIsStartedBy_ShouldOnlyMatchStepsWithoutDependsOn(t *testing.T) {
+
+	approve := newStepT("approve", "ApprovalReceived", "Slack")
+	approve.DependsOn = []string{"ask"}
+	flow := newFlowT(newStepT("ask", "IncidentOpened", "Monitor"), approve)
+
+	assert.True(t, flow.isStartedBy(Event{Name: "IncidentOpened", Pack: Pack{Name: "Monitor"}}))
+	assert.False(t, flow.isStartedBy(Event{Name: "ApprovalReceived", Pack: Pack{Name: "Slack"}}))
+	assert.False(t, flow.isStartedBy(Event{Name: "IncidentOpened", Pack: Pack{Name: "Slack"}}))
+}
+
+func TestFlowAddAction_ShouldSetCorrelationKeyOfExecution(t *testing.T) {
+
+	defer resetActionRepo()
+	var added Action
+	actionRepo = mockActionRepo{add: func(a Action) error {
+		added = a
+		return nil
+	}}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	flow := newFlowT(newStepT("ask", "IncidentOpened", "Monitor"))
+	flow.correlationKey = "INC-42"
+
+	assert.NoError(t, flow.addAction("ask", Action{Id: "ask"}))
+	assert.Equal(t, "INC-42", added.CorrelationKey)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// how many of the highest priority, oldest new actions fairness chooses from
+const fairnessCandidates = 500
+
+// nil when fairness is disabled and actions are taken strictly by priority and age
+var flowFairness *fairness
+
+// Enables per-flow fairness: within the same priority, actions of a pack are taken round-robin across flows,
+// starting with the flow least recently served, so a burst of actions from one flow does not starve the others.
+func EnableFlowFairness() {
+	flowFairness = newFairness()
+}
+
+// keeps track of when an action of each flow was last taken by a pack, on this flyte instance only
+type fairness struct {
+	mu        sync.Mutex
+	lastTaken map[string]time.Time
+}
+
+func newFairness() *fairness {
+	return &fairness{lastTaken: map[string]time.Time{}}
+}
+
+// reorders candidates, which are sorted by priority and age, round-robin across flows within each priority
+func (f *fairness) order(candidates []Action) []Action {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ordered := make([]Action, 0, len(candidates))
+	for start := 0; start < len(candidates); {
+		end := start
+		for end < len(candidates) && candidates[end].Priority == candidates[start].Priority {
+			end++
+		}
+		ordered = append(ordered, f.roundRobin(candidates[start:end])...)
+		start = end
+	}
+	return ordered
+}
+
+func (f *fairness) roundRobin(actions []Action) []Action {
+
+	var flows []string
+	byFlow := map[string][]Action{}
+	for _, a := range actions {
+		k := fairnessKey(a)
+		if _, ok := byFlow[k]; !ok {
+			flows = append(flows, k)
+		}
+		byFlow[k] = append(byFlow[k], a)
+	}
+
+	// flows are in order of their oldest action, the stable sort keeps it for flows served at the same time
+	sort.SliceStable(flows, func(i, j int) bool {
+		return f.lastTaken[flows[i]].Before(f.lastTaken[flows[j]])
+	})
+
+	ordered := make([]Action, 0, len(actions))
+	for round := 0; len(ordered) < len(actions); round++ {
+		for _, k := range flows {
+			if round < len(byFlow[k]) {
+				ordered = append(ordered, byFlow[k][round])
+			}
+		}
+	}
+	return ordered
+}
+
+func (f *fairness) taken(a Action) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastTaken[fairnessKey(a)] = a.State.Time
+}
+
+func fairnessKey(a Action) string {
+	return a.PackName + "/" + a.FlowName
+}
@@ -0,0 +1,111 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFairnessOrder_ShouldInterleaveFlowsWithinTheSamePriority(t *testing.T) {
+
+	candidates := []Action{
+		{Id: "urgent", FlowName: "noisy", Priority: 10},
+		{Id: "noisy1", FlowName: "noisy"},
+		{Id: "noisy2", FlowName: "noisy"},
+		{Id: "noisy3", FlowName: "noisy"},
+		{Id: "quiet1", FlowName: "quiet"},
+		{Id: "other1", FlowName: "other"},
+	}
+
+	got := newFairness().order(candidates)
+
+	assert.Equal(t, []string{"urgent", "noisy1", "quiet1", "other1", "noisy2", "noisy3"}, actionIds(got))
+}
+
+func TestFairnessOrder_ShouldStartWithLeastRecentlyServedFlow(t *testing.T) {
+
+	f := newFairness()
+	now := time.Now()
+	f.taken(Action{FlowName: "noisy", State: State{Value: statePending, Time: now}})
+	f.taken(Action{FlowName: "quiet", State: State{Value: statePending, Time: now.Add(-1 * time.Minute)}})
+
+	candidates := []Action{
+		{Id: "noisy1", FlowName: "noisy"},
+		{Id: "quiet1", FlowName: "quiet"},
+		{Id: "other1", FlowName: "other"},
+	}
+
+	got := f.order(candidates)
+
+	assert.Equal(t, []string{"other1", "quiet1", "noisy1"}, actionIds(got))
+}
+
+func TestTakeAction_ShouldTakeActionsRoundRobinAcrossFlows_WhenFairnessIsEnabled(t *testing.T) {
+
+	defer func(f *fairness) { flowFairness = f }(flowFairness)
+	EnableFlowFairness()
+
+	defer resetActionRepo()
+	newState := State{Value: stateNew}
+	actions := []Action{
+		{Id: "noisy1", FlowName: "noisy", State: newState},
+		{Id: "noisy2", FlowName: "noisy", State: newState},
+		{Id: "quiet1", FlowName: "quiet", State: newState},
+	}
+	actionRepo = mockActionRepo{
+		findAllNew: func(pack Pack, name string, limit int) ([]Action, error) {
+			return actions, nil
+		},
+		update: func(a Action) error {
+			// taken actions are no longer new
+			for i := range actions {
+				if actions[i].Id == a.Id {
+					actions = append(actions[:i], actions[i+1:]...)
+					break
+				}
+			}
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(a Action) error {
+			return nil
+		},
+	}
+
+	var taken []string
+	for i := 0; i < 3; i++ {
+		a, err := Pack{Name: "packA"}.TakeAction("")
+		if assert.NoError(t, err) && assert.NotNil(t, a) {
+			taken = append(taken, a.Id)
+		}
+	}
+
+	assert.Equal(t, []string{"noisy1", "quiet1", "noisy2"}, taken)
+}
+
+func actionIds(actions []Action) []string {
+	var ids []string
+	for _, a := range actions {
+		ids = append(ids, a.Id)
+	}
+	return ids
+}
@@ -17,17 +17,39 @@ limitations under the License.
 package execution
 
 import (
+	"fmt"
+	"github.com/ExpediaGroup/flyte/template"
 	"github.com/rs/zerolog/log"
+	"strconv"
+)
+
+const (
+	joinAll = "all"
+	joinAny = "any"
 )
 
 type Flow struct {
-	UUID  string `bson:"uuid"`
-	Name  string `bson:"name"`
-	Steps []Step `bson:"steps,omitempty"`
+	UUID     string    `bson:"uuid"`
+	Name     string    `bson:"name"`
+	Schedule *Schedule `bson:"schedule,omitempty"`
+	Steps    []Step    `bson:"steps,omitempty"`
+	OnError  []Step    `bson:"onError,omitempty"`
+	Finally  []Step    `bson:"finally,omitempty"`
+	// template resolved with the events of the flow, events with the same key are handled by the same execution
+	CorrelationKey string `bson:"correlationKey,omitempty"`
+	// how many executions of the flow can be running at once, 0 is no limit
+	MaxConcurrentExecutions int `bson:"maxConcurrentExecutions,omitempty"`
 
-	correlationId string            `bson:"-"`
-	context       map[string]string `bson:"-"`
-	actions       map[string]Action `bson:"-"`
+	correlationId  string              `bson:"-"`
+	correlationKey string              `bson:"-"`
+	context        map[string]string   `bson:"-"`
+	actions        map[string]Action   `bson:"-"`
+	iterations     map[string][]Action `bson:"-"`
+	parent         *ParentAction       `bson:"-"`
+	// action ended with a FATAL event whose result is being handled, it triggers the onError steps
+	failed *Action `bson:"-"`
+	// the flow execution waits for a running execution of the flow to finish, its actions are queued
+	queued bool `bson:"-"`
 }
 
 func (f *Flow) HandleEvent(e Event) {
@@ -38,38 +60,115 @@ var flowEventHandler = flowEventHandlerFn
 
 func flowEventHandlerFn(f *Flow, e Event) {
 
-	for _, step := range f.candidateSteps(e) {
+	if f.isCancelled() {
+		log.Info().Msgf("Flow=%s correlationId=%s has been cancelled, event=%s is not handled", f.Name, f.correlationId, e.Name)
+		return
+	}
 
+	for _, step := range f.candidateSteps(e) {
 		action, err := step.Execute(e, f.context)
-		if err != nil {
-			log.Err(err).Msgf("Error handling flow=%s step=%s", f.UUID, step.Id)
-			continue
+		f.addStepAction(step, action, err)
+	}
+	f.executeHandlerSteps(e)
+}
+
+func (f *Flow) addStepAction(step Step, action *Action, err error) {
+
+	if err != nil {
+		log.Err(err).Msgf("Error handling flow=%s step=%s", f.UUID, step.Id)
+		return
+	}
+
+	if action != nil {
+		if err := f.addAction(step.Id, *action); err != nil {
+			log.Err(err).Msgf("Error saving action=%+v", action)
+		} else {
+			log.Info().Msgf("Action has been created actionId=%s", action.Id)
+			log.Debug().Msgf("action=%+v", action)
 		}
+	}
+}
 
-		if action != nil {
-			if err := f.addAction(step.Id, *action); err != nil {
-				log.Err(err).Msgf("Error saving action=%+v", action)
-			} else {
-				log.Info().Msgf("Action has been created actionId=%s", action.Id)
-				log.Debug().Msgf("action=%+v", action)
-			}
+func (f Flow) isCancelled() bool {
+	for _, a := range f.actions {
+		if a.Cancellation != nil {
+			return true
 		}
 	}
+	return false
 }
 
 func (f *Flow) addAction(stepId string, a Action) error {
+
+	iterations := a.iterations
+	a.iterations = nil
+	if prev, ok := f.actions[stepId]; ok {
+		// a re-run replaces the failed action, otherwise the step is repeated
+		a.Repetition = prev.Repetition
+		if a.ReplayOf == "" {
+			a.Repetition++
+		}
+	}
+	if err := f.saveAction(stepId, &a); err != nil {
+		return err
+	}
+	f.actions[stepId] = a
+	if a.ForEach == nil {
+		return nil
+	}
+
+	if f.iterations == nil {
+		f.iterations = map[string][]Action{}
+	}
+	for _, it := range iterations {
+		it.Repetition = a.Repetition
+		if err := f.saveAction(stepId, &it); err != nil {
+			return err
+		}
+		f.iterations[stepId] = append(f.iterations[stepId], it)
+	}
+	// a forEach step with an empty list completes straight away
+	f.completeForEach(stepId)
+	return nil
+}
+
+func (f *Flow) saveAction(stepId string, a *Action) error {
 	a.CorrelationId = f.correlationId
+	a.CorrelationKey = f.correlationKey
 	a.FlowUUID = f.UUID
 	a.FlowName = f.Name
 	a.StepId = stepId
+	a.Parent = f.parent
+	if f.isLimited(*a) {
+		unlock, err := lockLimits(f.Name)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if err := f.applyLimits(a); err != nil {
+			return err
+		}
+	}
+	if a.isBuiltin() && a.ForEach == nil && a.State.Value != stateQueued {
+		a.scheduleBuiltin()
+	}
 
-	if err := actionRepo.Add(a); err != nil {
+	if err := actionRepo.Add(*a); err != nil {
 		return err
 	}
-	if err := auditRepo.Add(a); err != nil {
-		log.Err(err).Msgf("Error saving audit for action=%+v", a)
+	if err := auditRepo.Add(*a); err != nil {
+		log.Err(err).Msgf("Error saving audit for action=%+v", *a)
+	}
+	switch {
+	case a.ForEach != nil:
+		// the action of a forEach step is completed by its iterations
+	case a.State.Value == stateQueued:
+		// the action is released once its limits allow it
+	case a.isBuiltin() && !a.WakeAt.After(a.State.Time):
+		runBuiltinNow(*a)
+	default:
+		newActions.notify()
 	}
-	f.actions[stepId] = a
 	return nil
 }
 
@@ -84,10 +183,29 @@ func (f *Flow) candidateSteps(e Event) []Step {
 }
 
 func (f Flow) isStepCandidateForExecution(step Step, e Event) bool {
-	return step.Event.Name == e.Name &&
-		step.Event.PackName == e.Pack.Name &&
-		!f.hasActionForStep(step.Id) &&
-		f.isDependsOnSatisfied(step)
+	return f.notCandidateReason(step, e) == ""
+}
+
+// why the step is not executed with the event, empty when it is a candidate for execution
+func (f Flow) notCandidateReason(step Step, e Event) string {
+	switch {
+	case step.Event.Name != e.Name || step.Event.PackName != e.Pack.Name:
+		return reasonEventNotMatched
+	case f.hasActionForStep(step.Id) && !f.canRepeat(step):
+		return reasonAlreadyExecuted
+	case !f.isDependsOnSatisfied(step):
+		return reasonDependsOnNotMet
+	}
+	return ""
+}
+
+func (f Flow) step(stepId string) (Step, bool) {
+	for _, step := range f.Steps {
+		if step.Id == stepId {
+			return step, true
+		}
+	}
+	return Step{}, false
 }
 
 func (f Flow) hasActionForStep(stepId string) bool {
@@ -102,14 +220,48 @@ func (f Flow) isDependsOnSatisfied(step Step) bool {
 	if len(step.DependsOn) == 0 {
 		return true
 	}
-	for _, stepId := range step.DependsOn {
-		// TODO: currently depends_on is an "OR" operation i.e. {"dependsOn" : ["hipchat_start", "jira_start"] }
-		// means that EITHER "hipchat_start" OR "jira_start" must have happened, not necessarily both
-		if f.hasFinishedAction(stepId) {
-			return true
+	switch step.Join {
+	case joinAll:
+		for _, stepId := range step.DependsOn {
+			if !f.hasFinishedAction(stepId) {
+				return false
+			}
+		}
+		return true
+	case joinAny, "":
+		for _, stepId := range step.DependsOn {
+			if f.hasFinishedAction(stepId) {
+				return true
+			}
 		}
+		return false
+	default:
+		satisfied, err := f.isJoinExpressionMet(step.Join)
+		if err != nil {
+			log.Err(err).Msgf("Error evaluating join for flow=%s step=%s", f.UUID, step.Id)
+			return false
+		}
+		return satisfied
 	}
-	return false
+}
+
+// Join expressions are evaluated as pongo templates where every step id is bound to a map of
+// the state of its action, e.g. "jira_start.SUCCESS && !slack.FATAL". Steps without an action have no
+// states set, so any state check on them evaluates to false.
+func (f Flow) isJoinExpressionMet(expression string) (bool, error) {
+	ctx := template.Context{}
+	for _, step := range f.Steps {
+		ctx[step.Id] = map[string]bool{}
+	}
+	for stepId, action := range f.actions {
+		ctx[stepId] = map[string]bool{action.State.Value: true}
+	}
+
+	result, err := template.Resolve("{{ "+expression+" }}", ctx)
+	if err != nil {
+		return false, fmt.Errorf("error resolving join expression=%q: %v", expression, err)
+	}
+	return strconv.ParseBool(result.(string))
 }
 
 func (f Flow) hasFinishedAction(stepId string) bool {
@@ -134,6 +134,22 @@ func TestFlowHandleEvent_ShouldSkipStepsWhenThereIsAlreadyActionForThatStep(t *t
 	assert.Len(t, rec.calls, 0)
 }
 
+func TestFlowHandleEvent_ShouldSkipAllStepsWhenFlowHasBeenCancelled(t *testing.T) {
+
+	defer resetStepExecutor()
+	rec := setupStepExecutor(nil, nil)
+
+	stepWithDepends := newStepT("withDependsOn", "eventOK", "packOK")
+	stepWithDepends.DependsOn = []string{"stepA"}
+	flow := newFlowT(stepWithDepends, newStepT("withoutDependsOn", "eventOK", "packOK"))
+	flow.actions["stepA"] = Action{State: State{Value: stateSuccess}}
+	flow.actions["stepB"] = Action{State: State{Value: stateCancelled}, Cancellation: &Cancellation{By: "jdoe"}}
+
+	flow.HandleEvent(Event{Name: "eventOK", Pack: Pack{Name: "packOK"}})
+
+	assert.Len(t, rec.calls, 0)
+}
+
 func TestFlowHandleEvent_ShouldProceedWithStepExecutionWhenItDoesNotDependOnOtherSteps(t *testing.T) {
 
 	defer resetStepExecutor()
@@ -179,6 +195,108 @@ func TestFlowHandleEvent_ShouldSkipStepWhenDependsOnForAStepIsNotSatisfied(t *te
 	assert.Len(t, rec.calls, 0)
 }
 
+func TestFlowHandleEvent_ShouldSkipStepWhenJoinIsAllAndNotAllDependsOnStepsHaveFinished(t *testing.T) {
+
+	defer resetStepExecutor()
+	rec := setupStepExecutor(nil, nil)
+
+	step := newStepT("joinAll", "eventOK", "packOK")
+	step.DependsOn = []string{"stepA", "stepB"}
+	step.Join = joinAll
+	flow := newFlowT(step)
+	flow.actions["stepA"] = Action{State: State{Value: stateSuccess}}
+	flow.actions["stepB"] = Action{State: State{Value: statePending}}
+
+	flow.HandleEvent(Event{Name: "eventOK", Pack: Pack{Name: "packOK"}})
+
+	assert.Len(t, rec.calls, 0)
+}
+
+func TestFlowHandleEvent_ShouldProceedWithStepExecutionWhenJoinIsAllAndAllDependsOnStepsHaveFinished(t *testing.T) {
+
+	defer resetStepExecutor()
+	rec := setupStepExecutor(nil, nil)
+
+	step := newStepT("joinAll", "eventOK", "packOK")
+	step.DependsOn = []string{"stepA", "stepB"}
+	step.Join = joinAll
+	flow := newFlowT(step)
+	flow.actions["stepA"] = Action{State: State{Value: stateSuccess}}
+	flow.actions["stepB"] = Action{State: State{Value: stateFatal}}
+
+	flow.HandleEvent(Event{Name: "eventOK", Pack: Pack{Name: "packOK"}})
+
+	assert.Len(t, rec.calls, 1)
+	assert.Contains(t, rec.steps(), step)
+}
+
+func TestFlowHandleEvent_ShouldProceedWithStepExecutionWhenJoinIsAnyAndOneDependsOnStepHasFinished(t *testing.T) {
+
+	defer resetStepExecutor()
+	rec := setupStepExecutor(nil, nil)
+
+	step := newStepT("joinAny", "eventOK", "packOK")
+	step.DependsOn = []string{"stepA", "stepB"}
+	step.Join = joinAny
+	flow := newFlowT(step)
+	flow.actions["stepA"] = Action{State: State{Value: statePending}}
+	flow.actions["stepB"] = Action{State: State{Value: stateSuccess}}
+
+	flow.HandleEvent(Event{Name: "eventOK", Pack: Pack{Name: "packOK"}})
+
+	assert.Len(t, rec.calls, 1)
+}
+
+func TestFlowHandleEvent_ShouldProceedWithStepExecutionWhenJoinExpressionIsMet(t *testing.T) {
+
+	defer resetStepExecutor()
+	rec := setupStepExecutor(nil, nil)
+
+	step := newStepT("joinExpression", "eventOK", "packOK")
+	step.DependsOn = []string{"jira_start", "slack"}
+	step.Join = "jira_start.SUCCESS && !slack.FATAL"
+	flow := newFlowT(step, newStepT("jira_start", "eventA", "packA"), newStepT("slack", "eventB", "packB"))
+	flow.actions["jira_start"] = Action{State: State{Value: stateSuccess}}
+
+	flow.HandleEvent(Event{Name: "eventOK", Pack: Pack{Name: "packOK"}})
+
+	assert.Len(t, rec.calls, 1)
+	assert.Contains(t, rec.steps(), step)
+}
+
+func TestFlowHandleEvent_ShouldSkipStepWhenJoinExpressionIsNotMet(t *testing.T) {
+
+	defer resetStepExecutor()
+	rec := setupStepExecutor(nil, nil)
+
+	step := newStepT("joinExpression", "eventOK", "packOK")
+	step.DependsOn = []string{"jira_start", "slack"}
+	step.Join = "jira_start.SUCCESS && !slack.FATAL"
+	flow := newFlowT(step, newStepT("jira_start", "eventA", "packA"), newStepT("slack", "eventB", "packB"))
+	flow.actions["jira_start"] = Action{State: State{Value: stateSuccess}}
+	flow.actions["slack"] = Action{State: State{Value: stateFatal}}
+
+	flow.HandleEvent(Event{Name: "eventOK", Pack: Pack{Name: "packOK"}})
+
+	assert.Len(t, rec.calls, 0)
+}
+
+func TestFlowHandleEvent_ShouldSkipStepWhenJoinExpressionIsInvalid(t *testing.T) {
+
+	defer resetStepExecutor()
+	rec := setupStepExecutor(nil, nil)
+
+	step := newStepT("joinExpression", "eventOK", "packOK")
+	step.DependsOn = []string{"stepA"}
+	step.Join = "stepA.SUCCESS &&"
+	flow := newFlowT(step)
+	flow.actions["stepA"] = Action{State: State{Value: stateSuccess}}
+
+	flow.HandleEvent(Event{Name: "eventOK", Pack: Pack{Name: "packOK"}})
+
+	assert.Len(t, rec.calls, 0)
+}
+
 func TestFlowHandleEvent_ShouldProduceNothingWhenStepExecutesToNil(t *testing.T) {
 
 	defer resetStepExecutor()
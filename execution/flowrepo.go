@@ -25,6 +25,20 @@ import (
 
 type flowMgoRepo struct{}
 
+// gets the current version of the flow, it is not bound to any execution
+func (r flowMgoRepo) Get(name string) (*Flow, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	var flow Flow
+	err := s.DB(mongo.DbName).C(mongo.FlowCollectionId).Find(bson.M{"name": name}).One(&flow)
+	if err == mgo.ErrNotFound {
+		return nil, FlowNotFoundErr
+	}
+	return &flow, err
+}
+
 func (r flowMgoRepo) GetByAction(action Action) (*Flow, error) {
 
 	flow, err := r.getFlow(action.FlowUUID)
@@ -41,14 +55,29 @@ func (r flowMgoRepo) GetByAction(action Action) (*Flow, error) {
 	}
 
 	flow.correlationId = action.CorrelationId
+	flow.correlationKey = action.CorrelationKey
 	flow.context = action.Context
-	flow.actions = map[string]Action{}
+	flow.parent = action.Parent
+	flow.setActions(actions)
 
+	return flow, nil
+}
+
+// Actions are sorted by creation, so the latest action of a repeated step is kept. A flow execution with an action
+// waiting for the execution to be released is queued.
+func (f *Flow) setActions(actions []Action) {
+	f.actions = map[string]Action{}
+	f.iterations = map[string][]Action{}
 	for _, a := range actions {
-		flow.actions[a.StepId] = a
+		if a.State.Value == stateQueued && a.Queue == queueExecution {
+			f.queued = true
+		}
+		if a.Iteration != nil {
+			f.iterations[a.StepId] = append(f.iterations[a.StepId], a)
+			continue
+		}
+		f.actions[a.StepId] = a
 	}
-
-	return flow, nil
 }
 
 func (r flowMgoRepo) FindByEvent(e Event) ([]Flow, error) {
@@ -56,31 +85,101 @@ func (r flowMgoRepo) FindByEvent(e Event) ([]Flow, error) {
 	s := mongo.GetSession()
 	defer s.Close()
 
+	// flows with a correlation key may handle the event in an existing execution with any of their steps
 	flowQuery := bson.M{
-		"steps": bson.M{
-			"$elemMatch": bson.M{
-				"event.packName": e.Pack.Name,
-				"event.name":     e.Name,
-				"dependsOn": bson.M{
-					"$exists": false,
+		"$or": []bson.M{
+			{
+				"steps": bson.M{
+					"$elemMatch": bson.M{
+						"event.packName": e.Pack.Name,
+						"event.name":     e.Name,
+						"dependsOn": bson.M{
+							"$exists": false,
+						},
+					},
+				},
+			},
+			{
+				"correlationKey": bson.M{"$exists": true},
+				"steps": bson.M{
+					"$elemMatch": bson.M{
+						"event.packName": e.Pack.Name,
+						"event.name":     e.Name,
+					},
 				},
 			},
 		},
 	}
 
-	flows := []Flow{}
-	if err := s.DB(mongo.DbName).C(mongo.FlowCollectionId).Find(flowQuery).All(&flows); err != nil {
-		return flows, err
+	var candidates []Flow
+	if err := s.DB(mongo.DbName).C(mongo.FlowCollectionId).Find(flowQuery).All(&candidates); err != nil {
+		return []Flow{}, err
 	}
 
-	for i := range flows {
-		flows[i].correlationId = bson.NewObjectId().Hex()
-		flows[i].context = map[string]string{}
-		flows[i].actions = map[string]Action{}
+	flows := []Flow{}
+	for _, f := range candidates {
+		key := f.correlationKeyOf(e)
+		if key != "" {
+			existing, err := r.getByCorrelationKey(f.Name, key)
+			if err != nil {
+				return flows, err
+			}
+			if existing != nil && existing.acceptsEvent(e) {
+				flows = append(flows, *existing)
+				continue
+			}
+		}
+		if !f.isStartedBy(e) {
+			continue
+		}
+		f.correlationId = bson.NewObjectId().Hex()
+		f.correlationKey = key
+		f.context = map[string]string{}
+		f.actions = map[string]Action{}
+		flows = append(flows, f)
 	}
 	return flows, nil
 }
 
+// Gets the latest running or queued execution of the flow with the correlation key, bound to the version of the flow
+// it runs. Its context is the one of its latest action. Finished and cancelled executions are not correlated.
+func (r flowMgoRepo) getByCorrelationKey(flowName, key string) (*Flow, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	var record Record
+	err := s.DB(mongo.DbName).C(mongo.ExecutionCollectionId).
+		Find(bson.M{"flowName": flowName, "correlationKey": key, "status": bson.M{"$in": []string{executionRunning, executionQueued}}}).
+		Sort("-startedAt").
+		One(&record)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	actions, err := actionRepo.FindCorrelated(record.CorrelationId)
+	if err != nil || len(actions) == 0 {
+		return nil, err
+	}
+	latest, err := actionRepo.Get(actions[len(actions)-1].Id)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByAction(*latest)
+}
+
+func (r flowMgoRepo) FindScheduled() ([]Flow, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	flows := []Flow{}
+	return flows, s.DB(mongo.DbName).C(mongo.FlowCollectionId).Find(bson.M{"schedule": bson.M{"$exists": true}}).All(&flows)
+}
+
 func (r flowMgoRepo) getFlow(uuid string) (*Flow, error) {
 
 	s := mongo.GetSession()
@@ -26,6 +26,28 @@ import (
 	"testing"
 )
 
+func TestGet_ShouldReturnFlowForGivenName(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	want := Flow{Name: "flowA", UUID: "flowUUID", Steps: []Step{{Id: "stepA"}}}
+	mongoT.Insert(t, mongo.FlowCollectionId, want)
+	mongoT.Insert(t, mongo.FlowCollectionId, Flow{Name: "flowB", UUID: "otherUUID"})
+
+	got, err := flowRepo.Get("flowA")
+	require.NoError(t, err)
+
+	assert.Equal(t, want, *got)
+}
+
+func TestGet_ShouldReturnFlowNotFoundErrWhenFlowDoesNotExist(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+
+	_, err := flowRepo.Get("nonExistingFlow")
+
+	assert.Equal(t, FlowNotFoundErr, err)
+}
+
 func TestGetByAction_ShouldReturnFlowWithAllCorrelatedActions(t *testing.T) {
 
 	mongoT.DropDatabase(t)
@@ -118,6 +140,102 @@ func TestFindCandidatesByTriggerEvent_ShouldReturnEmptyListIfThereAreNoCandidate
 	assert.Empty(t, got)
 }
 
+func TestFindByEvent_ShouldRouteEventIntoExecutionWithSameCorrelationKey(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	insertCorrelatedExecutionT(t, executionRunning)
+
+	got, err := flowRepo.FindByEvent(Event{Name: "ApprovalReceived", Pack: Pack{Name: "Slack"}, Payload: map[string]interface{}{"incidentId": "INC-42"}})
+	require.NoError(t, err)
+
+	require.Len(t, got, 1)
+	assert.Equal(t, "incidentV1", got[0].UUID)
+	assert.Equal(t, "execA", got[0].correlationId)
+	assert.Equal(t, "INC-42", got[0].correlationKey)
+	assert.Equal(t, map[string]string{"team": "ops"}, got[0].context)
+	assert.Contains(t, got[0].actions, "ask")
+}
+
+func TestFindByEvent_ShouldNotStartExecutionWithDependentStepOfOtherCorrelationKey(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	insertCorrelatedExecutionT(t, executionRunning)
+
+	got, err := flowRepo.FindByEvent(Event{Name: "ApprovalReceived", Pack: Pack{Name: "Slack"}, Payload: map[string]interface{}{"incidentId": "INC-43"}})
+	require.NoError(t, err)
+
+	assert.Empty(t, got)
+}
+
+func TestFindByEvent_ShouldStartNewExecutionWhenCorrelatedExecutionDoesNotAcceptEvent(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	insertCorrelatedExecutionT(t, executionRunning)
+
+	got, err := flowRepo.FindByEvent(Event{Name: "IncidentOpened", Pack: Pack{Name: "Monitor"}, Payload: map[string]interface{}{"incidentId": "INC-42"}})
+	require.NoError(t, err)
+
+	require.Len(t, got, 1)
+	assert.Equal(t, "incidentV2", got[0].UUID)
+	assert.NotEqual(t, "execA", got[0].correlationId)
+	assert.NotEmpty(t, got[0].correlationId)
+	assert.Equal(t, "INC-42", got[0].correlationKey)
+	assert.Empty(t, got[0].actions)
+}
+
+func TestFindByEvent_ShouldNotRouteEventIntoFinishedExecutionWithSameCorrelationKey(t *testing.T) {
+
+	for _, status := range []string{executionSucceeded, executionFailed, executionTimedOut, executionCancelled} {
+		mongoT.DropDatabase(t)
+		insertCorrelatedExecutionT(t, status)
+
+		got, err := flowRepo.FindByEvent(Event{Name: "ApprovalReceived", Pack: Pack{Name: "Slack"}, Payload: map[string]interface{}{"incidentId": "INC-42"}})
+		require.NoError(t, err)
+		assert.Empty(t, got, status)
+
+		got, err = flowRepo.FindByEvent(Event{Name: "IncidentOpened", Pack: Pack{Name: "Monitor"}, Payload: map[string]interface{}{"incidentId": "INC-42"}})
+		require.NoError(t, err)
+		require.Len(t, got, 1, status)
+		assert.NotEqual(t, "execA", got[0].correlationId, status)
+	}
+}
+
+// execution "execA" of version incidentV1 of the flow, with key INC-42, waiting for the approval
+func insertCorrelatedExecutionT(t *testing.T, status string) {
+
+	approve := Step{Id: "approve", DependsOn: []string{"ask"}, Event: EventDef{Name: "ApprovalReceived", PackName: "Slack"}}
+	ask := Step{Id: "ask", Event: EventDef{Name: "IncidentOpened", PackName: "Monitor"}}
+	key := "{{ Event.Payload.incidentId }}"
+	mongoT.Insert(t, mongo.FlowCollectionId, Flow{Name: "incident", UUID: "incidentV2", CorrelationKey: key, Steps: []Step{ask, approve}})
+	mongoT.Insert(t, mongo.HistoryCollectionId, Flow{Name: "incident", UUID: "incidentV1", CorrelationKey: key, Steps: []Step{ask, approve}})
+
+	mongoT.Insert(t, mongo.ExecutionCollectionId, Record{CorrelationId: "execA", CorrelationKey: "INC-42", FlowName: "incident", FlowUUID: "incidentV1", Status: status})
+	mongoT.Insert(t, mongo.ActionCollectionId, Action{
+		Id:             bson.NewObjectId().Hex(),
+		FlowName:       "incident",
+		FlowUUID:       "incidentV1",
+		CorrelationId:  "execA",
+		CorrelationKey: "INC-42",
+		StepId:         "ask",
+		Context:        map[string]string{"team": "ops"},
+		State:          State{Value: stateSuccess},
+	})
+}
+
+func TestFindScheduled_ShouldReturnFlowsWithSchedule(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+
+	scheduled := Flow{Name: "flowA", UUID: "flowA", Schedule: &Schedule{Cron: "0 9 * * *", Timezone: "Europe/London"}}
+	mongoT.Insert(t, mongo.FlowCollectionId, scheduled)
+	mongoT.Insert(t, mongo.FlowCollectionId, Flow{Name: "flowB", UUID: "flowB"})
+
+	got, err := flowRepo.FindScheduled()
+	require.NoError(t, err)
+
+	assert.Equal(t, []Flow{scheduled}, got)
+}
+
 var flowPrivateRepo = flowMgoRepo{}
 
 func TestGetFlow_ShouldReturnFlowForGivenUUID(t *testing.T) {
@@ -17,6 +17,7 @@ limitations under the License.
 package execution
 
 import (
+	"errors"
 	"github.com/rs/zerolog/log"
 )
 
@@ -48,12 +49,29 @@ func (flowService) HandleAction(a Action) {
 		return
 	}
 
-	flow.HandleEvent(a.Result)
+	if a.State.Value == stateFatal && a.Handler == "" {
+		flow.failed = &a
+	}
+	if a.Iteration != nil {
+		flow.completeForEach(a.StepId)
+		// the forEach step may still be running, a failed iteration is handled straight away
+		flow.executeHandlerSteps(a.Result)
+	} else {
+		flow.HandleEvent(a.Result)
+	}
+	if flow.parent != nil {
+		completeParentAction(*flow)
+	}
+	flow.releaseQueued(a)
 }
 
 var flowRepo FlowRepository = flowMgoRepo{}
 
 type FlowRepository interface {
+	Get(name string) (*Flow, error)
 	GetByAction(a Action) (*Flow, error)
 	FindByEvent(e Event) ([]Flow, error)
+	FindScheduled() ([]Flow, error)
 }
+
+var FlowNotFoundErr = errors.New("flow not found")
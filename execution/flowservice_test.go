@@ -101,11 +101,44 @@ func TestHandleAction_ShouldTriggerHandleEventOnExistingFlowForProvidedAction(t
 	assert.Equal(t, expectedAction, actualAction)
 }
 
+func TestHandleAction_ShouldCheckIfSubflowHasFinished(t *testing.T) {
+
+	//Given
+	defer resetFlowRepo()
+	parent := &ParentAction{ActionId: "parentAction", CorrelationId: "parentFlow", FlowName: "deploy"}
+	flowRepo = mockFlowRepo{
+		getByAction: func(a Action) (*Flow, error) {
+			return &Flow{correlationId: "childFlow", parent: a.Parent}, nil
+		},
+	}
+	defer resetFlowEventHandler()
+	flowEventHandler = func(f *Flow, e Event) {}
+
+	defer resetCompleteParentAction()
+	var child Flow
+	completeParentAction = func(f Flow) {
+		child = f
+	}
+
+	//When
+	flowService{}.HandleAction(Action{CorrelationId: "childFlow", Parent: parent})
+
+	//Then
+	assert.Equal(t, "childFlow", child.correlationId)
+	assert.Equal(t, parent, child.parent)
+}
+
 // --- mocks & helpers ---
 
 type mockFlowRepo struct {
-	getByAction func(a Action) (*Flow, error)
-	findByEvent func(e Event) ([]Flow, error)
+	get           func(name string) (*Flow, error)
+	getByAction   func(a Action) (*Flow, error)
+	findByEvent   func(e Event) ([]Flow, error)
+	findScheduled func() ([]Flow, error)
+}
+
+func (r mockFlowRepo) Get(name string) (*Flow, error) {
+	return r.get(name)
 }
 
 func (r mockFlowRepo) GetByAction(a Action) (*Flow, error) {
@@ -116,6 +149,10 @@ func (r mockFlowRepo) FindByEvent(e Event) ([]Flow, error) {
 	return r.findByEvent(e)
 }
 
+func (r mockFlowRepo) FindScheduled() ([]Flow, error) {
+	return r.findScheduled()
+}
+
 func resetFlowRepo()         { flowRepo = flowMgoRepo{} }
 func resetFlowEventHandler() { flowEventHandler = flowEventHandlerFn }
 
@@ -0,0 +1,211 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	encodingjson "encoding/json"
+	"fmt"
+	"github.com/ExpediaGroup/flyte/template"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const forEachCompletedEventName = "ForEachCompleted"
+
+// upper limit of the actions a single forEach step can create
+const maxForEachItems = 1000
+
+// set on the action of a forEach step, it completes once its iterations have finished
+type ForEach struct {
+	Items int    `bson:"items"`
+	Join  string `bson:"join,omitempty"`
+}
+
+// set on the actions a forEach step creates for each item of its list
+type Iteration struct {
+	Index int         `bson:"index"`
+	Item  interface{} `bson:"item,omitempty"`
+}
+
+// A forEach step creates an action for each item of its list, with the item and its index available to the command
+// templates, and one action for the step itself. The step action is not run, it completes with the results
+// of the iterations, so the steps depending on it are executed once.
+func (s Step) executeForEach(e Event, ctx map[string]string) (*Action, error) {
+
+	items, err := s.forEachItems(e, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state := State{Value: stateNew, Time: time.Now().UTC()}
+	a := &Action{
+		Id:       bson.NewObjectId().Hex(),
+		Name:     s.Command.Name,
+		PackName: s.Command.PackName,
+		State:    state,
+		States:   []State{state},
+		Attempt:  1,
+		Trigger:  e,
+		Context:  ctx,
+		StepId:   s.Id,
+		ForEach:  &ForEach{Items: len(items), Join: s.ForEachJoin},
+	}
+	a.setState(statePending)
+
+	for i, item := range items {
+		tctx := templateContext(e, ctx)
+		tctx["item"] = item
+		tctx["index"] = i
+		iteration, err := s.Command.createActionIn(tctx, e, ctx)
+		if err != nil {
+			return nil, err
+		}
+		iteration.StepId = s.Id
+		iteration.Iteration = &Iteration{Index: i, Item: item}
+		a.iterations = append(a.iterations, *iteration)
+	}
+	return a, nil
+}
+
+func (s Step) forEachItems(e Event, ctx map[string]string) ([]interface{}, error) {
+
+	v, err := template.Evaluate(s.ForEach, templateContext(e, ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error resolving forEach with event=%+v and ctx=%v: %v", e, ctx, err)
+	}
+
+	var items []interface{}
+	if str, ok := v.(string); ok {
+		// the template has been rendered, e.g. into a json list
+		if err := encodingjson.Unmarshal([]byte(str), &items); err != nil {
+			return nil, fmt.Errorf("forEach=%q does not resolve to a list: %v", s.ForEach, err)
+		}
+	} else {
+		list := reflect.ValueOf(v)
+		if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+			return nil, fmt.Errorf("forEach=%q does not resolve to a list", s.ForEach)
+		}
+		for i := 0; i < list.Len(); i++ {
+			items = append(items, list.Index(i).Interface())
+		}
+	}
+
+	if len(items) > maxForEachItems {
+		return nil, fmt.Errorf("forEach=%q resolves to %d items, the maximum is %d", s.ForEach, len(items), maxForEachItems)
+	}
+	return items, nil
+}
+
+// Completes the action of a forEach step once all its iterations have finished, or any of them with the "any" join,
+// and hands its ForEachCompleted result to the flow. Iterations finishing later are recorded but not handled anymore.
+func (f *Flow) completeForEach(stepId string) {
+
+	a, ok := f.actions[stepId]
+	if !ok || a.ForEach == nil || a.State.Value != statePending {
+		return
+	}
+
+	// a repeated forEach step has the iterations of its previous repetitions too
+	var iterations []Action
+	for _, it := range f.iterations[stepId] {
+		if it.Repetition == a.Repetition {
+			iterations = append(iterations, it)
+		}
+	}
+	finished := 0
+	for _, it := range iterations {
+		if it.hasFinished() {
+			finished++
+		}
+	}
+	switch a.ForEach.Join {
+	case joinAny:
+		if finished == 0 && a.ForEach.Items > 0 {
+			return
+		}
+	default:
+		// iterations are added one by one, the first ones may finish before the last ones are saved
+		if finished < a.ForEach.Items {
+			return
+		}
+	}
+
+	// the actions of the flow have only the fields needed to execute it, the whole action is updated
+	action, err := actionRepo.Get(a.Id)
+	if err != nil {
+		log.Err(err).Msgf("Error getting forEach actionId=%s", a.Id)
+		return
+	}
+	a = *action
+	// another instance may have completed the action already, in which case finish fails
+	if err := a.finish(forEachResult(iterations)); err != nil {
+		log.Debug().Msgf("Cannot complete forEach actionId=%s: %v", a.Id, err)
+		return
+	}
+	f.actions[stepId] = a
+
+	log.Info().
+		Str("ActionId", a.Id).
+		Str("CorrelationId", a.CorrelationId).
+		Str("FlowName", a.FlowName).
+		Str("StepId", stepId).
+		Int("Finished", finished).
+		Int("Items", a.ForEach.Items).
+		Msg("ForEach step finished")
+
+	flowEventHandlerFn(f, a.Result)
+}
+
+func forEachResult(iterations []Action) Event {
+
+	sort.Slice(iterations, func(i, j int) bool {
+		return iterations[i].Iteration.Index < iterations[j].Iteration.Index
+	})
+
+	results := []interface{}{}
+	succeeded, failed := 0, 0
+	for _, it := range iterations {
+		result := map[string]interface{}{
+			"index": it.Iteration.Index,
+			"item":  it.Iteration.Item,
+			"state": it.State.Value,
+		}
+		switch {
+		case it.State.Value == stateSuccess:
+			succeeded++
+		case it.hasFinished():
+			failed++
+		}
+		if it.hasFinished() {
+			result["event"] = map[string]interface{}{
+				"name":     it.Result.Name,
+				"packName": it.Result.Pack.Name,
+				"payload":  it.Result.Payload,
+			}
+		}
+		results = append(results, result)
+	}
+
+	return builtinEvent(forEachCompletedEventName, map[string]interface{}{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    failed,
+	})
+}
@@ -0,0 +1,349 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestExecuteStep_ShouldCreateActionForEachItem(t *testing.T) {
+
+	//Given
+	step := newForEachStepT("{{ Event.Payload.hosts }}")
+	e := Event{Name: "Alert", Pack: Pack{Name: "Monitor"}, Payload: map[string]interface{}{"hosts": []interface{}{"db1", "db2"}}}
+
+	//When
+	a, err := executeStep(step, e, map[string]string{"env": "prod"})
+
+	//Then
+	require.NoError(t, err)
+	assert.Equal(t, "restart", a.StepId)
+	assert.Equal(t, "Restart", a.Name)
+	assert.Equal(t, &ForEach{Items: 2, Join: joinAny}, a.ForEach)
+	assert.Equal(t, statePending, a.State.Value)
+
+	require.Len(t, a.iterations, 2)
+	for i, host := range []string{"db1", "db2"} {
+		it := a.iterations[i]
+		assert.Equal(t, "restart", it.StepId)
+		assert.Equal(t, stateNew, it.State.Value)
+		assert.Equal(t, &Iteration{Index: i, Item: host}, it.Iteration)
+		assert.Equal(t, map[string]interface{}{"host": host, "index": []interface{}{"prod", string(rune('0' + i))}}, it.Input)
+		assert.NotEqual(t, a.Id, it.Id)
+	}
+}
+
+func TestForEachItems_ShouldAcceptRenderedJsonList(t *testing.T) {
+
+	step := newForEachStepT(`["{{ Event.Payload.host }}", "db2"]`)
+
+	items, err := step.forEachItems(Event{Payload: map[string]interface{}{"host": "db1"}}, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"db1", "db2"}, items)
+}
+
+func TestForEachItems_ShouldReturnErrorWhenNotResolvedToList(t *testing.T) {
+
+	cases := map[string]string{
+		"missing":     "{{ Event.Payload.missing }}",
+		"map":         "{{ Event.Payload }}",
+		"not json":    "db1,db2",
+		"invalid tag": "{{ Event.Payload| }}",
+	}
+	for name, forEach := range cases {
+		_, err := newForEachStepT(forEach).forEachItems(Event{Payload: map[string]interface{}{"host": "db1"}}, nil)
+
+		assert.Error(t, err, name)
+	}
+}
+
+func TestFlowHandleEvent_ShouldAddStepActionAndIterations(t *testing.T) {
+
+	//Given
+	defer resetActionRepo()
+	var added []Action
+	actionRepo = mockActionRepo{add: func(a Action) error {
+		added = append(added, a)
+		return nil
+	}}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	flow := newFlowT(newForEachStepT("{{ Event.Payload.hosts }}"))
+
+	//When
+	flow.HandleEvent(Event{Name: "Alert", Pack: Pack{Name: "Monitor"}, Payload: map[string]interface{}{"hosts": []interface{}{"db1", "db2"}}})
+
+	//Then
+	require.Len(t, added, 3)
+	assert.NotNil(t, added[0].ForEach)
+	assert.Equal(t, statePending, added[0].State.Value)
+	assert.Equal(t, 0, added[1].Iteration.Index)
+	assert.Equal(t, 1, added[2].Iteration.Index)
+	assert.Equal(t, added[0].Id, flow.actions["restart"].Id)
+	assert.Len(t, flow.iterations["restart"], 2)
+}
+
+func TestFlowHandleEvent_ShouldCompleteForEachStepWithEmptyListStraightAway(t *testing.T) {
+
+	//Given
+	defer resetActionRepo()
+	var added, updated Action
+	actionRepo = mockActionRepo{
+		add: func(a Action) error {
+			added = a
+			return nil
+		},
+		get: func(actionId string) (*Action, error) { return &added, nil },
+		update: func(a Action) error {
+			updated = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }, update: func(a Action) error { return nil }}
+
+	flow := newFlowT(newForEachStepT("{{ Event.Payload.hosts }}"))
+
+	//When
+	flow.HandleEvent(Event{Name: "Alert", Pack: Pack{Name: "Monitor"}, Payload: map[string]interface{}{"hosts": []interface{}{}}})
+
+	//Then
+	assert.Equal(t, stateSuccess, updated.State.Value)
+	assert.Equal(t, forEachCompletedEventName, updated.Result.Name)
+	assert.Equal(t, map[string]interface{}{"results": []interface{}{}, "succeeded": 0, "failed": 0}, updated.Result.Payload)
+}
+
+func TestCompleteForEach_ShouldWaitForAllIterationsToFinish(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{update: func(a Action) error {
+		t.Fatal("Should not get here")
+		return nil
+	}}
+
+	flow := newForEachFlowT("", stateSuccess, statePending)
+
+	flow.completeForEach("restart")
+
+	assert.Equal(t, statePending, flow.actions["restart"].State.Value)
+}
+
+func TestCompleteForEach_ShouldHandleResultsOfAllIterations(t *testing.T) {
+
+	//Given
+	flow := newForEachFlowT(joinAll, stateSuccess, stateFatal)
+
+	defer resetActionRepo()
+	var updated Action
+	actionRepo = mockActionRepo{
+		get: getForEachActionT(flow),
+		update: func(a Action) error {
+			updated = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+	defer resetStepExecutor()
+	rec := setupStepExecutor(nil, nil)
+
+	//When
+	flow.completeForEach("restart")
+
+	//Then
+	assert.Equal(t, stateSuccess, updated.State.Value)
+	assert.Equal(t, updated, flow.actions["restart"])
+
+	require.Len(t, rec.calls, 1)
+	assert.Equal(t, "report", rec.calls[0].step.Id)
+	e := rec.calls[0].event
+	assert.Equal(t, forEachCompletedEventName, e.Name)
+	assert.Equal(t, builtinPackName, e.Pack.Name)
+	assert.Equal(t, map[string]interface{}{
+		"results": []interface{}{
+			map[string]interface{}{"index": 0, "item": "db0", "state": stateSuccess, "event": map[string]interface{}{"name": "Restarted", "packName": "Ssh", "payload": "db0"}},
+			map[string]interface{}{"index": 1, "item": "db1", "state": stateFatal, "event": map[string]interface{}{"name": "Restarted", "packName": "Ssh", "payload": "db1"}},
+		},
+		"succeeded": 1,
+		"failed":    1,
+	}, e.Payload)
+}
+
+func TestCompleteForEach_ShouldCompleteOnFirstFinishedIterationWithAnyJoin(t *testing.T) {
+
+	flow := newForEachFlowT(joinAny, statePending, stateSuccess)
+
+	defer resetActionRepo()
+	var updated Action
+	actionRepo = mockActionRepo{
+		get: getForEachActionT(flow),
+		update: func(a Action) error {
+			updated = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+	defer resetStepExecutor()
+	rec := setupStepExecutor(nil, nil)
+
+	flow.completeForEach("restart")
+
+	assert.Equal(t, stateSuccess, updated.State.Value)
+	require.Len(t, rec.calls, 1)
+	results := rec.calls[0].event.Payload.(map[string]interface{})["results"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"index": 0, "item": "db0", "state": statePending}, results[0])
+	assert.Equal(t, 1, rec.calls[0].event.Payload.(map[string]interface{})["succeeded"])
+}
+
+func TestCompleteForEach_ShouldUpdateWholeActionOfStep(t *testing.T) {
+
+	//Given
+	// the actions of a flow read from the repo have only some of their fields
+	flow := newForEachFlowT(joinAll, stateSuccess, stateSuccess)
+	projected := flow.actions["restart"]
+
+	stored := projected
+	stored.Name = "Restart"
+	stored.PackName = "Ssh"
+	stored.CorrelationId = "abc"
+	stored.FlowName = "restart_hosts"
+	stored.FlowUUID = "123"
+	stored.Context = map[string]string{"env": "prod"}
+	stored.Trigger = Event{Name: "Alert", Pack: Pack{Name: "Monitor"}}
+	stored.States = []State{projected.State}
+
+	defer resetActionRepo()
+	var updated Action
+	actionRepo = mockActionRepo{
+		get: func(actionId string) (*Action, error) {
+			a := stored
+			return &a, nil
+		},
+		update: func(a Action) error {
+			updated = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	var audited Action
+	auditRepo = mockAuditRepo{update: func(a Action) error {
+		audited = a
+		return nil
+	}}
+	defer resetStepExecutor()
+	setupStepExecutor(nil, nil)
+
+	//When
+	flow.completeForEach("restart")
+
+	//Then
+	assert.Equal(t, stateSuccess, updated.State.Value)
+	assert.Equal(t, "abc", updated.CorrelationId)
+	assert.Equal(t, "123", updated.FlowUUID)
+	assert.Equal(t, "Restart", updated.Name)
+	assert.Equal(t, "Ssh", updated.PackName)
+	assert.Equal(t, stored.Trigger, updated.Trigger)
+	assert.Equal(t, stored.Context, updated.Context)
+	assert.Equal(t, []State{projected.State, updated.State}, updated.States)
+	assert.Equal(t, updated, audited)
+}
+
+func TestHandleAction_ShouldCompleteForEachStepInsteadOfHandlingIterationResult(t *testing.T) {
+
+	//Given
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		getByAction: func(a Action) (*Flow, error) {
+			flow := newForEachFlowT(joinAll, stateSuccess, stateSuccess)
+			return &flow, nil
+		},
+	}
+	defer resetFlowEventHandler()
+	flowEventHandler = func(f *Flow, e Event) {
+		t.Fatal("Should not get here")
+	}
+	defer resetActionRepo()
+	var updated Action
+	actionRepo = mockActionRepo{
+		get: getForEachActionT(newForEachFlowT(joinAll, stateSuccess, stateSuccess)),
+		update: func(a Action) error {
+			updated = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+	defer resetStepExecutor()
+	setupStepExecutor(nil, nil)
+
+	//When
+	flowService{}.HandleAction(Action{StepId: "restart", Iteration: &Iteration{Index: 1}, Result: Event{Name: "Restarted"}})
+
+	//Then
+	assert.Equal(t, "forEach", updated.Id)
+	assert.Equal(t, stateSuccess, updated.State.Value)
+}
+
+func newForEachStepT(forEach string) Step {
+	return Step{
+		Id:          "restart",
+		Event:       EventDef{Name: "Alert", PackName: "Monitor"},
+		ForEach:     forEach,
+		ForEachJoin: joinAny,
+		Command: Command{
+			Name:     "Restart",
+			PackName: "Ssh",
+			Input:    map[string]interface{}{"host": "{{ item }}", "index": []interface{}{"{{ Context.env }}", "{{ index }}"}},
+		},
+	}
+}
+
+// flow with a forEach step "restart" whose iterations are in the given states and a step "report" depending on it
+func newForEachFlowT(join string, iterationStates ...string) Flow {
+
+	report := newStepT("report", forEachCompletedEventName, builtinPackName)
+	report.DependsOn = []string{"restart"}
+	flow := newFlowT(newForEachStepT(""), report)
+
+	a := Action{Id: "forEach", StepId: "restart", ForEach: &ForEach{Items: len(iterationStates), Join: join}}
+	a.setState(statePending)
+	flow.actions["restart"] = a
+
+	flow.iterations = map[string][]Action{}
+	for i, state := range iterationStates {
+		item := "db" + string(rune('0'+i))
+		it := Action{StepId: "restart", Iteration: &Iteration{Index: i, Item: item}, State: State{Value: state}}
+		if state != statePending {
+			it.Result = Event{Name: "Restarted", Pack: Pack{Name: "Ssh"}, Payload: item}
+		}
+		flow.iterations["restart"] = append(flow.iterations["restart"], it)
+	}
+	return flow
+}
+
+// returns the forEach action of the flow created by newForEachFlowT, as the repo does
+func getForEachActionT(f Flow) func(actionId string) (*Action, error) {
+	return func(actionId string) (*Action, error) {
+		a := f.actions["restart"]
+		return &a, nil
+	}
+}
@@ -17,10 +17,14 @@ limitations under the License.
 package execution
 
 import (
+	"fmt"
 	"github.com/ExpediaGroup/flyte/httputil"
 	"github.com/husobee/vestigo"
 	"github.com/rs/zerolog/log"
+	"gopkg.in/mgo.v2"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func PostEvent(w http.ResponseWriter, r *http.Request) {
@@ -87,12 +91,18 @@ func CompleteAction(w http.ResponseWriter, r *http.Request) {
 	log.Debug().Msgf("Event Contents: Event=%+v", result)
 
 	actionId := vestigo.Param(r, "actionId")
-	action, err := pack.CompleteAction(actionId, *result)
+	action, err := pack.CompleteAction(actionId, r.URL.Query().Get("leaseToken"), *result)
 	if err != nil {
 		switch err {
 		case ActionNotFoundErr:
 			log.Info().Msgf("Action actionId=%s packId=%s not found", actionId, pack.Id)
 			w.WriteHeader(http.StatusNotFound)
+		case ActionCancelledErr:
+			log.Info().Msgf("Action actionId=%s packId=%s has been cancelled, result is discarded", actionId, pack.Id)
+			w.WriteHeader(http.StatusGone)
+		case ActionLeaseLostErr, mgo.ErrNotFound:
+			log.Info().Msgf("Action actionId=%s packId=%s lease has been lost, result is discarded", actionId, pack.Id)
+			w.WriteHeader(http.StatusConflict)
 		default:
 			log.Err(err).Msgf("Error completing actionId=%s with result=%+v", actionId, result)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -111,10 +121,16 @@ func CompleteAction(w http.ResponseWriter, r *http.Request) {
 		Str("ResultEventPackId", action.Result.Pack.Id).
 		Str("ResultEvent", action.Result.Name).
 		Bool("ResultEventIsFatal", action.Result.isFatal()).
+		Int("Attempt", action.Attempt).
 		Msg("Action completed")
 
-	flowSvc.HandleEvent(*result)
-	go flowSvc.HandleAction(*action)
+	// the result of an attempt that is retried is not handed over to the flows, the step has not finished yet
+	if action.isRetrying() {
+		log.Info().Msgf("Action actionId=%s will be retried, attempt=%d", action.Id, action.Attempt)
+	} else {
+		flowSvc.HandleEvent(*result)
+		go flowSvc.HandleAction(*action)
+	}
 	w.WriteHeader(http.StatusAccepted)
 }
 
@@ -137,7 +153,35 @@ func TakeAction(w http.ResponseWriter, r *http.Request) {
 	pack.UpdateLastSeen()
 
 	actionName := r.FormValue("actionName")
-	action, err := pack.TakeAction(actionName)
+	wait, err := takeActionWait(r)
+	if err != nil {
+		log.Info().Msgf("Invalid wait for packId=%s: %v", pack.Id, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	max, err := takeActionMax(r)
+	if err != nil {
+		log.Info().Msgf("Invalid max for packId=%s: %v", pack.Id, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if max > 0 {
+		takeActionBatch(w, r, *pack, actionName, max, wait)
+		return
+	}
+
+	var action *Action
+	if wait > 0 {
+		var actions []Action
+		actions, err = pack.WaitAndTakeActions(r.Context(), actionName, 1, wait)
+		if len(actions) > 0 {
+			action = &actions[0]
+		}
+	} else {
+		action, err = pack.TakeAction(actionName)
+	}
 
 	if err != nil {
 		log.Err(err).Msgf("Could not take action for packId=%s and actionName=%s", pack.Id, actionName)
@@ -155,6 +199,128 @@ func TakeAction(w http.ResponseWriter, r *http.Request) {
 	httputil.WriteResponse(w, r, toActionResponse(r, packId, *action))
 }
 
+// takes a batch of actions and responds with a list of them
+func takeActionBatch(w http.ResponseWriter, r *http.Request, pack Pack, actionName string, max int, wait time.Duration) {
+
+	var actions []Action
+	var err error
+	if wait > 0 {
+		actions, err = pack.WaitAndTakeActions(r.Context(), actionName, max, wait)
+	} else {
+		actions, err = pack.TakeActions(actionName, max)
+	}
+
+	if err != nil {
+		log.Err(err).Msgf("Could not take actions for packId=%s and actionName=%s", pack.Id, actionName)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if len(actions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	for _, a := range actions {
+		log.Info().Msgf("Action actionId=%s taken", a.Id)
+	}
+
+	httputil.WriteResponse(w, r, toActionsResponse(r, pack.Id, actions))
+}
+
+const (
+	// the longest a pack can wait for a new action in a single take request
+	maxTakeActionWait = 60 * time.Second
+	// the most actions a pack can take in a single take request
+	maxTakeActions = 100
+)
+
+func takeActionWait(r *http.Request) (time.Duration, error) {
+	v := r.FormValue("wait")
+	if v == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if wait < 0 {
+		return 0, fmt.Errorf("negative wait=%s", v)
+	}
+	if wait > maxTakeActionWait {
+		return maxTakeActionWait, nil
+	}
+	return wait, nil
+}
+
+// optional max query parameter, the number of actions to take at once, capped at maxTakeActions.
+// Zero means the parameter is not set and a single action is taken.
+func takeActionMax(r *http.Request) (int, error) {
+	v := r.FormValue("max")
+	if v == "" {
+		return 0, nil
+	}
+	max, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+	if max < 1 {
+		return 0, fmt.Errorf("max=%s must be at least 1", v)
+	}
+	if max > maxTakeActions {
+		return maxTakeActions, nil
+	}
+	return max, nil
+}
+
+func Heartbeat(w http.ResponseWriter, r *http.Request) {
+
+	packId := vestigo.Param(r, "packId")
+	pack, err := packRepo.Get(packId)
+	if err != nil {
+		switch err {
+		case PackNotFoundErr:
+			log.Info().Msgf("Pack packId=%s not found", packId)
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			log.Err(err).Send()
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	pack.UpdateLastSeen()
+
+	actionId := vestigo.Param(r, "actionId")
+	action, err := pack.Heartbeat(actionId, r.URL.Query().Get("leaseToken"))
+	if err != nil {
+		switch err {
+		case ActionNotFoundErr:
+			log.Info().Msgf("Action actionId=%s packId=%s not found", actionId, pack.Id)
+			w.WriteHeader(http.StatusNotFound)
+		case ActionCancelledErr:
+			log.Info().Msgf("Action actionId=%s packId=%s has been cancelled, lease cannot be extended", actionId, pack.Id)
+			w.WriteHeader(http.StatusGone)
+		case ActionNotPendingErr:
+			log.Info().Msgf("Action actionId=%s packId=%s is not pending, lease cannot be extended", actionId, pack.Id)
+			w.WriteHeader(http.StatusConflict)
+		case ActionLeaseLostErr, mgo.ErrNotFound:
+			log.Info().Msgf("Action actionId=%s packId=%s lease has been lost, lease cannot be extended", actionId, pack.Id)
+			w.WriteHeader(http.StatusConflict)
+		case ActionNotLeasedErr:
+			log.Info().Msgf("Action actionId=%s packId=%s has been taken without a lease, lease cannot be extended", actionId, pack.Id)
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			log.Err(err).Msgf("Error extending lease of actionId=%s", actionId)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Debug().Msgf("Action actionId=%s lease extended until %s", action.Id, action.LeaseExpiresAt)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 var flowSvc FlowService = flowService{}
 
 type FlowService interface {
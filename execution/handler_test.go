@@ -17,6 +17,7 @@ limitations under the License.
 package execution
 
 import (
+	"context"
 	"errors"
 	"github.com/ExpediaGroup/flyte/httputil"
 	"github.com/stretchr/testify/assert"
@@ -155,7 +156,7 @@ func TestCompleteAction_ShouldCompleteActionAndHandleIt(t *testing.T) {
 	}
 
 	defer resetCompleteAction()
-	completeAction = func(p Pack, actionId string, result Event) (*Action, error) {
+	completeAction = func(p Pack, actionId, leaseToken string, result Event) (*Action, error) {
 		if p.Id == pack.Id && actionId == "123" {
 			return &Action{Id: actionId, PackName: p.Name, Result: result, State: State{Value: stateSuccess}}, nil
 		}
@@ -198,6 +199,45 @@ func TestCompleteAction_ShouldCompleteActionAndHandleIt(t *testing.T) {
 	assert.Equal(t, "Slack", recPackId)
 }
 
+func TestCompleteAction_ShouldNotHandleActionThatWillBeRetried(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetCompleteAction()
+	completeAction = func(p Pack, actionId, leaseToken string, result Event) (*Action, error) {
+		return &Action{Id: actionId, Result: result, State: State{Value: stateNew}, Attempt: 2}, nil
+	}
+
+	defer resetFlowService()
+	handledEvent := false
+	handledAction := false
+	flowSvc = mockFlowService{
+		handleEvent: func(e Event) {
+			handledEvent = true
+		},
+		handleAction: func(a Action) {
+			handledAction = true
+		},
+	}
+
+	w := httptest.NewRecorder()
+	CompleteAction(w, httptest.NewRequest(http.MethodPost,
+		"/v1/packs/Slack/actions/123/result?:packId=Slack&:actionId=123", eventBody()))
+
+	assert.Equal(t, http.StatusAccepted, w.Result().StatusCode)
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, handledEvent)
+	assert.False(t, handledAction)
+}
+
 func TestCompleteAction_ShouldReturn404WhenPackDoesNotExist(t *testing.T) {
 
 	defer resetPackRepo()
@@ -270,7 +310,7 @@ func TestCompleteAction_ShouldReturn404ForNonExistingAction(t *testing.T) {
 	}
 
 	defer resetCompleteAction()
-	completeAction = func(pack Pack, actionId string, result Event) (*Action, error) {
+	completeAction = func(pack Pack, actionId, leaseToken string, result Event) (*Action, error) {
 		if pack.Id == "Slack" && actionId == "123" {
 			return nil, ActionNotFoundErr
 		}
@@ -288,6 +328,77 @@ func TestCompleteAction_ShouldReturn404ForNonExistingAction(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
+func TestCompleteAction_ShouldReturn410ForCancelledActionAndNotHandleIt(t *testing.T) {
+
+	//Given
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetCompleteAction()
+	completeAction = func(pack Pack, actionId, leaseToken string, result Event) (*Action, error) {
+		return &Action{Id: actionId, State: State{Value: stateCancelled}}, ActionCancelledErr
+	}
+
+	defer resetFlowService()
+	flowSvc = mockFlowService{
+		handleEvent: func(e Event) {
+			t.Fatal("Should not get here")
+		},
+	}
+
+	//When
+	w := httptest.NewRecorder()
+	CompleteAction(w, httptest.NewRequest(http.MethodPost,
+		"/v1/packs/Slack/actions/123/result?:packId=Slack&:actionId=123", eventBody()))
+
+	//Then
+	resp := w.Result()
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+}
+
+func TestCompleteAction_ShouldReturn409WhenLeaseIsLostAndNotHandleIt(t *testing.T) {
+
+	//Given
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetCompleteAction()
+	completeAction = func(pack Pack, actionId, leaseToken string, result Event) (*Action, error) {
+		require.Equal(t, "expired", leaseToken)
+		return nil, ActionLeaseLostErr
+	}
+
+	defer resetFlowService()
+	flowSvc = mockFlowService{
+		handleEvent: func(e Event) {
+			t.Fatal("Should not get here")
+		},
+	}
+
+	//When
+	w := httptest.NewRecorder()
+	CompleteAction(w, httptest.NewRequest(http.MethodPost,
+		"/v1/packs/Slack/actions/123/result?:packId=Slack&:actionId=123&leaseToken=expired", eventBody()))
+
+	//Then
+	resp := w.Result()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
 func TestCompleteAction_ShouldReturn500WhenThereIsErrorCompletingAction(t *testing.T) {
 	//Given
 	defer resetPackRepo()
@@ -301,7 +412,7 @@ func TestCompleteAction_ShouldReturn500WhenThereIsErrorCompletingAction(t *testi
 	}
 
 	defer resetCompleteAction()
-	completeAction = func(pack Pack, actionId string, result Event) (*Action, error) {
+	completeAction = func(pack Pack, actionId, leaseToken string, result Event) (*Action, error) {
 		if pack.Id == "Slack" && actionId == "123" {
 			return nil, errors.New("it's a disaster, run run run")
 		}
@@ -319,6 +430,164 @@ func TestCompleteAction_ShouldReturn500WhenThereIsErrorCompletingAction(t *testi
 	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 }
 
+func TestHeartbeat_ShouldReturn204WhenLeaseIsExtended(t *testing.T) {
+	//Given
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetHeartbeat()
+	heartbeat = func(pack Pack, actionId, leaseToken string) (*Action, error) {
+		if pack.Id == "Slack" && actionId == "123" && leaseToken == "token" {
+			return &Action{Id: actionId}, nil
+		}
+		t.Fatal("Should not get here")
+		return nil, nil
+	}
+
+	//When
+	w := httptest.NewRecorder()
+	Heartbeat(w, httptest.NewRequest(http.MethodPost,
+		"/v1/packs/Slack/actions/123/heartbeat?:packId=Slack&:actionId=123&leaseToken=token", nil))
+
+	//Then
+	resp := w.Result()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestHeartbeat_ShouldReturn404ForNonExistingAction(t *testing.T) {
+	//Given
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetHeartbeat()
+	heartbeat = func(pack Pack, actionId, leaseToken string) (*Action, error) {
+		return nil, ActionNotFoundErr
+	}
+
+	//When
+	w := httptest.NewRecorder()
+	Heartbeat(w, httptest.NewRequest(http.MethodPost,
+		"/v1/packs/Slack/actions/123/heartbeat?:packId=Slack&:actionId=123", nil))
+
+	//Then
+	resp := w.Result()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestHeartbeat_ShouldReturn409WhenActionIsNotPending(t *testing.T) {
+	//Given
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetHeartbeat()
+	heartbeat = func(pack Pack, actionId, leaseToken string) (*Action, error) {
+		return nil, ActionNotPendingErr
+	}
+
+	//When
+	w := httptest.NewRecorder()
+	Heartbeat(w, httptest.NewRequest(http.MethodPost,
+		"/v1/packs/Slack/actions/123/heartbeat?:packId=Slack&:actionId=123", nil))
+
+	//Then
+	resp := w.Result()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestHeartbeat_ShouldReturn409WhenLeaseIsLost(t *testing.T) {
+	//Given
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetHeartbeat()
+	heartbeat = func(pack Pack, actionId, leaseToken string) (*Action, error) {
+		return nil, ActionLeaseLostErr
+	}
+
+	//When
+	w := httptest.NewRecorder()
+	Heartbeat(w, httptest.NewRequest(http.MethodPost,
+		"/v1/packs/Slack/actions/123/heartbeat?:packId=Slack&:actionId=123&leaseToken=expired", nil))
+
+	//Then
+	resp := w.Result()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestHeartbeat_ShouldReturn400WhenActionHasNoLease(t *testing.T) {
+	//Given
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetHeartbeat()
+	heartbeat = func(pack Pack, actionId, leaseToken string) (*Action, error) {
+		return nil, ActionNotLeasedErr
+	}
+
+	//When
+	w := httptest.NewRecorder()
+	Heartbeat(w, httptest.NewRequest(http.MethodPost,
+		"/v1/packs/Slack/actions/123/heartbeat?:packId=Slack&:actionId=123", nil))
+
+	//Then
+	resp := w.Result()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHeartbeat_ShouldReturn404WhenPackDoesNotExist(t *testing.T) {
+	//Given
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return nil, PackNotFoundErr
+		},
+	}
+
+	//When
+	w := httptest.NewRecorder()
+	Heartbeat(w, httptest.NewRequest(http.MethodPost,
+		"/v1/packs/Slack/actions/123/heartbeat?:packId=Slack&:actionId=123", nil))
+
+	//Then
+	resp := w.Result()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
 func TestTakeAction_ShouldReturnActionWhenPackHasAnyNewActionsAndNameIsNotSpecified(t *testing.T) {
 
 	//Given
@@ -363,6 +632,43 @@ func TestTakeAction_ShouldReturnActionWhenPackHasAnyNewActionsAndNameIsNotSpecif
 	assert.Equal(t, "Slack", recPackId)
 }
 
+func TestTakeAction_ShouldReturnLeaseTokenInActionAndItsLinks_WhenActionIsLeased(t *testing.T) {
+
+	//Given
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetTakeAction()
+	leaseExpiresAt := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	takeAction = func(p Pack, actionName string) (*Action, error) {
+		return &Action{Id: "596759ef", Name: "SendMessage", LeaseExpiresAt: leaseExpiresAt, LeaseToken: "5a1b2c"}, nil
+	}
+
+	//When
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/v1/packs/Slack/actions/take?:packId=Slack", nil)
+	httputil.SetProtocolAndHostIn(request)
+	TakeAction(w, request)
+
+	//Then
+	resp := w.Result()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	expectedBody := `{"command":"SendMessage","input":null,"leaseExpiresAt":"2018-01-01T12:00:00Z","leaseToken":"5a1b2c","links":[` +
+		`{"href":"http://example.com/v1/packs/Slack/actions/596759ef/result?leaseToken=5a1b2c","rel":"http://example.com/swagger#/actionResult"},` +
+		`{"href":"http://example.com/v1/packs/Slack/actions/596759ef/heartbeat?leaseToken=5a1b2c","rel":"http://example.com/swagger#!/action/actionHeartbeat"}]}`
+	assert.Equal(t, expectedBody, string(body))
+}
+
 func TestTakeAction_ShouldReturnActionWhenPackHasNewActionsWithTheGivenName(t *testing.T) {
 
 	//Given
@@ -402,6 +708,192 @@ func TestTakeAction_ShouldReturnActionWhenPackHasNewActionsWithTheGivenName(t *t
 	assert.Equal(t, expectedBody, string(body))
 }
 
+func TestTakeAction_ShouldWaitForActionWhenWaitIsSpecified(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id, Name: "Slack"}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetWaitAndTakeActions()
+	var gotWait time.Duration
+	waitAndTakeActions = func(ctx context.Context, p Pack, actionName string, max int, wait time.Duration) ([]Action, error) {
+		gotWait = wait
+		return []Action{{Id: "596759ef", PackName: p.Name, Name: actionName}}, nil
+	}
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/v1/packs/Slack/actions/take?:packId=Slack&actionName=SendMessage&wait=30s", nil)
+	httputil.SetProtocolAndHostIn(request)
+	TakeAction(w, request)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 30*time.Second, gotWait)
+}
+
+func TestTakeAction_ShouldCapWait(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetWaitAndTakeActions()
+	var gotWait time.Duration
+	waitAndTakeActions = func(ctx context.Context, p Pack, actionName string, max int, wait time.Duration) ([]Action, error) {
+		gotWait = wait
+		return nil, nil
+	}
+
+	w := httptest.NewRecorder()
+	TakeAction(w, httptest.NewRequest(http.MethodPost, "/v1/packs/Slack/actions/take?:packId=Slack&wait=1h", nil))
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, maxTakeActionWait, gotWait)
+}
+
+func TestTakeAction_ShouldReturn400WhenWaitIsInvalid(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	TakeAction(w, httptest.NewRequest(http.MethodPost, "/v1/packs/Slack/actions/take?:packId=Slack&wait=soon", nil))
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestTakeAction_ShouldReturnListOfActionsWhenMaxIsSpecified(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id, Name: "Slack"}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetTakeActions()
+	takeActions = func(p Pack, actionName string, max int) ([]Action, error) {
+		if p.Id == "Slack" && actionName == "SendMessage" && max == 2 {
+			return []Action{
+				{Id: "596759ef", PackName: p.Name, Name: actionName},
+				{Id: "596759f0", PackName: p.Name, Name: actionName},
+			}, nil
+		}
+		t.Fatal("Should not get here")
+		return nil, nil
+	}
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/v1/packs/Slack/actions/take?:packId=Slack&actionName=SendMessage&max=2", nil)
+	httputil.SetProtocolAndHostIn(request)
+	TakeAction(w, request)
+
+	resp := w.Result()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	expectedBody := `{"actions":[` +
+		`{"command":"SendMessage","input":null,"links":[{"href":"http://example.com/v1/packs/Slack/actions/596759ef/result","rel":"http://example.com/swagger#/actionResult"}]},` +
+		`{"command":"SendMessage","input":null,"links":[{"href":"http://example.com/v1/packs/Slack/actions/596759f0/result","rel":"http://example.com/swagger#/actionResult"}]}` +
+		`]}`
+	assert.Equal(t, expectedBody, string(body))
+}
+
+func TestTakeAction_ShouldReturn204WhenMaxIsSpecifiedAndPackDoesNotHaveNewActions(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetTakeActions()
+	takeActions = func(p Pack, actionName string, max int) ([]Action, error) {
+		return nil, nil
+	}
+
+	w := httptest.NewRecorder()
+	TakeAction(w, httptest.NewRequest(http.MethodPost, "/v1/packs/Slack/actions/take?:packId=Slack&max=10", nil))
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestTakeAction_ShouldCapMax(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	defer resetTakeActions()
+	var gotMax int
+	takeActions = func(p Pack, actionName string, max int) ([]Action, error) {
+		gotMax = max
+		return nil, nil
+	}
+
+	w := httptest.NewRecorder()
+	TakeAction(w, httptest.NewRequest(http.MethodPost, "/v1/packs/Slack/actions/take?:packId=Slack&max=100000", nil))
+
+	assert.Equal(t, maxTakeActions, gotMax)
+}
+
+func TestTakeAction_ShouldReturn400WhenMaxIsInvalid(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	for _, max := range []string{"0", "-1", "many"} {
+		w := httptest.NewRecorder()
+		TakeAction(w, httptest.NewRequest(http.MethodPost, "/v1/packs/Slack/actions/take?:packId=Slack&max="+max, nil))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, max)
+	}
+}
+
 func TestTakeAction_ShouldReturn404WhenPackDoesNotExist(t *testing.T) {
 
 	defer resetPackRepo()
@@ -527,10 +1019,13 @@ func (r mockPackRepo) UpdateLastSeen(id string) error {
 	return r.updateLastSeen(id)
 }
 
-func resetFlowService()    { flowSvc = flowService{} }
-func resetPackRepo()       { packRepo = packMgoRepo{} }
-func resetCompleteAction() { completeAction = completeActionFn }
-func resetTakeAction()     { takeAction = takeActionFn }
+func resetFlowService()        { flowSvc = flowService{} }
+func resetPackRepo()           { packRepo = packMgoRepo{} }
+func resetCompleteAction()     { completeAction = completeActionFn }
+func resetTakeAction()         { takeAction = takeActionFn }
+func resetTakeActions()        { takeActions = takeActionsFn }
+func resetHeartbeat()          { heartbeat = heartbeatFn }
+func resetWaitAndTakeActions() { waitAndTakeActions = waitAndTakeActionsFn }
 
 func eventBody() io.Reader {
 	return strings.NewReader(`{"event": "MessageReceived", "payload": {"channelId": "123456"}}`)
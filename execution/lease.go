@@ -0,0 +1,63 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/jasonlvhit/gocron"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// how long a pack holds a taken action before it is returned to the NEW state, unless the pack sends a heartbeat.
+// Zero means leases are disabled and a taken action stays PENDING until it is completed.
+var leaseDuration time.Duration
+
+// Enables leases on taken actions and checks every interval for expired leases.
+func EnableActionLeases(lease time.Duration, checkIntervalInSeconds int) (*gocron.Scheduler, chan bool) {
+	leaseDuration = lease
+	s := gocron.NewScheduler()
+	s.Every(uint64(checkIntervalInSeconds)).Seconds().Do(requeueActions)
+	sc := s.Start()
+	return s, sc
+}
+
+func requeueActions() {
+
+	actions, err := actionRepo.FindLeaseExpired(currentTime())
+	if err != nil {
+		log.Err(err).Msg("Error finding actions with expired lease")
+		return
+	}
+
+	for _, a := range actions {
+		// the pack may have completed the action or sent a heartbeat in the meantime, the update is guarded by the state
+		// and the lease the action was found with, so it fails then
+		if err := a.requeue(); err != nil {
+			log.Err(err).Msgf("Error re-queueing actionId=%s", a.Id)
+			continue
+		}
+
+		log.Info().
+			Str("ActionId", a.Id).
+			Str("CorrelationId", a.CorrelationId).
+			Str("FlowName", a.FlowName).
+			Str("StepId", a.StepId).
+			Int("Redeliveries", a.Redeliveries).
+			Msg("Action lease expired, action re-queued")
+		newActions.notify()
+	}
+}
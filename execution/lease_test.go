@@ -0,0 +1,107 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRequeueActions_ShouldMoveActionsWithExpiredLeaseBackToNewState(t *testing.T) {
+
+	defer resetCurrentTime()
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+
+	defer resetActionRepo()
+	var findLeaseExpiredTime time.Time
+	var updated []Action
+	pendingState := State{Value: statePending}
+	actionRepo = mockActionRepo{
+		findLeaseExpired: func(t time.Time) ([]Action, error) {
+			findLeaseExpiredTime = t
+			return []Action{
+				{Id: "a1", State: pendingState, States: []State{pendingState}, LeaseExpiresAt: now, LeaseToken: "token", Timeout: Timeout{Take: "1m"}},
+				{Id: "a2", State: pendingState, States: []State{pendingState}, LeaseExpiresAt: now, Redeliveries: 2},
+			}, nil
+		},
+		update: func(a Action) error {
+			updated = append(updated, a)
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(a Action) error {
+			return nil
+		},
+	}
+
+	requeueActions()
+
+	assert.Equal(t, now, findLeaseExpiredTime)
+	assert.Len(t, updated, 2)
+
+	assert.Equal(t, stateNew, updated[0].State.Value)
+	assert.Equal(t, pendingState, updated[0].prevState)
+	assert.Equal(t, []State{pendingState, updated[0].State}, updated[0].States)
+	assert.Equal(t, 1, updated[0].Redeliveries)
+	assert.True(t, updated[0].LeaseExpiresAt.IsZero())
+	assert.Empty(t, updated[0].LeaseToken)
+	// a heartbeat received since the action was found extends the lease, the update does not match then
+	assert.Equal(t, &lease{token: "token", expiresAt: now}, updated[0].prevLease)
+	assert.Equal(t, updated[0].State.Time.Add(time.Minute), updated[0].ExpiresAt)
+
+	assert.Equal(t, 3, updated[1].Redeliveries)
+	assert.True(t, updated[1].ExpiresAt.IsZero())
+}
+
+func TestRequeueActions_ShouldContinueWithOtherActions_WhenUpdateFails(t *testing.T) {
+
+	defer resetActionRepo()
+	var updated []string
+	pendingState := State{Value: statePending}
+	actionRepo = mockActionRepo{
+		findLeaseExpired: func(t time.Time) ([]Action, error) {
+			return []Action{
+				{Id: "completedInTheMeantime", State: pendingState},
+				{Id: "a2", State: pendingState},
+			}, nil
+		},
+		update: func(a Action) error {
+			if a.Id == "completedInTheMeantime" {
+				return errors.New("not found")
+			}
+			updated = append(updated, a.Id)
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(a Action) error {
+			return nil
+		},
+	}
+
+	requeueActions()
+
+	assert.Equal(t, []string{"a2"}, updated)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/ExpediaGroup/flyte/mongo"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+type limitsMgoRepo struct{}
+
+func (limitsMgoRepo) Lock(flowName, holder string, now time.Time, lease time.Duration) (bool, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	// when another holder has an unexpired lease the query does not match
+	// and the upsert fails on the duplicate id
+	_, err := s.DB(mongo.DbName).C(mongo.LimitsCollectionId).Upsert(
+		bson.M{"_id": flowName, "expiresAt": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"holder": holder, "expiresAt": now.Add(lease)}},
+	)
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (limitsMgoRepo) Unlock(flowName, holder string) error {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	err := s.DB(mongo.DbName).C(mongo.LimitsCollectionId).Remove(bson.M{"_id": flowName, "holder": holder})
+	if err == mgo.ErrNotFound {
+		// the lease has expired and another holder has taken the lock over
+		return nil
+	}
+	return err
+}
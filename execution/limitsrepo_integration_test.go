@@ -0,0 +1,60 @@
+// +build integration
+
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var limitsRepoT = limitsMgoRepo{}
+
+func TestLockLimits_ShouldOnlyBeGrantedToOneHolderUntilUnlockedOrLeaseExpires(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	locked, err := limitsRepoT.Lock("flowA", "holderA", now, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	locked, err = limitsRepoT.Lock("flowA", "holderB", now, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	locked, err = limitsRepoT.Lock("flowB", "holderB", now, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	require.NoError(t, limitsRepoT.Unlock("flowA", "holderA"))
+	locked, err = limitsRepoT.Lock("flowA", "holderB", now, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	locked, err = limitsRepoT.Lock("flowA", "holderC", now.Add(2*time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, locked)
+	// the lock has been taken over, the previous holder cannot unlock it
+	require.NoError(t, limitsRepoT.Unlock("flowA", "holderB"))
+	locked, err = limitsRepoT.Lock("flowA", "holderD", now.Add(2*time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, locked)
+}
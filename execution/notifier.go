@@ -0,0 +1,52 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"sync"
+	"time"
+)
+
+// how often waiting packs look for new actions, this picks up actions created by other flyte instances
+// which are not notified in-process
+var newActionsPollInterval = 5 * time.Second
+
+// wakes up packs waiting for new actions when an action is created or returned to the NEW state on this instance
+var newActions = newActionNotifier()
+
+type actionNotifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func newActionNotifier() *actionNotifier {
+	return &actionNotifier{ch: make(chan struct{})}
+}
+
+// returns a channel that is closed on the next notification
+func (n *actionNotifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ch
+}
+
+func (n *actionNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
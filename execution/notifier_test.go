@@ -0,0 +1,116 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestActionNotifier_ShouldWakeUpAllWaiters(t *testing.T) {
+
+	n := newActionNotifier()
+	w1 := n.wait()
+	w2 := n.wait()
+
+	n.notify()
+
+	assert.True(t, isClosed(w1))
+	assert.True(t, isClosed(w2))
+	assert.False(t, isClosed(n.wait()))
+}
+
+func TestWaitAndTakeActions_ShouldTakeActionCreatedWhileWaiting(t *testing.T) {
+
+	defer resetTakeActions()
+	calls := 0
+	takeActions = func(p Pack, actionName string, max int) ([]Action, error) {
+		calls++
+		if calls == 1 {
+			go newActions.notify()
+			return nil, nil
+		}
+		return []Action{{Id: "123"}}, nil
+	}
+
+	got, err := Pack{Id: "packA"}.WaitAndTakeActions(context.Background(), "", 1, time.Minute)
+	require.NoError(t, err)
+
+	assert.Equal(t, "123", got[0].Id)
+	assert.Equal(t, 2, calls)
+}
+
+func TestWaitAndTakeActions_ShouldPollForActionsCreatedByOtherInstances(t *testing.T) {
+
+	defer func(d time.Duration) { newActionsPollInterval = d }(newActionsPollInterval)
+	newActionsPollInterval = time.Millisecond
+
+	defer resetTakeActions()
+	calls := 0
+	takeActions = func(p Pack, actionName string, max int) ([]Action, error) {
+		calls++
+		if calls < 3 {
+			return nil, nil
+		}
+		return []Action{{Id: "123"}}, nil
+	}
+
+	got, err := Pack{Id: "packA"}.WaitAndTakeActions(context.Background(), "", 1, time.Minute)
+	require.NoError(t, err)
+
+	assert.Equal(t, "123", got[0].Id)
+}
+
+func TestWaitAndTakeActions_ShouldReturnNil_WhenNoActionIsAvailableBeforeWaitExpires(t *testing.T) {
+
+	defer resetTakeActions()
+	takeActions = func(p Pack, actionName string, max int) ([]Action, error) {
+		return nil, nil
+	}
+
+	got, err := Pack{Id: "packA"}.WaitAndTakeActions(context.Background(), "", 1, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	assert.Empty(t, got)
+}
+
+func TestWaitAndTakeActions_ShouldReturnNil_WhenContextIsDone(t *testing.T) {
+
+	defer resetTakeActions()
+	takeActions = func(p Pack, actionName string, max int) ([]Action, error) {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err := Pack{Id: "packA"}.WaitAndTakeActions(ctx, "", 1, time.Minute)
+	require.NoError(t, err)
+
+	assert.Empty(t, got)
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"fmt"
+	"github.com/ExpediaGroup/flyte/collections"
+	"github.com/ExpediaGroup/flyte/template"
+	"strconv"
+)
+
+const (
+	onErrorHandler = "onError"
+	finallyHandler = "finally"
+)
+
+// executeHandlerSteps executes the onError steps when the action being handled has ended with a FATAL event and the
+// finally steps once no action of the flow execution is running any more. Each of them runs once per flow execution
+// and a failed onError or finally action does not trigger the onError steps again. A cancelled flow execution
+// only executes its finally steps.
+func (f *Flow) executeHandlerSteps(e Event) {
+
+	failed := f.failed
+	f.failed = nil
+	if failed != nil && !f.isCancelled() {
+		for _, step := range f.OnError {
+			if !f.hasActionForStep(step.Id) {
+				action, err := step.executeHandler(onErrorHandler, e, f.context, failed)
+				f.addStepAction(step, action, err)
+			}
+		}
+	}
+
+	if f.isRunning() {
+		return
+	}
+	for _, step := range f.Finally {
+		if !f.hasActionForStep(step.Id) {
+			action, err := step.executeHandler(finallyHandler, e, f.context, nil)
+			f.addStepAction(step, action, err)
+		}
+	}
+}
+
+// a flow execution without any action has not started, cancelled actions are not running any more
+func (f Flow) isRunning() bool {
+	if len(f.actions) == 0 {
+		return true
+	}
+	for _, a := range f.actions {
+		if !a.hasFinished() && a.Cancellation == nil {
+			return true
+		}
+	}
+	for _, iterations := range f.iterations {
+		for _, a := range iterations {
+			if !a.hasFinished() && a.Cancellation == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// onError and finally steps are not matched against the event, they are executed with the event being handled and,
+// for onError steps, the failed action as "Failure" in the template context.
+func (s Step) executeHandler(handler string, e Event, parentCtx map[string]string, failed *Action) (*Action, error) {
+
+	resolvedCtx, err := template.Resolve(s.Context, handlerTemplateContext(e, parentCtx, failed))
+	if err != nil {
+		return nil, fmt.Errorf("error resolving context with event=%+v and ctx=%v: %v", e, parentCtx, err)
+	}
+	ctx := collections.Merge(parentCtx, resolvedCtx.(map[string]string))
+	tctx := handlerTemplateContext(e, ctx, failed)
+
+	if s.Criteria != "" {
+		criteria, err := template.Resolve(s.Criteria, tctx)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving criteria with event=%+v and ctx=%v: %v", e, ctx, err)
+		}
+		if criteriaMet, err := strconv.ParseBool(criteria.(string)); err != nil || !criteriaMet {
+			return nil, err
+		}
+	}
+
+	a, err := s.Command.createActionIn(tctx, e, ctx)
+	if a != nil {
+		a.StepId = s.Id
+		a.Handler = handler
+	}
+	return a, err
+}
+
+func handlerTemplateContext(e Event, ctx map[string]string, failed *Action) template.Context {
+	tctx := templateContext(e, ctx)
+	if failed != nil {
+		tctx["Failure"] = map[string]interface{}{
+			"StepId":   failed.StepId,
+			"ActionId": failed.Id,
+			"Trigger":  failed.Trigger,
+			"Result":   failed.Result,
+		}
+	}
+	return tctx
+}
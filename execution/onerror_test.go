@@ -0,0 +1,168 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHandleAction_ShouldExecuteOnErrorStepsWithFailedAction(t *testing.T) {
+
+	//Given
+	trigger := Event{Name: "Approved", Pack: Pack{Name: "Slack"}}
+	fatal := Event{Name: "FATAL", Pack: Pack{Name: "Shell"}, Payload: map[string]interface{}{"error": "disk full"}}
+	failed := Action{Id: "failed", StepId: "deploy", State: State{Value: stateFatal}, Trigger: trigger, Result: fatal}
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		getByAction: func(a Action) (*Flow, error) {
+			flow := newHandlerFlowT()
+			flow.actions["deploy"] = failed
+			return &flow, nil
+		},
+	}
+	added := setupHandlerActionRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	defer resetStepExecutor()
+	setupStepExecutor(nil, nil)
+
+	//When
+	flowService{}.HandleAction(failed)
+
+	//Then
+	require.Len(t, *added, 1)
+	a := (*added)[0]
+	assert.Equal(t, "notify", a.StepId)
+	assert.Equal(t, onErrorHandler, a.Handler)
+	assert.Equal(t, fatal, a.Trigger)
+	assert.Equal(t, "deploy failed on Approved with disk full", a.Input)
+}
+
+func TestHandleAction_ShouldExecuteFinallyStepsOnceNoActionIsRunning(t *testing.T) {
+
+	//Given
+	done := Action{Id: "done", StepId: "deploy", State: State{Value: stateSuccess}, Result: Event{Name: "Deployed", Pack: Pack{Name: "Shell"}}}
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		getByAction: func(a Action) (*Flow, error) {
+			flow := newHandlerFlowT()
+			flow.actions["deploy"] = done
+			return &flow, nil
+		},
+	}
+	added := setupHandlerActionRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	defer resetStepExecutor()
+	setupStepExecutor(nil, nil)
+
+	//When
+	flowService{}.HandleAction(done)
+
+	//Then
+	require.Len(t, *added, 1)
+	assert.Equal(t, "clean_up", (*added)[0].StepId)
+	assert.Equal(t, finallyHandler, (*added)[0].Handler)
+	assert.Equal(t, "Deployed", (*added)[0].Input)
+}
+
+func TestExecuteHandlerSteps_ShouldNotExecuteFinallyStepsWhileActionsAreRunning(t *testing.T) {
+
+	cases := map[string]Flow{
+		"not started":       newHandlerFlowT(),
+		"running action":    newHandlerFlowWithActionT(Action{StepId: "deploy", State: State{Value: statePending}}),
+		"running iteration": newHandlerFlowWithActionT(Action{StepId: "deploy", State: State{Value: stateSuccess}}),
+		"already executed":  newHandlerFlowWithActionT(Action{StepId: "clean_up", State: State{Value: stateSuccess}}),
+	}
+	cases["running iteration"].iterations["deploy"] = []Action{{StepId: "deploy", State: State{Value: stateNew}}}
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{add: func(a Action) error {
+		t.Fatalf("Should not get here, action=%+v", a)
+		return nil
+	}}
+
+	for _, flow := range cases {
+		flow.executeHandlerSteps(Event{})
+	}
+}
+
+func TestExecuteHandlerSteps_ShouldNotExecuteOnErrorStepsTwice(t *testing.T) {
+
+	added := setupHandlerActionRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+
+	flow := newHandlerFlowWithActionT(Action{StepId: "notify", State: State{Value: statePending}, Handler: onErrorHandler})
+	flow.failed = &Action{StepId: "deploy", State: State{Value: stateFatal}}
+
+	flow.executeHandlerSteps(Event{Name: "FATAL"})
+
+	assert.Len(t, *added, 0)
+	assert.Nil(t, flow.failed)
+}
+
+func TestExecuteHandlerSteps_ShouldOnlyExecuteFinallyStepsOfCancelledFlow(t *testing.T) {
+
+	added := setupHandlerActionRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+
+	flow := newHandlerFlowWithActionT(Action{StepId: "deploy", State: State{Value: stateCancelled}, Cancellation: &Cancellation{}})
+	flow.failed = &Action{StepId: "deploy", State: State{Value: stateFatal}}
+
+	flow.executeHandlerSteps(Event{Name: "FlowCancelled"})
+
+	require.Len(t, *added, 1)
+	assert.Equal(t, "clean_up", (*added)[0].StepId)
+	assert.Equal(t, finallyHandler, (*added)[0].Handler)
+	assert.Nil(t, flow.failed)
+}
+
+func newHandlerFlowT() Flow {
+	flow := newFlowT(newStepT("deploy", "Approved", "Slack"))
+	flow.OnError = []Step{{
+		Id:      "notify",
+		Command: Command{Name: "SendMessage", PackName: "Slack", Input: "{{ Failure.StepId }} failed on {{ Failure.Trigger.Name }} with {{ Failure.Result.Payload.error }}"},
+	}}
+	flow.Finally = []Step{{
+		Id:      "clean_up",
+		Command: Command{Name: "Run", PackName: "Shell", Input: "{{ Event.Name }}"},
+	}}
+	flow.iterations = map[string][]Action{}
+	return flow
+}
+
+func newHandlerFlowWithActionT(a Action) Flow {
+	flow := newHandlerFlowT()
+	flow.actions[a.StepId] = a
+	return flow
+}
+
+func setupHandlerActionRepoT() *[]Action {
+	var added []Action
+	actionRepo = mockActionRepo{add: func(a Action) error {
+		added = append(added, a)
+		return nil
+	}}
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+	return &added
+}
@@ -17,9 +17,12 @@ limitations under the License.
 package execution
 
 import (
+	"context"
 	"errors"
 	"github.com/ExpediaGroup/flyte/collections"
 	"github.com/rs/zerolog/log"
+	"gopkg.in/mgo.v2"
+	"time"
 )
 
 type Pack struct {
@@ -28,26 +31,65 @@ type Pack struct {
 	Labels map[string]string `bson:"labels,omitempty"`
 }
 
-func (p Pack) CompleteAction(actionId string, result Event) (*Action, error) {
-	return completeAction(p, actionId, result)
+// the pack of the built-in commands, its actions are run by flyte and cannot be handled by external packs
+func (p Pack) isBuiltin() bool {
+	return p.Name == builtinPackName
+}
+
+// Completes an action taken by the pack. The lease token is the one issued when the action was taken, it is empty when
+// leases are disabled.
+func (p Pack) CompleteAction(actionId, leaseToken string, result Event) (*Action, error) {
+	return completeAction(p, actionId, leaseToken, result)
 }
 
 var completeAction = completeActionFn
 
-func completeActionFn(pack Pack, actionId string, result Event) (*Action, error) {
+func completeActionFn(pack Pack, actionId, leaseToken string, result Event) (*Action, error) {
 
 	action, err := actionRepo.Get(actionId)
 	if err != nil || action == nil {
 		return action, err
 	}
 
+	if action.isBuiltin() {
+		// built-in actions are completed by flyte itself, or through the approvals API
+		log.Error().Msgf("pack=%+v trying to complete built-in actionId=%s", pack, action.Id)
+		return nil, ActionNotFoundErr
+	}
 	if action.PackName != pack.Name || !collections.ContainsAll(pack.Labels, action.PackLabels) {
 		log.Error().Msgf("pack=%+v trying to complete actionId=%s which which it cannot handle", pack, action.Id)
 		return nil, nil
 	}
+	if err := action.checkLease(leaseToken); err != nil {
+		return nil, err
+	}
+	if !action.LeaseExpiresAt.IsZero() {
+		// the lease may expire, and the action be taken by another pack, before the result is saved
+		action.guardLease()
+	}
 	return action, action.finish(result)
 }
 
+func (p Pack) Heartbeat(actionId, leaseToken string) (*Action, error) {
+	return heartbeat(p, actionId, leaseToken)
+}
+
+var heartbeat = heartbeatFn
+
+func heartbeatFn(pack Pack, actionId, leaseToken string) (*Action, error) {
+
+	action, err := actionRepo.Get(actionId)
+	if err != nil || action == nil {
+		return action, err
+	}
+
+	if action.PackName != pack.Name || !collections.ContainsAll(pack.Labels, action.PackLabels) || action.isBuiltin() {
+		log.Error().Msgf("pack=%+v trying to extend lease of actionId=%s which it cannot handle", pack, action.Id)
+		return nil, ActionNotFoundErr
+	}
+	return action, action.heartbeat(leaseToken)
+}
+
 func (p Pack) TakeAction(actionName string) (*Action, error) {
 	return takeAction(p, actionName)
 }
@@ -56,6 +98,19 @@ var takeAction = takeActionFn
 
 func takeActionFn(pack Pack, actionName string) (*Action, error) {
 
+	// built-in actions are run by flyte itself
+	if pack.isBuiltin() {
+		return nil, nil
+	}
+
+	if flowFairness != nil {
+		actions, err := takeActionsFn(pack, actionName, 1)
+		if err != nil || len(actions) == 0 {
+			return nil, err
+		}
+		return &actions[0], nil
+	}
+
 	action, err := actionRepo.FindNew(pack, actionName)
 	if err != nil || action == nil {
 		return action, err
@@ -64,6 +119,98 @@ func takeActionFn(pack Pack, actionName string) (*Action, error) {
 	return action, action.take()
 }
 
+// Takes up to max new actions. Actions taken by another pack in the meantime are skipped.
+func (p Pack) TakeActions(actionName string, max int) ([]Action, error) {
+	return takeActions(p, actionName, max)
+}
+
+var takeActions = takeActionsFn
+
+func takeActionsFn(pack Pack, actionName string, max int) ([]Action, error) {
+
+	// built-in actions are run by flyte itself
+	if pack.isBuiltin() {
+		return nil, nil
+	}
+
+	candidates, err := actionRepo.FindAllNew(pack, actionName, takeCandidatesLimit(max))
+	if err != nil {
+		return nil, err
+	}
+	if flowFairness != nil {
+		candidates = flowFairness.order(candidates)
+	}
+
+	var taken []Action
+	for _, action := range candidates {
+		if len(taken) == max {
+			break
+		}
+		if err := action.take(); err != nil {
+			if err == mgo.ErrNotFound {
+				// the state guard did not match, another pack took the action first
+				continue
+			}
+			if len(taken) == 0 {
+				return nil, err
+			}
+			// the actions taken so far are already pending, so they have to be handed over to the pack
+			log.Err(err).Msgf("Error taking actionId=%s, returning %d taken actions", action.Id, len(taken))
+			break
+		}
+		if flowFairness != nil {
+			flowFairness.taken(action)
+		}
+		taken = append(taken, action)
+	}
+	return taken, nil
+}
+
+// how many candidates to fetch when taking max actions: some spare ones in case other packs take the first ones,
+// or a wider window when fairness has to choose between flows
+func takeCandidatesLimit(max int) int {
+	limit := 2 * max
+	if flowFairness != nil && limit < fairnessCandidates {
+		return fairnessCandidates
+	}
+	return limit
+}
+
+// Takes up to max new actions, waiting up to the given duration for at least one to become available.
+// Returns no actions when there are still none after the wait or the context is done.
+func (p Pack) WaitAndTakeActions(ctx context.Context, actionName string, max int, wait time.Duration) ([]Action, error) {
+	return waitAndTakeActions(ctx, p, actionName, max, wait)
+}
+
+var waitAndTakeActions = waitAndTakeActionsFn
+
+func waitAndTakeActionsFn(ctx context.Context, pack Pack, actionName string, max int, wait time.Duration) ([]Action, error) {
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	poll := time.NewTicker(newActionsPollInterval)
+	defer poll.Stop()
+
+	for {
+		// subscribe before looking for actions, so an action created in between is not missed
+		notified := newActions.wait()
+
+		actions, err := takeActions(pack, actionName, max)
+		if err != nil || len(actions) > 0 {
+			return actions, err
+		}
+
+		select {
+		case <-notified:
+		case <-poll.C:
+		case <-timeout.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
 func (p Pack) UpdateLastSeen() {
 	updateLastSeen(p)
 }
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2"
 	"testing"
 	"time"
 )
@@ -57,7 +58,7 @@ func TestCompleteAction_ShouldFinishAction_WhenItExistsAndIsInPendingState(t *te
 	}
 
 	//When
-	got, err := Pack{Id: "packA"}.CompleteAction("existingPendingAction", Event{Name: "resultEvent"})
+	got, err := Pack{Id: "packA"}.CompleteAction("existingPendingAction", "", Event{Name: "resultEvent"})
 	require.NoError(t, err)
 	require.NotNil(t, got)
 	require.True(t, calledGet)
@@ -95,7 +96,7 @@ func TestCompleteAction_ShouldSetActionStateToFatalForFatalResult(t *testing.T)
 	}
 
 	//When
-	got, err := Pack{Id: "packA"}.CompleteAction("existingPendingAction", Event{Name: fatalEventName})
+	got, err := Pack{Id: "packA"}.CompleteAction("existingPendingAction", "", Event{Name: fatalEventName})
 	require.NoError(t, err)
 	require.NotNil(t, got)
 
@@ -105,6 +106,102 @@ func TestCompleteAction_ShouldSetActionStateToFatalForFatalResult(t *testing.T)
 	assert.Equal(t, expectedAction, *got)
 }
 
+func TestCompleteAction_ShouldPutActionBackToNewState_WhenFatalResultShouldBeRetried(t *testing.T) {
+
+	defer resetActionRepo()
+	state1 := State{Value: stateNew}
+	state2 := State{Value: statePending}
+	var updated Action
+	actionRepo = mockActionRepo{
+		get: func(actionId string) (*Action, error) {
+			return &Action{
+				State:   state2,
+				States:  []State{state1, state2},
+				Retry:   Retry{MaxAttempts: 3, Delay: "1m"},
+				Timeout: Timeout{Take: "5m"},
+				Attempt: 1,
+			}, nil
+		},
+		update: func(action Action) error {
+			updated = action
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	got, err := Pack{Id: "packA"}.CompleteAction("retriedAction", "", Event{Name: fatalEventName})
+	require.NoError(t, err)
+
+	assert.Equal(t, *got, updated)
+	assert.Equal(t, stateNew, got.State.Value)
+	assert.Equal(t, state2, got.prevState)
+	require.Len(t, got.States, 4)
+	assert.Equal(t, []string{stateNew, statePending, stateFatal, stateNew},
+		[]string{got.States[0].Value, got.States[1].Value, got.States[2].Value, got.States[3].Value})
+	assert.Equal(t, 2, got.Attempt)
+	assert.Equal(t, Event{Name: fatalEventName}, got.Result)
+	assert.Equal(t, got.State.Time.Add(time.Minute), got.NotBefore)
+	assert.Equal(t, got.NotBefore.Add(5*time.Minute), got.ExpiresAt)
+	assert.True(t, got.isRetrying())
+}
+
+func TestCompleteAction_ShouldSetActionStateToFatal_WhenRetriesAreExhausted(t *testing.T) {
+
+	defer resetActionRepo()
+	state1 := State{Value: statePending}
+	actionRepo = mockActionRepo{
+		get: func(actionId string) (*Action, error) {
+			return &Action{State: state1, States: []State{state1}, Retry: Retry{MaxAttempts: 3}, Attempt: 3}, nil
+		},
+		update: func(action Action) error {
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	got, err := Pack{Id: "packA"}.CompleteAction("retriedAction", "", Event{Name: fatalEventName})
+	require.NoError(t, err)
+
+	assert.Equal(t, stateFatal, got.State.Value)
+	assert.Equal(t, 3, got.Attempt)
+	assert.False(t, got.isRetrying())
+}
+
+func TestCompleteAction_ShouldNotRetrySuccessfulResult(t *testing.T) {
+
+	defer resetActionRepo()
+	state1 := State{Value: statePending}
+	actionRepo = mockActionRepo{
+		get: func(actionId string) (*Action, error) {
+			return &Action{State: state1, States: []State{state1}, Retry: Retry{MaxAttempts: 3}, Attempt: 1}, nil
+		},
+		update: func(action Action) error {
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	got, err := Pack{Id: "packA"}.CompleteAction("retriedAction", "", Event{Name: "Deployed"})
+	require.NoError(t, err)
+
+	assert.Equal(t, stateSuccess, got.State.Value)
+}
+
 func TestCompleteAction_ShouldReturnErrorWhenActionIsInNewState(t *testing.T) {
 
 	defer resetPackRepo()
@@ -117,7 +214,7 @@ func TestCompleteAction_ShouldReturnErrorWhenActionIsInNewState(t *testing.T) {
 		},
 	}
 
-	_, err := Pack{Id: "packA"}.CompleteAction("new", Event{Name: "resultEvent"})
+	_, err := Pack{Id: "packA"}.CompleteAction("new", "", Event{Name: "resultEvent"})
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "action is not in PENDING state")
@@ -134,7 +231,7 @@ func TestCompleteAction_ShouldReturnErrorWhenActionIsInSuccessState(t *testing.T
 		},
 	}
 
-	_, err := Pack{Id: "packA"}.CompleteAction("success", Event{Name: "resultEvent"})
+	_, err := Pack{Id: "packA"}.CompleteAction("success", "", Event{Name: "resultEvent"})
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "action is not in PENDING state")
@@ -152,7 +249,7 @@ func TestCompleteAction_ShouldReturnErrorWhenActionIsInFatalState(t *testing.T)
 		},
 	}
 
-	_, err := Pack{Id: "packA"}.CompleteAction("fatal", Event{Name: "resultEvent"})
+	_, err := Pack{Id: "packA"}.CompleteAction("fatal", "", Event{Name: "resultEvent"})
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "action is not in PENDING state")
@@ -170,7 +267,7 @@ func TestCompleteAction_ShouldReturnActionNotFoundErrWhenItDoesNotExist(t *testi
 		},
 	}
 
-	_, err := Pack{Id: "packA"}.CompleteAction("nonExisting", Event{Name: "resultEvent"})
+	_, err := Pack{Id: "packA"}.CompleteAction("nonExisting", "", Event{Name: "resultEvent"})
 
 	assert.EqualError(t, err, ActionNotFoundErr.Error())
 }
@@ -188,7 +285,7 @@ func TestCompleteAction_ShouldReturnErrorProducedWhileSearchingForAction(t *test
 		},
 	}
 
-	_, err := Pack{Id: "packA"}.CompleteAction("error", Event{Name: "resultEvent"})
+	_, err := Pack{Id: "packA"}.CompleteAction("error", "", Event{Name: "resultEvent"})
 
 	assert.EqualError(t, err, expectedError.Error())
 }
@@ -211,7 +308,7 @@ func TestCompleteAction_ShouldReturnErrorProducedWhileUpdatingAction(t *testing.
 	}
 
 	//When
-	_, err := Pack{Id: "packA"}.CompleteAction("error", Event{Name: "resultEvent"})
+	_, err := Pack{Id: "packA"}.CompleteAction("error", "", Event{Name: "resultEvent"})
 
 	//Then
 	assert.EqualError(t, err, expectedError.Error())
@@ -265,6 +362,259 @@ func TestTakeAction_ShouldReturnActionInPendingState_WhenPackHadNewActionWithThe
 	assert.Equal(t, pendingAction, *got)
 }
 
+func TestTakeAction_ShouldSetCompleteDeadline_WhenActionHasCompleteTimeout(t *testing.T) {
+
+	defer resetActionRepo()
+	state1 := State{Value: stateNew}
+	actionRepo = mockActionRepo{
+		findNew: func(pack Pack, name string) (*Action, error) {
+			return &Action{State: state1, States: []State{state1}, Timeout: Timeout{Take: "1m", Complete: "10m"}}, nil
+		},
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	got, err := Pack{Id: "packA"}.TakeAction("")
+	require.NoError(t, err)
+
+	assert.Equal(t, got.State.Time.Add(10*time.Minute), got.ExpiresAt)
+}
+
+func TestTakeAction_ShouldSetLease_WhenLeasesAreEnabled(t *testing.T) {
+
+	defer func(d time.Duration) { leaseDuration = d }(leaseDuration)
+	leaseDuration = time.Minute
+
+	defer resetActionRepo()
+	state1 := State{Value: stateNew}
+	actionRepo = mockActionRepo{
+		findNew: func(pack Pack, name string) (*Action, error) {
+			return &Action{State: state1, States: []State{state1}}, nil
+		},
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	got, err := Pack{Id: "packA"}.TakeAction("")
+	require.NoError(t, err)
+
+	assert.Equal(t, got.State.Time.Add(time.Minute), got.LeaseExpiresAt)
+	assert.NotEmpty(t, got.LeaseToken)
+}
+
+func TestHeartbeat_ShouldExtendLeaseOfPendingAction(t *testing.T) {
+
+	defer func(d time.Duration) { leaseDuration = d }(leaseDuration)
+	leaseDuration = time.Minute
+
+	defer resetCurrentTime()
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+
+	defer resetActionRepo()
+	pendingState := State{Value: statePending}
+	var updated Action
+	actionRepo = mockActionRepo{
+		get: func(id string) (*Action, error) {
+			return &Action{Id: id, PackName: "packA", State: pendingState, States: []State{pendingState}, LeaseExpiresAt: now, LeaseToken: "token"}, nil
+		},
+		update: func(action Action) error {
+			updated = action
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	got, err := Pack{Name: "packA"}.Heartbeat("123", "token")
+	require.NoError(t, err)
+
+	assert.Equal(t, now.Add(time.Minute), got.LeaseExpiresAt)
+	assert.Equal(t, pendingState, updated.prevState)
+	assert.Equal(t, pendingState, updated.State)
+	assert.Equal(t, &lease{token: "token", expiresAt: now}, updated.prevLease)
+}
+
+func TestHeartbeat_ShouldReturnActionLeaseLostErr_WhenPackDoesNotHoldTheLease(t *testing.T) {
+
+	defer func(d time.Duration) { leaseDuration = d }(leaseDuration)
+	leaseDuration = time.Minute
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		get: func(id string) (*Action, error) {
+			return &Action{Id: id, PackName: "packA", State: State{Value: statePending}, LeaseExpiresAt: time.Now(), LeaseToken: "token"}, nil
+		},
+	}
+
+	_, err := Pack{Name: "packA"}.Heartbeat("123", "expiredToken")
+	assert.Equal(t, ActionLeaseLostErr, err)
+}
+
+func TestHeartbeat_ShouldReturnActionNotLeasedErr_WhenLeasesAreDisabled(t *testing.T) {
+
+	defer func(d time.Duration) { leaseDuration = d }(leaseDuration)
+	leaseDuration = 0
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		get: func(id string) (*Action, error) {
+			return &Action{Id: id, PackName: "packA", State: State{Value: statePending}}, nil
+		},
+		update: func(action Action) error {
+			t.Fatal("lease should not be written")
+			return nil
+		},
+	}
+
+	_, err := Pack{Name: "packA"}.Heartbeat("123", "")
+	assert.Equal(t, ActionNotLeasedErr, err)
+}
+
+func TestCompleteAction_ShouldReturnActionLeaseLostErr_WhenPackDoesNotHoldTheLease(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		get: func(id string) (*Action, error) {
+			// the lease expired and another pack has taken the action since
+			return &Action{Id: id, State: State{Value: statePending}, LeaseExpiresAt: time.Now(), LeaseToken: "newToken"}, nil
+		},
+		update: func(action Action) error {
+			t.Fatal("action should not be completed")
+			return nil
+		},
+	}
+
+	_, err := Pack{}.CompleteAction("123", "expiredToken", Event{Name: "resultEvent"})
+	assert.Equal(t, ActionLeaseLostErr, err)
+}
+
+func TestCompleteAction_ShouldGuardUpdateByTheLease(t *testing.T) {
+
+	leaseExpiresAt := time.Now()
+	defer resetActionRepo()
+	var updated Action
+	actionRepo = mockActionRepo{
+		get: func(id string) (*Action, error) {
+			return &Action{Id: id, State: State{Value: statePending}, LeaseExpiresAt: leaseExpiresAt, LeaseToken: "token"}, nil
+		},
+		update: func(action Action) error {
+			updated = action
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{update: func(action Action) error { return nil }}
+
+	_, err := Pack{}.CompleteAction("123", "token", Event{Name: "resultEvent"})
+	require.NoError(t, err)
+
+	assert.Equal(t, &lease{token: "token", expiresAt: leaseExpiresAt}, updated.prevLease)
+	assert.Empty(t, updated.LeaseToken)
+	assert.True(t, updated.LeaseExpiresAt.IsZero())
+}
+
+func TestHeartbeat_ShouldReturnActionNotPendingErr_WhenActionIsNotPending(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		get: func(id string) (*Action, error) {
+			return &Action{Id: id, PackName: "packA", State: State{Value: stateNew}}, nil
+		},
+	}
+
+	_, err := Pack{Name: "packA"}.Heartbeat("123", "")
+	assert.Equal(t, ActionNotPendingErr, err)
+}
+
+func TestCompleteAction_ShouldReturnActionNotFoundErr_ForBuiltinAction(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		get: func(id string) (*Action, error) {
+			return &Action{Id: id, Name: approvalCommandName, PackName: builtinPackName, State: State{Value: statePending}}, nil
+		},
+		update: func(a Action) error {
+			t.Fatal("Should not get here")
+			return nil
+		},
+	}
+
+	_, err := Pack{Name: builtinPackName}.CompleteAction("123", "", Event{Name: approvedEventName})
+	assert.Equal(t, ActionNotFoundErr, err)
+}
+
+func TestHeartbeat_ShouldReturnActionNotFoundErr_ForBuiltinAction(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		get: func(id string) (*Action, error) {
+			return &Action{Id: id, Name: waitCommandName, PackName: builtinPackName, State: State{Value: statePending}}, nil
+		},
+	}
+
+	_, err := Pack{Name: builtinPackName}.Heartbeat("123", "")
+	assert.Equal(t, ActionNotFoundErr, err)
+}
+
+func TestTakeActions_ShouldNotTakeBuiltinActions(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findNew: func(pack Pack, name string) (*Action, error) {
+			t.Fatal("built-in actions should not be looked up")
+			return nil, nil
+		},
+		findAllNew: func(pack Pack, name string, limit int) ([]Action, error) {
+			t.Fatal("built-in actions should not be looked up")
+			return nil, nil
+		},
+	}
+
+	pack := Pack{Id: builtinPackName, Name: builtinPackName}
+	action, err := pack.TakeAction("")
+	require.NoError(t, err)
+	assert.Nil(t, action)
+
+	actions, err := pack.TakeActions("", 10)
+	require.NoError(t, err)
+	assert.Empty(t, actions)
+}
+
+func TestHeartbeat_ShouldReturnActionNotFoundErr_WhenActionBelongsToAnotherPack(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		get: func(id string) (*Action, error) {
+			return &Action{Id: id, PackName: "packB", State: State{Value: statePending}}, nil
+		},
+	}
+
+	_, err := Pack{Name: "packA"}.Heartbeat("123", "")
+	assert.Equal(t, ActionNotFoundErr, err)
+}
+
 func TestTakeAction_ShouldReturnActionInPendingState_WhenPackHasAnyNewActionAndNameWasNotSpecified(t *testing.T) {
 
 	//Given
@@ -390,6 +740,125 @@ func TestTakeAction_ShouldReturnErrorForFatalAction(t *testing.T) {
 	assert.Contains(t, err.Error(), "action is not in NEW state, cannot set to PENDING")
 }
 
+func TestTakeActions_ShouldTakeUpToMaxActions(t *testing.T) {
+
+	defer resetActionRepo()
+	newState := State{Value: stateNew}
+	var updated []string
+	actionRepo = mockActionRepo{
+		findAllNew: func(pack Pack, name string, limit int) ([]Action, error) {
+			return []Action{
+				{Id: "1", State: newState, States: []State{newState}},
+				{Id: "2", State: newState, States: []State{newState}},
+				{Id: "3", State: newState, States: []State{newState}},
+			}, nil
+		},
+		update: func(action Action) error {
+			assert.Equal(t, stateNew, action.prevState.Value)
+			updated = append(updated, action.Id)
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	got, err := Pack{Id: "packA"}.TakeActions("", 2)
+	require.NoError(t, err)
+
+	require.Len(t, got, 2)
+	assert.Equal(t, []string{"1", "2"}, updated)
+	assert.Equal(t, statePending, got[0].State.Value)
+	assert.Equal(t, statePending, got[1].State.Value)
+}
+
+func TestTakeActions_ShouldSkipActionsTakenByAnotherPack(t *testing.T) {
+
+	defer resetActionRepo()
+	newState := State{Value: stateNew}
+	actionRepo = mockActionRepo{
+		findAllNew: func(pack Pack, name string, limit int) ([]Action, error) {
+			return []Action{
+				{Id: "1", State: newState, States: []State{newState}},
+				{Id: "2", State: newState, States: []State{newState}},
+			}, nil
+		},
+		update: func(action Action) error {
+			if action.Id == "1" {
+				return mgo.ErrNotFound
+			}
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	got, err := Pack{Id: "packA"}.TakeActions("", 2)
+	require.NoError(t, err)
+
+	require.Len(t, got, 1)
+	assert.Equal(t, "2", got[0].Id)
+}
+
+func TestTakeActions_ShouldReturnActionsTakenSoFar_WhenUpdateFails(t *testing.T) {
+
+	defer resetActionRepo()
+	newState := State{Value: stateNew}
+	actionRepo = mockActionRepo{
+		findAllNew: func(pack Pack, name string, limit int) ([]Action, error) {
+			return []Action{
+				{Id: "1", State: newState, States: []State{newState}},
+				{Id: "2", State: newState, States: []State{newState}},
+			}, nil
+		},
+		update: func(action Action) error {
+			if action.Id == "2" {
+				return errors.New("connection lost")
+			}
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(action Action) error {
+			return nil
+		},
+	}
+
+	got, err := Pack{Id: "packA"}.TakeActions("", 2)
+	require.NoError(t, err)
+
+	require.Len(t, got, 1)
+	assert.Equal(t, "1", got[0].Id)
+}
+
+func TestTakeActions_ShouldReturnError_WhenNoActionCouldBeTaken(t *testing.T) {
+
+	defer resetActionRepo()
+	newState := State{Value: stateNew}
+	actionRepo = mockActionRepo{
+		findAllNew: func(pack Pack, name string, limit int) ([]Action, error) {
+			return []Action{{Id: "1", State: newState, States: []State{newState}}}, nil
+		},
+		update: func(action Action) error {
+			return errors.New("connection lost")
+		},
+	}
+
+	_, err := Pack{Id: "packA"}.TakeActions("", 2)
+	assert.EqualError(t, err, "connection lost")
+}
+
 func TestUpdateLastSeen_ShouldRecordLastSeen(t *testing.T) {
 
 	defer resetPackRepo()
@@ -409,11 +878,19 @@ func TestUpdateLastSeen_ShouldRecordLastSeen(t *testing.T) {
 // --- mocks & helpers ---
 
 type mockActionRepo struct {
-	add            func(a Action) error
-	get            func(actionId string) (*Action, error)
-	update         func(a Action) error
-	findNew        func(p Pack, name string) (*Action, error)
-	findCorrelated func(correlationId string) ([]Action, error)
+	add              func(a Action) error
+	get              func(actionId string) (*Action, error)
+	update           func(a Action) error
+	findNew          func(p Pack, name string) (*Action, error)
+	findAllNew       func(p Pack, name string, limit int) ([]Action, error)
+	findExpired      func(now time.Time) ([]Action, error)
+	findLeaseExpired func(now time.Time) ([]Action, error)
+	findCorrelated   func(correlationId string) ([]Action, error)
+	findBuiltinDue   func(now time.Time) ([]Action, error)
+	findQueued       func(flowName, stepId, queue string) (*Action, error)
+	findAllQueued    func() ([]Action, error)
+	countInFlight    func(flowName, stepId string) (int, error)
+	countRunning     func(flowName string) (int, error)
 }
 
 func (r mockActionRepo) Add(a Action) error {
@@ -432,10 +909,42 @@ func (r mockActionRepo) FindNew(p Pack, name string) (*Action, error) {
 	return r.findNew(p, name)
 }
 
+func (r mockActionRepo) FindAllNew(p Pack, name string, limit int) ([]Action, error) {
+	return r.findAllNew(p, name, limit)
+}
+
+func (r mockActionRepo) FindExpired(now time.Time) ([]Action, error) {
+	return r.findExpired(now)
+}
+
+func (r mockActionRepo) FindLeaseExpired(now time.Time) ([]Action, error) {
+	return r.findLeaseExpired(now)
+}
+
 func (r mockActionRepo) FindCorrelated(correlationId string) ([]Action, error) {
 	return r.findCorrelated(correlationId)
 }
 
+func (r mockActionRepo) FindBuiltinDue(now time.Time) ([]Action, error) {
+	return r.findBuiltinDue(now)
+}
+
+func (r mockActionRepo) FindQueued(flowName, stepId, queue string) (*Action, error) {
+	return r.findQueued(flowName, stepId, queue)
+}
+
+func (r mockActionRepo) FindAllQueued() ([]Action, error) {
+	return r.findAllQueued()
+}
+
+func (r mockActionRepo) CountInFlight(flowName, stepId string) (int, error) {
+	return r.countInFlight(flowName, stepId)
+}
+
+func (r mockActionRepo) CountRunningExecutions(flowName string) (int, error) {
+	return r.countRunning(flowName)
+}
+
 func resetActionRepo() { actionRepo = actionMgoRepo{} }
 
 type mockAuditRepo struct {
@@ -34,6 +34,10 @@ func (r packMgoRepo) Get(id string) (*Pack, error) {
 	if err == mgo.ErrNotFound {
 		return nil, PackNotFoundErr
 	}
+	if err == nil && pack.isBuiltin() {
+		// registered before the name was reserved, it cannot send events or handle the built-in actions
+		return nil, PackNotFoundErr
+	}
 	return &pack, err
 }
 
@@ -49,6 +49,16 @@ func TestGet_ShouldReturnNilForNonExistingPack(t *testing.T) {
 	assert.EqualError(t, err, PackNotFoundErr.Error())
 }
 
+func TestGet_ShouldReturnPackNotFoundErrForPackWithBuiltinPackName(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	mongoT.Insert(t, mongo.PackCollectionId, Pack{Id: "Flyte", Name: builtinPackName})
+
+	_, err := packRepo.Get("Flyte")
+
+	assert.Equal(t, PackNotFoundErr, err)
+}
+
 func TestUpdateLastSeen_ShouldRecordLastSeenWithCurrentDate(t *testing.T) {
 
 	mongoT.DropDatabase(t)
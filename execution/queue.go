@@ -0,0 +1,318 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"fmt"
+	"github.com/jasonlvhit/gocron"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+// what a QUEUED action waits for, a free slot for its flow execution or for the command of its step
+const (
+	queueExecution = "execution"
+	queueCommand   = "command"
+)
+
+const (
+	// how long the limits of a flow stay locked by an instance that stops before unlocking them
+	limitsLockLease = 30 * time.Second
+	// how long locking the limits is attempted while another instance holds the lock, longer than the lease so the
+	// lock of an instance that stopped holding it expires in the meantime and the actions are not dropped
+	limitsLockTimeout = limitsLockLease + 10*time.Second
+)
+
+var limitsLockWait = 50 * time.Millisecond
+
+var limitsRepo LimitsRepository = limitsMgoRepo{}
+
+type LimitsRepository interface {
+	Lock(flowName, holder string, now time.Time, lease time.Duration) (bool, error)
+	Unlock(flowName, holder string) error
+}
+
+// Serialises checking the limits of the flow with queueing or releasing the actions they apply to, across flyte
+// instances, so the actions in flight are counted and saved by one of them at a time.
+func lockLimits(flowName string) (unlock func(), err error) {
+
+	holder := bson.NewObjectId().Hex()
+	deadline := currentTime().Add(limitsLockTimeout)
+	for {
+		now := currentTime().UTC()
+		locked, err := limitsRepo.Lock(flowName, holder, now, limitsLockLease)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			return func() {
+				if err := limitsRepo.Unlock(flowName, holder); err != nil {
+					log.Err(err).Msgf("Error unlocking limits of flow=%s", flowName)
+				}
+			}, nil
+		}
+		if now.After(deadline) {
+			return nil, fmt.Errorf("limits of flow=%s are locked by another instance", flowName)
+		}
+		time.Sleep(limitsLockWait)
+	}
+}
+
+func (f Flow) isLimited(a Action) bool {
+	return f.queued || a.MaxInFlight > 0 || (f.MaxConcurrentExecutions > 0 && f.isNewExecution())
+}
+
+// no action has been saved in the flow execution yet
+func (f Flow) isNewExecution() bool {
+	return len(f.actions) == 0 && len(f.iterations) == 0
+}
+
+// Queues the action when its flow execution or the command of its step has reached its limit. The first action of a new
+// flow execution decides whether the execution is queued, the actions following it in the execution are queued with it.
+func (f *Flow) applyLimits(a *Action) error {
+
+	if f.MaxConcurrentExecutions > 0 && f.isNewExecution() {
+		running, err := actionRepo.CountRunningExecutions(f.Name)
+		if err != nil {
+			return err
+		}
+		f.queued = running >= f.MaxConcurrentExecutions
+	}
+
+	switch {
+	case a.ForEach != nil:
+		// the action of a forEach step is not run, its iterations are queued instead
+	case f.queued:
+		a.enqueue(queueExecution)
+	case a.MaxInFlight > 0:
+		inFlight, err := actionRepo.CountInFlight(f.Name, a.StepId)
+		if err != nil {
+			return err
+		}
+		if inFlight >= a.MaxInFlight {
+			a.enqueue(queueCommand)
+		}
+	}
+	return nil
+}
+
+// A queued action is not available to packs and its take timeout does not start until it is released. The time it has
+// been queued at orders the queue, and keeps the action from expiring (see FLYTE_TTL_IN_SECONDS) while it waits.
+func (a *Action) enqueue(queue string) {
+	a.State = State{Value: stateQueued, Time: time.Now().UTC()}
+	a.States = []State{a.State}
+	a.ExpiresAt = time.Time{}
+	a.Queue = queue
+}
+
+// releases a queued action, it becomes NEW and is available to packs as if it had just been created
+func (a *Action) release() error {
+
+	if a.State.Value != stateQueued {
+		return fmt.Errorf("action is not in %s state, cannot set to %s", stateQueued, stateNew)
+	}
+	a.setState(stateNew)
+	a.Queue = ""
+	a.ExpiresAt = a.Timeout.takeDeadline(a.State.Time)
+	if a.isBuiltin() {
+		a.scheduleBuiltin()
+	}
+	if err := a.update(); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("ActionId", a.Id).
+		Str("CorrelationId", a.CorrelationId).
+		Str("FlowName", a.FlowName).
+		Str("StepId", a.StepId).
+		Msg("Queued action released")
+
+	if a.isBuiltin() && !a.WakeAt.After(a.State.Time) {
+		runBuiltinNow(*a)
+	} else {
+		newActions.notify()
+	}
+	return nil
+}
+
+// The finished action leaves room for a queued action of its step, the finished flow execution for a queued execution.
+// The limit of the flow executions is the one of the flow version the finished execution runs.
+func (f Flow) releaseQueued(a Action) {
+
+	if a.MaxInFlight > 0 {
+		releaseCommandQueue(a.FlowName, a.StepId)
+	}
+	if f.MaxConcurrentExecutions > 0 && !f.isRunning() {
+		releaseExecutionQueue(f.Name, f.MaxConcurrentExecutions)
+	}
+}
+
+// releases the queued actions of the step, oldest first, as long as fewer actions than the limit are in flight
+func releaseCommandQueue(flowName, stepId string) {
+
+	unlock, err := lockLimits(flowName)
+	if err != nil {
+		logReleaseErr(err, flowName, stepId)
+		return
+	}
+	defer unlock()
+
+	for {
+		queued, err := actionRepo.FindQueued(flowName, stepId, queueCommand)
+		if err != nil || queued == nil {
+			logReleaseErr(err, flowName, stepId)
+			return
+		}
+		inFlight, err := actionRepo.CountInFlight(flowName, stepId)
+		if err != nil || inFlight >= queued.MaxInFlight {
+			logReleaseErr(err, flowName, stepId)
+			return
+		}
+		// another flyte instance may have released the action in the meantime, in which case update fails
+		if err := queued.release(); err != nil {
+			logReleaseErr(err, flowName, stepId)
+			return
+		}
+	}
+}
+
+// releases the queued executions of the flow, oldest first, as long as fewer executions than the limit (0 is no limit)
+// are running
+func releaseExecutionQueue(flowName string, limit int) {
+
+	unlock, err := lockLimits(flowName)
+	if err != nil {
+		logReleaseErr(err, flowName, "")
+		return
+	}
+	defer unlock()
+
+	for {
+		if limit > 0 {
+			running, err := actionRepo.CountRunningExecutions(flowName)
+			if err != nil || running >= limit {
+				logReleaseErr(err, flowName, "")
+				return
+			}
+		}
+		queued, err := actionRepo.FindQueued(flowName, "", queueExecution)
+		if err != nil || queued == nil {
+			logReleaseErr(err, flowName, "")
+			return
+		}
+		if err := releaseExecution(queued.CorrelationId); err != nil {
+			logReleaseErr(err, flowName, "")
+			return
+		}
+	}
+}
+
+// Releases the actions of a queued flow execution. Those whose command has reached its limit are moved to the queue
+// of the command.
+func releaseExecution(correlationId string) error {
+
+	actions, err := actionRepo.FindCorrelated(correlationId)
+	if err != nil {
+		return err
+	}
+	for _, c := range actions {
+		if c.State.Value != stateQueued || c.Queue != queueExecution {
+			continue
+		}
+		a, err := actionRepo.Get(c.Id)
+		if err != nil {
+			return err
+		}
+		if a.MaxInFlight > 0 {
+			inFlight, err := actionRepo.CountInFlight(a.FlowName, a.StepId)
+			if err != nil {
+				return err
+			}
+			if inFlight >= a.MaxInFlight {
+				a.prevState = a.State
+				a.enqueue(queueCommand)
+				if err := a.update(); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := a.release(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func logReleaseErr(err error, flowName, stepId string) {
+	if err != nil {
+		log.Err(err).Msgf("Error releasing queued actions of flow=%s step=%s", flowName, stepId)
+	}
+}
+
+// Checks every interval for queued actions that can be released, in case the instance that should have released them,
+// once the action or flow execution they waited for finished, has stopped. Flow executions are released with the limit
+// of the current version of their flow.
+func ScheduleQueuedActionRelease(intervalInSeconds int) (*gocron.Scheduler, chan bool) {
+	s := gocron.NewScheduler()
+	s.Every(uint64(intervalInSeconds)).Seconds().Do(releaseQueuedActions)
+	sc := s.Start()
+	return s, sc
+}
+
+func releaseQueuedActions() {
+
+	queued, err := actionRepo.FindAllQueued()
+	if err != nil {
+		log.Err(err).Msg("Error finding queued actions")
+		return
+	}
+
+	steps, flows := map[string]bool{}, map[string]bool{}
+	for _, a := range queued {
+		switch a.Queue {
+		case queueCommand:
+			if k := a.FlowName + "/" + a.StepId; !steps[k] {
+				steps[k] = true
+				releaseCommandQueue(a.FlowName, a.StepId)
+			}
+		case queueExecution:
+			if !flows[a.FlowName] {
+				flows[a.FlowName] = true
+				releaseExecutionQueueOf(a.FlowName)
+			}
+		}
+	}
+}
+
+func releaseExecutionQueueOf(flowName string) {
+
+	limit := 0
+	flow, err := flowRepo.Get(flowName)
+	switch {
+	case err == FlowNotFoundErr:
+		// the flow has been removed, nothing limits its executions anymore
+	case err != nil:
+		log.Err(err).Msgf("Error getting flow=%s to release its queued executions", flowName)
+		return
+	default:
+		limit = flow.MaxConcurrentExecutions
+	}
+	releaseExecutionQueue(flowName, limit)
+}
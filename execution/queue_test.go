@@ -0,0 +1,562 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestAddAction_ShouldQueueNewExecutionOverTheLimit(t *testing.T) {
+
+	//Given
+	defer resetLimitsRepo()
+	setupLimitsRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	var added []Action
+	actionRepo = mockActionRepo{
+		add: func(a Action) error {
+			added = append(added, a)
+			return nil
+		},
+		countRunning: func(flowName string) (int, error) {
+			require.Equal(t, "alerts", flowName)
+			return 2, nil
+		},
+	}
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	flow := newLimitedFlowT()
+	flow.MaxConcurrentExecutions = 2
+
+	//When
+	require.NoError(t, flow.addAction("notify", newQueueActionT("1", stateNew)))
+	// the following actions of the execution are queued with it, running executions are not counted again
+	actionRepo = mockActionRepo{add: func(a Action) error {
+		added = append(added, a)
+		return nil
+	}}
+	require.NoError(t, flow.addAction("page", newQueueActionT("2", stateNew)))
+
+	//Then
+	require.Len(t, added, 2)
+	for _, a := range added {
+		assert.Equal(t, stateQueued, a.State.Value)
+		assert.Equal(t, []string{stateQueued}, stateValues(a.States))
+		assert.Equal(t, queueExecution, a.Queue)
+		assert.True(t, a.ExpiresAt.IsZero())
+	}
+	assert.True(t, flow.queued)
+}
+
+func TestAddAction_ShouldNotQueueNewExecutionUnderTheLimit(t *testing.T) {
+
+	defer resetLimitsRepo()
+	setupLimitsRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	var added Action
+	actionRepo = mockActionRepo{
+		add: func(a Action) error {
+			added = a
+			return nil
+		},
+		countRunning: func(flowName string) (int, error) { return 1, nil },
+	}
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	flow := newLimitedFlowT()
+	flow.MaxConcurrentExecutions = 2
+
+	require.NoError(t, flow.addAction("notify", newQueueActionT("1", stateNew)))
+
+	assert.Equal(t, stateNew, added.State.Value)
+	assert.Empty(t, added.Queue)
+	assert.False(t, flow.queued)
+}
+
+func TestAddAction_ShouldQueueActionOverMaxInFlightOfItsCommand(t *testing.T) {
+
+	defer resetLimitsRepo()
+	setupLimitsRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	cases := map[int]string{1: stateNew, 2: stateQueued}
+	for inFlight, want := range cases {
+		var added Action
+		actionRepo = mockActionRepo{
+			add: func(a Action) error {
+				added = a
+				return nil
+			},
+			countInFlight: func(flowName, stepId string) (int, error) {
+				require.Equal(t, "alerts", flowName)
+				require.Equal(t, "page", stepId)
+				return inFlight, nil
+			},
+		}
+		auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+		// the flow execution has been started already
+		flow := newLimitedFlowT()
+		flow.MaxConcurrentExecutions = 1
+		flow.actions["notify"] = Action{StepId: "notify", State: State{Value: stateSuccess}}
+		a := newQueueActionT("2", stateNew)
+		a.MaxInFlight = 2
+
+		require.NoError(t, flow.addAction("page", a))
+
+		assert.Equal(t, want, added.State.Value, "in flight: %d", inFlight)
+		if want == stateQueued {
+			assert.Equal(t, queueCommand, added.Queue)
+			// the action does not expire before it has been queued for the TTL
+			assert.True(t, added.State.Time.After(a.State.Time))
+		}
+	}
+}
+
+func TestAddAction_ShouldQueueIterationsButNotActionOfForEachStep(t *testing.T) {
+
+	defer resetLimitsRepo()
+	setupLimitsRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	var added []Action
+	actionRepo = mockActionRepo{
+		add: func(a Action) error {
+			added = append(added, a)
+			return nil
+		},
+		countRunning: func(flowName string) (int, error) { return 1, nil },
+	}
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	flow := newLimitedFlowT()
+	flow.MaxConcurrentExecutions = 1
+	a := Action{Id: "1", State: State{Value: statePending}, ForEach: &ForEach{Items: 2}}
+	a.iterations = []Action{newQueueActionT("2", stateNew), newQueueActionT("3", stateNew)}
+
+	require.NoError(t, flow.addAction("page", a))
+
+	require.Len(t, added, 3)
+	assert.Equal(t, statePending, added[0].State.Value)
+	assert.Equal(t, stateQueued, added[1].State.Value)
+	assert.Equal(t, stateQueued, added[2].State.Value)
+}
+
+func TestReleaseCommandQueue_ShouldReleaseQueuedActionsWhileUnderTheLimit(t *testing.T) {
+
+	//Given
+	queued := []Action{newQueuedActionT("1", queueCommand), newQueuedActionT("2", queueCommand), newQueuedActionT("3", queueCommand)}
+	for i := range queued {
+		queued[i].MaxInFlight = 3
+	}
+	inFlight := 1
+
+	defer resetLimitsRepo()
+	setupLimitsRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	var released []Action
+	actionRepo = mockActionRepo{
+		findQueued: func(flowName, stepId, queue string) (*Action, error) {
+			require.Equal(t, "alerts", flowName)
+			require.Equal(t, "page", stepId)
+			require.Equal(t, queueCommand, queue)
+			if len(queued) == 0 {
+				return nil, nil
+			}
+			a := queued[0]
+			return &a, nil
+		},
+		countInFlight: func(flowName, stepId string) (int, error) { return inFlight, nil },
+		update: func(a Action) error {
+			queued = queued[1:]
+			inFlight++
+			released = append(released, a)
+			return nil
+		},
+	}
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	//When
+	releaseCommandQueue("alerts", "page")
+
+	//Then
+	require.Len(t, released, 2)
+	for _, a := range released {
+		assert.Equal(t, stateNew, a.State.Value)
+		assert.Equal(t, stateQueued, a.prevState.Value)
+		assert.Empty(t, a.Queue)
+		assert.Equal(t, a.State.Time.Add(time.Minute), a.ExpiresAt)
+	}
+	assert.Equal(t, "1", released[0].Id)
+	assert.Equal(t, "2", released[1].Id)
+}
+
+func TestReleaseExecution_ShouldMoveActionsOverMaxInFlightToCommandQueue(t *testing.T) {
+
+	//Given
+	page := newQueuedActionT("1", queueExecution)
+	page.MaxInFlight = 1
+	notify := newQueuedActionT("2", queueExecution)
+	notify.StepId = "notify"
+	stored := map[string]Action{"1": page, "2": notify}
+
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	updated := map[string]Action{}
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			require.Equal(t, "exec", correlationId)
+			return []Action{page, notify, {Id: "3", State: State{Value: stateSuccess}}}, nil
+		},
+		get: func(actionId string) (*Action, error) {
+			a := stored[actionId]
+			return &a, nil
+		},
+		countInFlight: func(flowName, stepId string) (int, error) {
+			require.Equal(t, "page", stepId)
+			return 1, nil
+		},
+		update: func(a Action) error {
+			updated[a.Id] = a
+			return nil
+		},
+	}
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	//When
+	require.NoError(t, releaseExecution("exec"))
+
+	//Then
+	require.Len(t, updated, 2)
+	assert.Equal(t, stateQueued, updated["1"].State.Value)
+	assert.Equal(t, stateQueued, updated["1"].prevState.Value)
+	assert.Equal(t, queueCommand, updated["1"].Queue)
+	assert.True(t, updated["1"].State.Time.After(page.State.Time))
+	assert.Equal(t, stateNew, updated["2"].State.Value)
+	assert.Empty(t, updated["2"].Queue)
+}
+
+func TestHandleAction_ShouldReleaseQueuedExecutionOnceExecutionFinishes(t *testing.T) {
+
+	//Given
+	done := Action{Id: "done", StepId: "notify", FlowName: "alerts", State: State{Value: stateSuccess}, Result: Event{Name: "Sent", Pack: Pack{Name: "Slack"}}}
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		getByAction: func(a Action) (*Flow, error) {
+			flow := newLimitedFlowT()
+			flow.MaxConcurrentExecutions = 1
+			flow.actions["notify"] = done
+			return &flow, nil
+		},
+	}
+
+	queued := newQueuedActionT("1", queueExecution)
+	released := false
+	defer resetLimitsRepo()
+	setupLimitsRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	actionRepo = mockActionRepo{
+		countRunning: func(flowName string) (int, error) {
+			if released {
+				return 1, nil
+			}
+			return 0, nil
+		},
+		findQueued: func(flowName, stepId, queue string) (*Action, error) {
+			require.Equal(t, queueExecution, queue)
+			return &queued, nil
+		},
+		findCorrelated: func(correlationId string) ([]Action, error) { return []Action{queued}, nil },
+		get:            func(actionId string) (*Action, error) { return &queued, nil },
+		update: func(a Action) error {
+			released = a.State.Value == stateNew
+			return nil
+		},
+	}
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+	defer resetStepExecutor()
+	setupStepExecutor(nil, nil)
+
+	//When
+	flowService{}.HandleAction(done)
+
+	//Then
+	assert.True(t, released)
+}
+
+func TestHandleAction_ShouldNotReleaseQueuedExecutionWhileExecutionIsRunning(t *testing.T) {
+
+	done := Action{Id: "done", StepId: "notify", FlowName: "alerts", State: State{Value: stateSuccess}, Result: Event{Name: "Sent", Pack: Pack{Name: "Slack"}}}
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		getByAction: func(a Action) (*Flow, error) {
+			flow := newLimitedFlowT()
+			flow.MaxConcurrentExecutions = 1
+			flow.actions["notify"] = done
+			flow.actions["page"] = Action{StepId: "page", State: State{Value: statePending}}
+			return &flow, nil
+		},
+	}
+	defer resetLimitsRepo()
+	setupLimitsRepoT()
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{}
+	defer resetStepExecutor()
+	setupStepExecutor(nil, nil)
+
+	// the mock repo has no functions to release queued actions with
+	flowService{}.HandleAction(done)
+}
+
+func TestCancelFlow_ShouldCancelQueuedActionsAndReleaseTheirCommandQueue(t *testing.T) {
+
+	//Given
+	pending := Action{Id: "1", FlowName: "alerts", StepId: "page", State: State{Value: statePending}, MaxInFlight: 1}
+	queued := newQueuedActionT("2", queueCommand)
+	queued.MaxInFlight = 1
+	stored := map[string]Action{"1": pending, "2": queued}
+
+	defer resetFlowRepo()
+	setupCancelledFlowRepoT()
+	defer resetLimitsRepo()
+	setupLimitsRepoT()
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	var cancelled []string
+	releasedFor := ""
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) { return []Action{pending, queued}, nil },
+		get: func(actionId string) (*Action, error) {
+			a := stored[actionId]
+			return &a, nil
+		},
+		update: func(a Action) error {
+			cancelled = append(cancelled, a.Id)
+			return nil
+		},
+		findQueued: func(flowName, stepId, queue string) (*Action, error) {
+			releasedFor = flowName + "/" + stepId
+			return nil, nil
+		},
+	}
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+
+	//When
+	n, err := cancelFlowFn("exec", Cancellation{By: "jdoe"})
+
+	//Then
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"1", "2"}, cancelled)
+	assert.Equal(t, "alerts/page", releasedFor)
+}
+
+func TestRelease_ShouldRunBuiltinActionStraightAway(t *testing.T) {
+
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	actionRepo = mockActionRepo{update: func(a Action) error { return nil }}
+	auditRepo = mockAuditRepo{update: func(a Action) error { return nil }}
+	defer resetRunBuiltinNow()
+	var run Action
+	runBuiltinNow = func(a Action) { run = a }
+
+	a := newBuiltinActionT("1", waitCommandName, map[string]interface{}{"duration": "0s"})
+	a.enqueue(queueCommand)
+
+	require.NoError(t, a.release())
+
+	assert.Equal(t, "1", run.Id)
+	assert.Equal(t, []string{stateQueued, stateNew}, stateValues(run.States))
+}
+
+func TestAddAction_ShouldCountAndSaveActionWhileHoldingLockOfLimits(t *testing.T) {
+
+	//Given
+	defer resetLimitsRepo()
+	var calls []string
+	limitsRepo = mockLimitsRepo{
+		lock: func(flowName, holder string, now time.Time, lease time.Duration) (bool, error) {
+			require.Equal(t, "alerts", flowName)
+			calls = append(calls, "lock")
+			// held by another instance at first
+			return len(calls) > 2, nil
+		},
+		unlock: func(flowName, holder string) error {
+			calls = append(calls, "unlock")
+			return nil
+		},
+	}
+	defer func(wait time.Duration) { limitsLockWait = wait }(limitsLockWait)
+	limitsLockWait = 0
+
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	actionRepo = mockActionRepo{
+		add: func(a Action) error {
+			calls = append(calls, "add")
+			return nil
+		},
+		countInFlight: func(flowName, stepId string) (int, error) {
+			calls = append(calls, "count")
+			return 0, nil
+		},
+	}
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	flow := newLimitedFlowT()
+	a := newQueueActionT("1", stateNew)
+	a.MaxInFlight = 1
+
+	//When
+	require.NoError(t, flow.addAction("page", a))
+
+	//Then
+	assert.Equal(t, []string{"lock", "lock", "lock", "count", "add", "unlock"}, calls)
+}
+
+func TestAddAction_ShouldWaitForLockOfStoppedInstanceToExpire(t *testing.T) {
+
+	//Given
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	defer resetCurrentTime()
+	currentTime = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	defer resetLimitsRepo()
+	limitsRepo = mockLimitsRepo{
+		lock: func(flowName, holder string, now time.Time, lease time.Duration) (bool, error) {
+			// the instance holding the lock stopped when the lease started
+			return now.After(start.Add(lease)), nil
+		},
+		unlock: func(flowName, holder string) error { return nil },
+	}
+	defer func(wait time.Duration) { limitsLockWait = wait }(limitsLockWait)
+	limitsLockWait = 0
+
+	defer resetActionRepo()
+	defer resetAuditRepo()
+	var added []Action
+	actionRepo = mockActionRepo{
+		add: func(a Action) error {
+			added = append(added, a)
+			return nil
+		},
+		countInFlight: func(flowName, stepId string) (int, error) { return 0, nil },
+	}
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	flow := newLimitedFlowT()
+	a := newQueueActionT("1", stateNew)
+	a.MaxInFlight = 1
+
+	//When
+	err := flow.addAction("page", a)
+
+	//Then
+	require.NoError(t, err)
+	assert.Len(t, added, 1)
+}
+
+func TestAddAction_ShouldFailWhenLimitsStayLockedByAnotherInstance(t *testing.T) {
+
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	defer resetCurrentTime()
+	currentTime = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	defer resetLimitsRepo()
+	attempts := 0
+	limitsRepo = mockLimitsRepo{
+		lock: func(flowName, holder string, now time.Time, lease time.Duration) (bool, error) {
+			attempts++
+			return false, nil
+		},
+	}
+	defer func(wait time.Duration) { limitsLockWait = wait }(limitsLockWait)
+	limitsLockWait = 0
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		add: func(a Action) error {
+			t.Fatal("Should not get here")
+			return nil
+		},
+	}
+
+	flow := newLimitedFlowT()
+	a := newQueueActionT("1", stateNew)
+	a.MaxInFlight = 1
+
+	err := flow.addAction("page", a)
+
+	assert.EqualError(t, err, "limits of flow=alerts are locked by another instance")
+	// attempted a second apart for longer than the lease
+	assert.True(t, time.Duration(attempts)*time.Second > limitsLockLease)
+}
+
+func newLimitedFlowT() Flow {
+	flow := newFlowT(newStepT("notify", "Alert", "Monitor"), newStepT("page", "Alert", "Monitor"))
+	flow.Name = "alerts"
+	return flow
+}
+
+func newQueueActionT(id, state string) Action {
+	s := State{Value: state, Time: time.Now().UTC()}
+	return Action{Id: id, State: s, States: []State{s}, Timeout: Timeout{Take: "1m"}, ExpiresAt: s.Time.Add(time.Minute)}
+}
+
+func newQueuedActionT(id, queue string) Action {
+	a := newQueueActionT(id, stateNew)
+	a.FlowName = "alerts"
+	a.StepId = "page"
+	a.CorrelationId = "exec"
+	a.enqueue(queue)
+	return a
+}
+
+type mockLimitsRepo struct {
+	lock   func(flowName, holder string, now time.Time, lease time.Duration) (bool, error)
+	unlock func(flowName, holder string) error
+}
+
+func (r mockLimitsRepo) Lock(flowName, holder string, now time.Time, lease time.Duration) (bool, error) {
+	return r.lock(flowName, holder, now, lease)
+}
+
+func (r mockLimitsRepo) Unlock(flowName, holder string) error {
+	return r.unlock(flowName, holder)
+}
+
+func setupLimitsRepoT() {
+	limitsRepo = mockLimitsRepo{
+		lock:   func(flowName, holder string, now time.Time, lease time.Duration) (bool, error) { return true, nil },
+		unlock: func(flowName, holder string) error { return nil },
+	}
+}
+
+func resetLimitsRepo() { limitsRepo = limitsMgoRepo{} }
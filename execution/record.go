@@ -0,0 +1,124 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+const (
+	executionQueued    = "QUEUED"
+	executionRunning   = "RUNNING"
+	executionSucceeded = "SUCCEEDED"
+	executionFailed    = "FAILED"
+	executionCancelled = "CANCELLED"
+	executionTimedOut  = "TIMED_OUT"
+)
+
+// Record is the status of a flow execution, it is updated with the audit of its actions whenever they change.
+type Record struct {
+	CorrelationId  string    `bson:"_id"`
+	CorrelationKey string    `bson:"correlationKey,omitempty"`
+	FlowName       string    `bson:"flowName"`
+	FlowUUID       string    `bson:"flowUUID"`
+	Status         string    `bson:"status"`
+	StartedAt      time.Time `bson:"startedAt"`
+	LastActivityAt time.Time `bson:"lastActivityAt"`
+	TerminalStepId string    `bson:"terminalStepId,omitempty"`
+
+	// kept up to date by every change of an action, the status is derived from them
+	Unfinished int           `bson:"unfinished"`
+	Queued     int           `bson:"queued"`
+	Cancelled  bool          `bson:"cancelled,omitempty"`
+	Terminal   *terminalStep `bson:"terminal,omitempty"`
+	Version    int           `bson:"version"`
+}
+
+// The step action that finished last. Time is the first field, so $max keeps the latest one.
+type terminalStep struct {
+	Time   time.Time `bson:"time"`
+	StepId string    `bson:"stepId"`
+	State  string    `bson:"state"`
+}
+
+// The change an action makes to the record of its flow execution, prev is the action before the change and nil for
+// a new action. Only the action itself is needed, so the record is updated without reading the other actions
+// of the execution.
+func recordUpdate(a Action, prev *Action) bson.M {
+
+	unfinished, queued := a.recordCounts()
+	if prev != nil {
+		prevUnfinished, prevQueued := prev.recordCounts()
+		unfinished -= prevUnfinished
+		queued -= prevQueued
+	}
+
+	onInsert := bson.M{"flowName": a.FlowName, "flowUUID": a.FlowUUID}
+	if a.CorrelationKey != "" {
+		onInsert["correlationKey"] = a.CorrelationKey
+	}
+	max := bson.M{"lastActivityAt": a.State.Time}
+	if a.Cancellation != nil {
+		max["cancelled"] = true
+	}
+	// the actions of onError and finally steps and the iterations of a forEach step do not decide the status
+	if a.hasFinished() && a.Handler == "" && a.Iteration == nil {
+		max["terminal"] = terminalStep{Time: a.State.Time, StepId: a.StepId, State: a.State.Value}
+	}
+
+	return bson.M{
+		"$setOnInsert": onInsert,
+		"$min":         bson.M{"startedAt": a.createdAt()},
+		"$max":         max,
+		"$inc":         bson.M{"unfinished": unfinished, "queued": queued, "version": 1},
+	}
+}
+
+func (a Action) recordCounts() (unfinished, queued int) {
+	if !a.hasFinished() {
+		unfinished = 1
+	}
+	if a.State.Value == stateQueued && a.Queue == queueExecution {
+		queued = 1
+	}
+	return unfinished, queued
+}
+
+// A flow execution is queued while it waits for running executions of the flow to finish, see
+// Flow.MaxConcurrentExecutions, and running as long as any of its actions has not finished. Once they have all finished,
+// its status follows the action that finished last, the same way as the outcome of a sub-flow. The actions
+// of onError and finally steps do not decide the status, a failed execution stays failed once it is handled.
+func (r *Record) setStatus() {
+
+	r.TerminalStepId = ""
+	switch {
+	case r.Cancelled:
+		r.Status = executionCancelled
+	case r.Queued > 0:
+		r.Status = executionQueued
+	case r.Unfinished > 0 || r.Terminal == nil:
+		r.Status = executionRunning
+	default:
+		r.TerminalStepId = r.Terminal.StepId
+		r.Status = map[string]string{
+			stateSuccess: executionSucceeded,
+			stateFatal:   executionFailed,
+			stateTimeout: executionTimedOut,
+		}[r.Terminal.State]
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+	"time"
+)
+
+func TestRecordUpdate_ShouldCountNewActionAsUnfinished(t *testing.T) {
+
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := newRecordActionT("deploy", start, statePending, start.Add(time.Minute))
+	a.FlowName = "deploy"
+	a.FlowUUID = "deployV1"
+	a.CorrelationKey = "INC-42"
+
+	got := recordUpdate(a, nil)
+
+	want := bson.M{
+		"$setOnInsert": bson.M{"flowName": "deploy", "flowUUID": "deployV1", "correlationKey": "INC-42"},
+		"$min":         bson.M{"startedAt": start},
+		"$max":         bson.M{"lastActivityAt": start.Add(time.Minute)},
+		"$inc":         bson.M{"unfinished": 1, "queued": 0, "version": 1},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestRecordUpdate_ShouldRecordFinishedActionAsTerminal(t *testing.T) {
+
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	prev := newRecordActionT("deploy", start, statePending, start.Add(time.Minute))
+	a := newRecordActionT("deploy", start, stateFatal, start.Add(2*time.Minute))
+
+	got := recordUpdate(a, &prev)
+
+	assert.Equal(t, bson.M{"unfinished": -1, "queued": 0, "version": 1}, got["$inc"])
+	assert.Equal(t, bson.M{
+		"lastActivityAt": start.Add(2 * time.Minute),
+		"terminal":       terminalStep{Time: start.Add(2 * time.Minute), StepId: "deploy", State: stateFatal},
+	}, got["$max"])
+}
+
+func TestRecordUpdate_ShouldNotRecordActionsOfOnErrorAndFinallyStepsOrIterationsAsTerminal(t *testing.T) {
+
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	cleanUp := newRecordActionT("clean_up", start, stateSuccess, start.Add(time.Minute))
+	cleanUp.Handler = finallyHandler
+	iteration := newRecordActionT("restart", start, stateSuccess, start.Add(time.Minute))
+	iteration.Iteration = &Iteration{Index: 1}
+
+	for _, a := range []Action{cleanUp, iteration} {
+		got := recordUpdate(a, nil)
+
+		assert.NotContains(t, got["$max"], "terminal", a.StepId)
+	}
+}
+
+func TestRecordUpdate_ShouldCountActionsWaitingForExecutionToBeReleased(t *testing.T) {
+
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	queued := newRecordActionT("notify", start, stateQueued, start)
+	queued.Queue = queueExecution
+	// an action waiting for its command does not hold the whole execution back
+	commandQueued := queued
+	commandQueued.Queue = queueCommand
+	released := newRecordActionT("notify", start, stateNew, start.Add(time.Minute))
+
+	assert.Equal(t, bson.M{"unfinished": 1, "queued": 1, "version": 1}, recordUpdate(queued, nil)["$inc"])
+	assert.Equal(t, bson.M{"unfinished": 0, "queued": -1, "version": 1}, recordUpdate(commandQueued, &queued)["$inc"])
+	assert.Equal(t, bson.M{"unfinished": 0, "queued": -1, "version": 1}, recordUpdate(released, &queued)["$inc"])
+}
+
+func TestRecordUpdate_ShouldMarkCancelledAction(t *testing.T) {
+
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := newRecordActionT("deploy", start, stateCancelled, start.Add(time.Minute))
+	a.Cancellation = &Cancellation{By: "jdoe"}
+
+	got := recordUpdate(a, nil)
+
+	assert.Equal(t, true, got["$max"].(bson.M)["cancelled"])
+}
+
+func TestRecordSetStatus_ShouldBeRunningWhileAnyActionHasNotFinished(t *testing.T) {
+
+	r := Record{Unfinished: 1, Terminal: &terminalStep{StepId: "build", State: stateSuccess}}
+
+	r.setStatus()
+
+	assert.Equal(t, executionRunning, r.Status)
+	assert.Empty(t, r.TerminalStepId)
+}
+
+func TestRecordSetStatus_ShouldFollowActionThatFinishedLast(t *testing.T) {
+
+	cases := map[string]string{
+		stateSuccess: executionSucceeded,
+		stateFatal:   executionFailed,
+		stateTimeout: executionTimedOut,
+	}
+	for state, status := range cases {
+		r := Record{Terminal: &terminalStep{StepId: "notify", State: state}}
+
+		r.setStatus()
+
+		assert.Equal(t, status, r.Status, state)
+		assert.Equal(t, "notify", r.TerminalStepId, state)
+	}
+}
+
+func TestRecordSetStatus_ShouldBeCancelledWhenAnyActionHasBeenCancelled(t *testing.T) {
+
+	r := Record{Cancelled: true, Unfinished: 1, Terminal: &terminalStep{StepId: "build", State: stateSuccess}}
+
+	r.setStatus()
+
+	assert.Equal(t, executionCancelled, r.Status)
+	assert.Empty(t, r.TerminalStepId)
+}
+
+func TestRecordSetStatus_ShouldBeQueuedWhileExecutionWaitsToBeReleased(t *testing.T) {
+
+	r := Record{Queued: 1, Unfinished: 1}
+
+	r.setStatus()
+
+	assert.Equal(t, executionQueued, r.Status)
+}
+
+func newRecordActionT(stepId string, created time.Time, state string, stateTime time.Time) Action {
+	return Action{
+		StepId: stepId,
+		State:  State{Value: state, Time: stateTime},
+		States: []State{{Value: stateNew, Time: created}, {Value: state, Time: stateTime}},
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"fmt"
+	"github.com/ExpediaGroup/flyte/template"
+	"github.com/rs/zerolog/log"
+	"strconv"
+)
+
+// Repeat lets a step be executed more than once in a flow execution, at most Max times (including the first one)
+// and until the Until criteria is met by the result of its latest action. Without Max the step repeats until the criteria is met.
+type Repeat struct {
+	Max   int    `bson:"max,omitempty"`
+	Until string `bson:"until,omitempty"`
+}
+
+// A step is repeated only once its latest action has finished, so its repetitions do not run at the same time.
+func (f Flow) canRepeat(step Step) bool {
+
+	if step.Repeat == nil {
+		return false
+	}
+	latest, ok := f.actions[step.Id]
+	if !ok || !latest.hasFinished() {
+		return false
+	}
+	if step.Repeat.Max > 0 && latest.Repetition+1 >= step.Repeat.Max {
+		return false
+	}
+	if step.Repeat.Until == "" {
+		return true
+	}
+
+	done, err := step.Repeat.isUntilMet(latest.Result, f.context)
+	if err != nil {
+		log.Err(err).Msgf("Error evaluating repeat for flow=%s step=%s", f.UUID, step.Id)
+		return false
+	}
+	return !done
+}
+
+func (r Repeat) isUntilMet(result Event, ctx map[string]string) (bool, error) {
+
+	until, err := template.Resolve(r.Until, templateContext(result, ctx))
+	if err != nil {
+		return false, fmt.Errorf("error resolving repeat until with event=%+v and ctx=%v: %v", result, ctx, err)
+	}
+	return strconv.ParseBool(until.(string))
+}
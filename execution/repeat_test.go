@@ -0,0 +1,109 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCanRepeat(t *testing.T) {
+
+	answered := Event{Name: "QuestionAnswered", Pack: Pack{Name: "Slack"}, Payload: map[string]interface{}{"answer": "again"}}
+	done := Event{Name: "QuestionAnswered", Pack: Pack{Name: "Slack"}, Payload: map[string]interface{}{"answer": "done"}}
+	until := "{{ Event.Payload.answer == 'done' }}"
+
+	cases := []struct {
+		name   string
+		repeat *Repeat
+		latest Action
+		want   bool
+	}{
+		{name: "no repeat", latest: Action{State: State{Value: stateSuccess}}},
+		{name: "not finished", repeat: &Repeat{Max: 3}, latest: Action{State: State{Value: statePending}}},
+		{name: "below max", repeat: &Repeat{Max: 3}, latest: Action{State: State{Value: stateFatal}, Repetition: 1}, want: true},
+		{name: "max reached", repeat: &Repeat{Max: 3}, latest: Action{State: State{Value: stateSuccess}, Repetition: 2}},
+		{name: "until not met", repeat: &Repeat{Until: until}, latest: Action{State: State{Value: stateSuccess}, Result: answered}, want: true},
+		{name: "until met", repeat: &Repeat{Max: 10, Until: until}, latest: Action{State: State{Value: stateSuccess}, Result: done}},
+		{name: "invalid until", repeat: &Repeat{Until: "{{ Event.Payload.answer }}"}, latest: Action{State: State{Value: stateSuccess}, Result: done}},
+	}
+	for _, c := range cases {
+		step := newStepT("ask", "QuestionAnswered", "Slack")
+		step.Repeat = c.repeat
+		flow := newFlowT(step)
+		flow.actions["ask"] = c.latest
+
+		assert.Equal(t, c.want, flow.canRepeat(step), c.name)
+	}
+}
+
+func TestFlowHandleEvent_ShouldRepeatStepWithNextRepetition(t *testing.T) {
+
+	//Given
+	defer resetStepExecutor()
+	rec := setupStepExecutorWithAction(nil)
+	defer resetActionRepo()
+	var added Action
+	actionRepo = mockActionRepo{add: func(a Action) error {
+		added = a
+		return nil
+	}}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	step := newStepT("ask", "QuestionAnswered", "Slack")
+	step.Repeat = &Repeat{Max: 3}
+	flow := newFlowT(step)
+	flow.actions["ask"] = Action{Id: "previous", State: State{Value: stateSuccess}, Repetition: 1}
+
+	//When
+	flow.HandleEvent(Event{Name: "QuestionAnswered", Pack: Pack{Name: "Slack"}})
+
+	//Then
+	require.Len(t, rec.calls, 1)
+	assert.Equal(t, 2, added.Repetition)
+	assert.Equal(t, added, flow.actions["ask"])
+}
+
+func TestFlowHandleEvent_ShouldNotRepeatStepWithoutRepeat(t *testing.T) {
+
+	defer resetStepExecutor()
+	rec := setupStepExecutorWithAction(nil)
+
+	flow := newFlowT(newStepT("ask", "QuestionAnswered", "Slack"))
+	flow.actions["ask"] = Action{State: State{Value: stateSuccess}}
+
+	flow.HandleEvent(Event{Name: "QuestionAnswered", Pack: Pack{Name: "Slack"}})
+
+	assert.Len(t, rec.calls, 0)
+}
+
+func TestFlowAddAction_ShouldKeepRepetitionOfReplayedAction(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{add: func(a Action) error { return nil }}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	flow := newFlowT(newStepT("ask", "QuestionAnswered", "Slack"))
+	flow.actions["ask"] = Action{Id: "failed", State: State{Value: stateFatal}, Repetition: 2}
+
+	require.NoError(t, flow.addAction("ask", Action{Id: "replay", ReplayOf: "failed"}))
+
+	assert.Equal(t, 2, flow.actions["ask"].Repetition)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ExpediaGroup/flyte/flytepath"
+	"github.com/ExpediaGroup/flyte/httputil"
+	"github.com/husobee/vestigo"
+	"github.com/rs/zerolog/log"
+	"net/http"
+)
+
+var StepNotFoundErr = errors.New("step not found")
+var StepNotFailedErr = errors.New("step has not failed")
+var FlowCancelledErr = errors.New("flow has been cancelled")
+
+func RerunStep(w http.ResponseWriter, r *http.Request) {
+
+	correlationId := vestigo.Param(r, "correlationId")
+	stepId := vestigo.Param(r, "stepId")
+
+	action, err := rerunStep(correlationId, stepId)
+	if err != nil {
+		switch err {
+		case FlowNotFoundErr, StepNotFoundErr:
+			log.Info().Msgf("Flow correlationId=%s stepId=%s not found", correlationId, stepId)
+			w.WriteHeader(http.StatusNotFound)
+		case StepNotFailedErr, FlowCancelledErr:
+			log.Info().Msgf("Flow correlationId=%s stepId=%s cannot be re-run: %s", correlationId, stepId, err)
+			w.WriteHeader(http.StatusConflict)
+		default:
+			log.Err(err).Msgf("Error re-running flow correlationId=%s stepId=%s", correlationId, stepId)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Info().
+		Str("ActionId", action.Id).
+		Str("ReplayOf", action.ReplayOf).
+		Str("CorrelationId", correlationId).
+		Str("FlowName", action.FlowName).
+		Str("StepId", stepId).
+		Msg("Step re-run")
+
+	w.Header().Set("Location", httputil.UriBuilder(r).Path(flytepath.AuditFlowPath, correlationId).Build())
+	w.WriteHeader(http.StatusCreated)
+}
+
+var rerunStep = rerunStepFn
+
+// re-executes a failed step of a flow execution with the event that originally triggered it,
+// the new action replaces the failed one in the flow and references it in ReplayOf
+func rerunStepFn(correlationId, stepId string) (*Action, error) {
+
+	actions, err := actionRepo.FindCorrelated(correlationId)
+	if err != nil {
+		return nil, err
+	}
+	if len(actions) == 0 {
+		return nil, FlowNotFoundErr
+	}
+
+	// actions are sorted by creation, so the last one is the latest attempt of the step
+	var failed *Action
+	for i := range actions {
+		if actions[i].StepId == stepId && actions[i].Iteration == nil {
+			failed = &actions[i]
+		}
+	}
+	if failed == nil {
+		return nil, StepNotFoundErr
+	}
+	if failed.State.Value != stateFatal && failed.State.Value != stateTimeout {
+		return nil, StepNotFailedErr
+	}
+
+	original, err := actionRepo.Get(failed.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	flow, err := flowRepo.GetByAction(*original)
+	if err != nil {
+		return nil, err
+	}
+	if flow.isCancelled() {
+		return nil, FlowCancelledErr
+	}
+
+	step, ok := flow.step(stepId)
+	if !ok {
+		return nil, StepNotFoundErr
+	}
+
+	action, err := step.Execute(original.Trigger, flow.context)
+	if err != nil {
+		return nil, err
+	}
+	if action == nil {
+		return nil, fmt.Errorf("step=%s does not match its original trigger event anymore", stepId)
+	}
+
+	action.ReplayOf = original.Id
+	if err := flow.addAction(stepId, *action); err != nil {
+		return nil, err
+	}
+	replay := flow.actions[stepId]
+	return &replay, nil
+}
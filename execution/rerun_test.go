@@ -0,0 +1,209 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"errors"
+	"github.com/ExpediaGroup/flyte/httputil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRerunStep_ShouldExecuteFailedStepWithOriginalTriggerAndContext(t *testing.T) {
+
+	//Given
+	trigger := Event{Name: "eventOK", Pack: Pack{Name: "packOK"}}
+	ctx := map[string]string{"channel": "123"}
+	original := &Action{Id: "failed", StepId: "stepB", FlowUUID: "uuid", CorrelationId: "abc",
+		State: State{Value: stateFatal}, Trigger: trigger, Context: ctx}
+
+	defer resetActionRepo()
+	var added Action
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			require.Equal(t, "abc", correlationId)
+			return []Action{
+				{Id: "a", StepId: "stepA", State: State{Value: stateSuccess}},
+				{Id: "failed", StepId: "stepB", State: State{Value: stateFatal}},
+			}, nil
+		},
+		get: func(actionId string) (*Action, error) {
+			require.Equal(t, "failed", actionId)
+			return original, nil
+		},
+		add: func(a Action) error {
+			added = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	defer resetFlowRepo()
+	stepB := newStepT("stepB", "eventOK", "packOK")
+	flowRepo = mockFlowRepo{
+		getByAction: func(a Action) (*Flow, error) {
+			require.Equal(t, *original, a)
+			flow := newFlowT(newStepT("stepA", "eventOK", "packOK"), stepB)
+			flow.UUID = "uuid"
+			flow.Name = "flowA"
+			flow.correlationId = "abc"
+			flow.context = a.Context
+			return &flow, nil
+		},
+	}
+
+	defer resetStepExecutor()
+	rec := setupStepExecutor(&Action{Id: "replay"}, nil)
+
+	//When
+	replay, err := rerunStepFn("abc", "stepB")
+
+	//Then
+	require.NoError(t, err)
+	require.Len(t, rec.calls, 1)
+	assert.Equal(t, stepB, rec.calls[0].step)
+	assert.Equal(t, trigger, rec.calls[0].event)
+	assert.Equal(t, ctx, rec.calls[0].ctx)
+
+	assert.Equal(t, "replay", replay.Id)
+	assert.Equal(t, "failed", replay.ReplayOf)
+	assert.Equal(t, "abc", replay.CorrelationId)
+	assert.Equal(t, "flowA", replay.FlowName)
+	assert.Equal(t, "stepB", replay.StepId)
+	assert.Equal(t, *replay, added)
+}
+
+func TestRerunStep_ShouldReturnFlowNotFoundErrWhenThereAreNoActions(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			return nil, nil
+		},
+	}
+
+	_, err := rerunStepFn("abc", "stepB")
+
+	assert.Equal(t, FlowNotFoundErr, err)
+}
+
+func TestRerunStep_ShouldReturnStepNotFoundErrWhenStepHasNoAction(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			return []Action{{Id: "a", StepId: "stepA", State: State{Value: stateFatal}}}, nil
+		},
+	}
+
+	_, err := rerunStepFn("abc", "stepB")
+
+	assert.Equal(t, StepNotFoundErr, err)
+}
+
+func TestRerunStep_ShouldReturnStepNotFailedErrWhenLatestAttemptHasNotFailed(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			return []Action{
+				{Id: "a", StepId: "stepB", State: State{Value: stateFatal}},
+				{Id: "b", StepId: "stepB", State: State{Value: statePending}, ReplayOf: "a"},
+			}, nil
+		},
+	}
+
+	_, err := rerunStepFn("abc", "stepB")
+
+	assert.Equal(t, StepNotFailedErr, err)
+}
+
+func TestRerunStep_ShouldReturnFlowCancelledErrWhenFlowHasBeenCancelled(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findCorrelated: func(correlationId string) ([]Action, error) {
+			return []Action{{Id: "a", StepId: "stepB", State: State{Value: stateTimeout}}}, nil
+		},
+		get: func(actionId string) (*Action, error) {
+			return &Action{Id: actionId}, nil
+		},
+	}
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		getByAction: func(a Action) (*Flow, error) {
+			flow := newFlowT(newStepT("stepB", "eventOK", "packOK"))
+			flow.actions["stepC"] = Action{State: State{Value: stateCancelled}, Cancellation: &Cancellation{By: "jdoe"}}
+			return &flow, nil
+		},
+	}
+
+	_, err := rerunStepFn("abc", "stepB")
+
+	assert.Equal(t, FlowCancelledErr, err)
+}
+
+func TestRerunStepHandler_ShouldReturn201WithLocationOfFlowExecution(t *testing.T) {
+
+	defer resetRerunStep()
+	rerunStep = func(correlationId, stepId string) (*Action, error) {
+		require.Equal(t, "abc", correlationId)
+		require.Equal(t, "stepB", stepId)
+		return &Action{Id: "replay", ReplayOf: "failed"}, nil
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/audit/flows/abc/steps/stepB/rerun?:correlationId=abc&:stepId=stepB", nil)
+	httputil.SetProtocolAndHostIn(req)
+	w := httptest.NewRecorder()
+	RerunStep(w, req)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	location, err := resp.Location()
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/v1/audit/flows/abc", location.String())
+}
+
+func TestRerunStepHandler_ShouldMapErrorsToStatusCodes(t *testing.T) {
+
+	defer resetRerunStep()
+	cases := map[error]int{
+		FlowNotFoundErr:      http.StatusNotFound,
+		StepNotFoundErr:      http.StatusNotFound,
+		StepNotFailedErr:     http.StatusConflict,
+		FlowCancelledErr:     http.StatusConflict,
+		errors.New("db err"): http.StatusInternalServerError,
+	}
+	for err, status := range cases {
+		rerunStep = func(correlationId, stepId string) (*Action, error) {
+			return nil, err
+		}
+
+		w := httptest.NewRecorder()
+		RerunStep(w, httptest.NewRequest(http.MethodPost,
+			"/v1/audit/flows/abc/steps/stepB/rerun?:correlationId=abc&:stepId=stepB", nil))
+
+		assert.Equal(t, status, w.Result().StatusCode, err.Error())
+	}
+}
+
+func resetRerunStep() { rerunStep = rerunStepFn }
@@ -0,0 +1,85 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"fmt"
+	"github.com/ExpediaGroup/flyte/collections"
+	"time"
+)
+
+// Retry policy of a command. MaxAttempts includes the first attempt, so MaxAttempts of 3 means
+// the action is retried at most twice.
+type Retry struct {
+	MaxAttempts int      `bson:"maxAttempts,omitempty"`
+	Backoff     string   `bson:"backoff,omitempty"`
+	Delay       string   `bson:"delay,omitempty"`
+	On          []string `bson:"on,omitempty"`
+}
+
+const (
+	backoffFixed       = "fixed"
+	backoffExponential = "exponential"
+)
+
+// longest delay before a retry, exponential backoff stops doubling once it reaches it
+const maxRetryDelay = time.Hour
+
+func (r Retry) validate() error {
+	switch r.Backoff {
+	case "", backoffFixed, backoffExponential:
+	default:
+		return fmt.Errorf("invalid command retry backoff=%q", r.Backoff)
+	}
+	if r.Delay != "" {
+		if _, err := time.ParseDuration(r.Delay); err != nil {
+			return fmt.Errorf("invalid command retry delay=%q: %v", r.Delay, err)
+		}
+	}
+	return nil
+}
+
+// an action is retried when its result is one of the retry-on events (FATAL by default)
+// and it has not used up all of its attempts yet
+func (r Retry) shouldRetry(result Event, attempt int) bool {
+	if attempt >= r.MaxAttempts {
+		return false
+	}
+	if len(r.On) == 0 {
+		return result.isFatal()
+	}
+	return collections.Contains(r.On, result.Name)
+}
+
+// delay before the given retry (1 for the first retry), doubled on each retry for exponential backoff up to
+// maxRetryDelay
+func (r Retry) delay(retry int) time.Duration {
+	d, err := time.ParseDuration(r.Delay)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	if r.Backoff != backoffExponential {
+		return d
+	}
+	for i := 1; i < retry && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return d
+}
@@ -0,0 +1,83 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetryShouldRetry_ShouldRetryFatalResultByDefault(t *testing.T) {
+
+	r := Retry{MaxAttempts: 2}
+
+	assert.True(t, r.shouldRetry(Event{Name: fatalEventName}, 1))
+	assert.False(t, r.shouldRetry(Event{Name: "Deployed"}, 1))
+}
+
+func TestRetryShouldRetry_ShouldRetryOnlyOnConfiguredEvents(t *testing.T) {
+
+	r := Retry{MaxAttempts: 2, On: []string{"DeployFailed", timeoutEventName}}
+
+	assert.True(t, r.shouldRetry(Event{Name: "DeployFailed"}, 1))
+	assert.True(t, r.shouldRetry(Event{Name: timeoutEventName}, 1))
+	assert.False(t, r.shouldRetry(Event{Name: fatalEventName}, 1))
+}
+
+func TestRetryShouldRetry_ShouldNotRetryWhenAttemptsAreExhausted(t *testing.T) {
+
+	assert.False(t, Retry{MaxAttempts: 2}.shouldRetry(Event{Name: fatalEventName}, 2))
+	assert.False(t, Retry{}.shouldRetry(Event{Name: fatalEventName}, 1))
+}
+
+func TestRetryDelay_ShouldBeFixedByDefault(t *testing.T) {
+
+	r := Retry{Delay: "10s"}
+
+	assert.Equal(t, 10*time.Second, r.delay(1))
+	assert.Equal(t, 10*time.Second, r.delay(3))
+}
+
+func TestRetryDelay_ShouldDoubleForExponentialBackoff(t *testing.T) {
+
+	r := Retry{Delay: "10s", Backoff: backoffExponential}
+
+	assert.Equal(t, 10*time.Second, r.delay(1))
+	assert.Equal(t, 20*time.Second, r.delay(2))
+	assert.Equal(t, 40*time.Second, r.delay(3))
+}
+
+func TestRetryDelay_ShouldNotExceedMaxDelayForExponentialBackoff(t *testing.T) {
+
+	r := Retry{Delay: "10m", Backoff: backoffExponential}
+
+	assert.Equal(t, 40*time.Minute, r.delay(3))
+	assert.Equal(t, maxRetryDelay, r.delay(4))
+	assert.Equal(t, maxRetryDelay, r.delay(64))
+	assert.Equal(t, maxRetryDelay, r.delay(1000))
+}
+
+func TestRetryDelay_ShouldBeZeroWithoutDelay(t *testing.T) {
+
+	assert.Equal(t, time.Duration(0), Retry{Backoff: backoffExponential}.delay(2))
+}
+
+func TestRetryValidate_ShouldReturnErrorForInvalidBackoff(t *testing.T) {
+
+	assert.EqualError(t, Retry{Backoff: "linear"}.validate(), `invalid command retry backoff="linear"`)
+}
@@ -0,0 +1,189 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"fmt"
+	"github.com/adhocore/gronx"
+	"github.com/jasonlvhit/gocron"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+const scheduledEventName = "Scheduled"
+
+// longest period of missed ticks that are fired when the scheduler catches up, e.g. after a leader change
+const maxScheduleCatchUp = 10 * time.Minute
+
+type Schedule struct {
+	Cron     string `bson:"cron"`
+	Timezone string `bson:"timezone,omitempty"`
+}
+
+// returns the minutes in (from, to] matching the cron expression in the schedule's timezone
+func (s Schedule) ticks(from, to time.Time) ([]time.Time, error) {
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule timezone=%q: %v", s.Timezone, err)
+	}
+
+	var ticks []time.Time
+	gron := gronx.New()
+	for t := from.Truncate(time.Minute).Add(time.Minute); !t.After(to); t = t.Add(time.Minute) {
+		due, err := gron.IsDue(s.Cron, t.In(loc))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule cron=%q: %v", s.Cron, err)
+		}
+		if due {
+			ticks = append(ticks, t)
+		}
+	}
+	return ticks, nil
+}
+
+func (s Schedule) event(tick time.Time) Event {
+	now := currentTime().UTC()
+	return Event{
+		Name: scheduledEventName,
+		Pack: Pack{Name: builtinPackName},
+		Payload: map[string]interface{}{
+			"time":     tick.UTC().Format(time.RFC3339),
+			"cron":     s.Cron,
+			"timezone": s.Timezone,
+		},
+		CreatedAt:  now,
+		ReceivedAt: now,
+	}
+}
+
+// identifies this instance in the leader election
+var instanceId = bson.NewObjectId().Hex()
+
+var scheduleLeaderLease time.Duration
+
+// time up to which the ticks have been fired by this instance, zero when it has not been the leader
+var lastScheduleCheck time.Time
+
+// Fires the ticks of scheduled flows, the check runs every interval but only on the instance elected as the leader.
+// Every tick is also claimed in mongo before it is fired, so it fires once even when the leader changes.
+func ScheduleFlowTriggers(checkIntervalInSeconds int) (*gocron.Scheduler, chan bool) {
+	scheduleLeaderLease = 3 * time.Duration(checkIntervalInSeconds) * time.Second
+	s := gocron.NewScheduler()
+	s.Every(uint64(checkIntervalInSeconds)).Seconds().Do(triggerScheduledFlows)
+	sc := s.Start()
+	return s, sc
+}
+
+func triggerScheduledFlows() {
+
+	now := currentTime()
+	leader, err := scheduleRepo.AcquireLeadership(instanceId, now, scheduleLeaderLease)
+	if err != nil {
+		log.Err(err).Msg("Error acquiring scheduler leadership")
+		return
+	}
+	if !leader {
+		lastScheduleCheck = time.Time{}
+		return
+	}
+
+	newlyElected := lastScheduleCheck.IsZero()
+	from := lastScheduleCheck
+	if newlyElected || now.Sub(from) > maxScheduleCatchUp {
+		from = now.Add(-maxScheduleCatchUp)
+	}
+
+	flows, err := flowRepo.FindScheduled()
+	if err != nil {
+		log.Err(err).Msg("Error finding scheduled flows")
+		return
+	}
+	for _, f := range flows {
+		flowFrom := from
+		if newlyElected {
+			if flowFrom, err = scheduleCatchUpFrom(f.Name, from, now); err != nil {
+				log.Err(err).Msgf("Error finding last tick of flow=%s", f.Name)
+				continue
+			}
+		}
+		triggerScheduledFlow(f, flowFrom, now)
+	}
+	lastScheduleCheck = now
+}
+
+// A newly elected leader does not know up to when the previous one has fired the ticks, e.g. it may have crashed and
+// missed the ticks due while its lease was running. It fires the ticks since the last claimed one, a flow that has
+// never been fired starts from the current minute.
+func scheduleCatchUpFrom(flowName string, from, now time.Time) (time.Time, error) {
+
+	lastTick, err := scheduleRepo.LastTick(flowName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if lastTick.IsZero() {
+		return now.Truncate(time.Minute).Add(-time.Nanosecond), nil
+	}
+	if lastTick.After(from) {
+		return lastTick, nil
+	}
+	return from, nil
+}
+
+func triggerScheduledFlow(f Flow, from, to time.Time) {
+
+	ticks, err := f.Schedule.ticks(from, to)
+	if err != nil {
+		log.Err(err).Msgf("Error evaluating schedule of flow=%s", f.Name)
+		return
+	}
+
+	for _, tick := range ticks {
+		claimed, err := scheduleRepo.ClaimTick(f.Name, tick)
+		if err != nil {
+			log.Err(err).Msgf("Error claiming tick=%s of flow=%s", tick, f.Name)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		execution := f
+		execution.correlationId = bson.NewObjectId().Hex()
+		execution.context = map[string]string{}
+		execution.actions = map[string]Action{}
+		execution.HandleEvent(f.Schedule.event(tick))
+
+		log.Info().
+			Str("FlowName", f.Name).
+			Str("CorrelationId", execution.correlationId).
+			Time("Tick", tick).
+			Msg("Scheduled flow triggered")
+	}
+}
+
+var scheduleRepo ScheduleRepository = scheduleMgoRepo{}
+
+type ScheduleRepository interface {
+	// extends the leadership of the instance, or takes it over when the current leader's lease has expired
+	AcquireLeadership(instanceId string, now time.Time, lease time.Duration) (bool, error)
+	// records the tick of the flow, returns false when the tick or a later one has already been claimed
+	ClaimTick(flowName string, tick time.Time) (bool, error)
+	// returns the last tick claimed for the flow, zero when none has been claimed
+	LastTick(flowName string) (time.Time, error)
+}
@@ -0,0 +1,251 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestScheduleTicks_ShouldEvaluateCronInTimezone(t *testing.T) {
+
+	s := Schedule{Cron: "0 9 * * *", Timezone: "Europe/London"}
+	from := time.Date(2018, 7, 1, 7, 0, 0, 0, time.UTC)
+
+	ticks, err := s.ticks(from, from.Add(2*time.Hour))
+
+	require.NoError(t, err)
+	// 9:00 in London is 8:00 UTC during summer time
+	assert.Equal(t, []time.Time{time.Date(2018, 7, 1, 8, 0, 0, 0, time.UTC)}, ticks)
+}
+
+func TestScheduleTicks_ShouldExcludeFromAndIncludeTo(t *testing.T) {
+
+	s := Schedule{Cron: "* * * * *"}
+	from := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ticks, err := s.ticks(from, from.Add(2*time.Minute))
+
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{from.Add(time.Minute), from.Add(2 * time.Minute)}, ticks)
+}
+
+func TestTriggerScheduledFlows_ShouldStartExecutionForEachClaimedTick(t *testing.T) {
+
+	//Given
+	defer resetSchedule()
+	lastScheduleCheck = time.Date(2018, 1, 1, 12, 0, 30, 0, time.UTC)
+	now := time.Date(2018, 1, 1, 12, 3, 30, 0, time.UTC)
+	defer resetCurrentTime()
+	currentTime = func() time.Time { return now }
+
+	var claimed []time.Time
+	scheduleRepo = mockScheduleRepo{
+		acquireLeadership: func(id string, t time.Time, lease time.Duration) (bool, error) {
+			return true, nil
+		},
+		claimTick: func(flowName string, tick time.Time) (bool, error) {
+			claimed = append(claimed, tick)
+			// another instance has already fired the last tick
+			return tick.Minute() != 3, nil
+		},
+	}
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		findScheduled: func() ([]Flow, error) {
+			f := newFlowT(newStepT("start", scheduledEventName, builtinPackName))
+			f.Name = "nightly"
+			f.Schedule = &Schedule{Cron: "* * * * *"}
+			return []Flow{f}, nil
+		},
+	}
+
+	defer resetStepExecutor()
+	rec := setupStepExecutorWithAction(nil)
+	defer resetActionRepo()
+	var added []Action
+	actionRepo = mockActionRepo{add: func(a Action) error {
+		added = append(added, a)
+		return nil
+	}}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	//When
+	triggerScheduledFlows()
+
+	//Then
+	assert.Len(t, claimed, 3)
+	require.Len(t, rec.calls, 2)
+	assert.Equal(t, scheduledEventName, rec.calls[0].event.Name)
+	assert.Equal(t, builtinPackName, rec.calls[0].event.Pack.Name)
+	assert.Equal(t, "2018-01-01T12:01:00Z", rec.calls[0].event.Payload.(map[string]interface{})["time"])
+	assert.Equal(t, "2018-01-01T12:02:00Z", rec.calls[1].event.Payload.(map[string]interface{})["time"])
+	require.Len(t, added, 2)
+	assert.NotEqual(t, added[0].CorrelationId, added[1].CorrelationId)
+	assert.Equal(t, now, lastScheduleCheck)
+}
+
+func TestTriggerScheduledFlows_ShouldFireTicksSinceLastClaimedTickWhenNewlyElected(t *testing.T) {
+
+	defer resetSchedule()
+	now := time.Date(2018, 1, 1, 12, 3, 30, 0, time.UTC)
+	defer resetCurrentTime()
+	currentTime = func() time.Time { return now }
+
+	var claimed []time.Time
+	scheduleRepo = mockScheduleRepo{
+		acquireLeadership: func(id string, t time.Time, lease time.Duration) (bool, error) {
+			return true, nil
+		},
+		claimTick: func(flowName string, tick time.Time) (bool, error) {
+			claimed = append(claimed, tick)
+			return false, nil
+		},
+		lastTick: func(flowName string) (time.Time, error) {
+			// the previous leader crashed after firing this tick
+			return time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC), nil
+		},
+	}
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		findScheduled: func() ([]Flow, error) {
+			return []Flow{{Name: "nightly", Schedule: &Schedule{Cron: "* * * * *"}}}, nil
+		},
+	}
+
+	triggerScheduledFlows()
+
+	assert.Equal(t, []time.Time{
+		time.Date(2018, 1, 1, 12, 1, 0, 0, time.UTC),
+		time.Date(2018, 1, 1, 12, 2, 0, 0, time.UTC),
+		time.Date(2018, 1, 1, 12, 3, 0, 0, time.UTC),
+	}, claimed)
+}
+
+func TestTriggerScheduledFlows_ShouldLimitCatchUpWhenNewlyElected(t *testing.T) {
+
+	defer resetSchedule()
+	now := time.Date(2018, 1, 1, 12, 3, 30, 0, time.UTC)
+	defer resetCurrentTime()
+	currentTime = func() time.Time { return now }
+
+	var claimed []time.Time
+	scheduleRepo = mockScheduleRepo{
+		acquireLeadership: func(id string, t time.Time, lease time.Duration) (bool, error) {
+			return true, nil
+		},
+		claimTick: func(flowName string, tick time.Time) (bool, error) {
+			claimed = append(claimed, tick)
+			return false, nil
+		},
+		lastTick: func(flowName string) (time.Time, error) {
+			return now.Add(-time.Hour), nil
+		},
+	}
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		findScheduled: func() ([]Flow, error) {
+			return []Flow{{Name: "nightly", Schedule: &Schedule{Cron: "* * * * *"}}}, nil
+		},
+	}
+
+	triggerScheduledFlows()
+
+	require.Len(t, claimed, 10)
+	assert.Equal(t, time.Date(2018, 1, 1, 11, 54, 0, 0, time.UTC), claimed[0])
+}
+
+func TestTriggerScheduledFlows_ShouldOnlyFireCurrentMinuteOfFlowNeverFiredWhenNewlyElected(t *testing.T) {
+
+	defer resetSchedule()
+	now := time.Date(2018, 1, 1, 12, 3, 30, 0, time.UTC)
+	defer resetCurrentTime()
+	currentTime = func() time.Time { return now }
+
+	var claimed []time.Time
+	scheduleRepo = mockScheduleRepo{
+		acquireLeadership: func(id string, t time.Time, lease time.Duration) (bool, error) {
+			return true, nil
+		},
+		claimTick: func(flowName string, tick time.Time) (bool, error) {
+			claimed = append(claimed, tick)
+			return false, nil
+		},
+		lastTick: func(flowName string) (time.Time, error) {
+			return time.Time{}, nil
+		},
+	}
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		findScheduled: func() ([]Flow, error) {
+			return []Flow{{Name: "nightly", Schedule: &Schedule{Cron: "* * * * *"}}}, nil
+		},
+	}
+
+	triggerScheduledFlows()
+
+	assert.Equal(t, []time.Time{time.Date(2018, 1, 1, 12, 3, 0, 0, time.UTC)}, claimed)
+}
+
+func TestTriggerScheduledFlows_ShouldDoNothingWhenNotLeader(t *testing.T) {
+
+	defer resetSchedule()
+	lastScheduleCheck = time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	scheduleRepo = mockScheduleRepo{
+		acquireLeadership: func(id string, t time.Time, lease time.Duration) (bool, error) {
+			return false, nil
+		},
+	}
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		findScheduled: func() ([]Flow, error) {
+			t.Fatal("Should not get here")
+			return nil, nil
+		},
+	}
+
+	triggerScheduledFlows()
+
+	assert.True(t, lastScheduleCheck.IsZero())
+}
+
+type mockScheduleRepo struct {
+	acquireLeadership func(instanceId string, now time.Time, lease time.Duration) (bool, error)
+	claimTick         func(flowName string, tick time.Time) (bool, error)
+	lastTick          func(flowName string) (time.Time, error)
+}
+
+func (r mockScheduleRepo) AcquireLeadership(instanceId string, now time.Time, lease time.Duration) (bool, error) {
+	return r.acquireLeadership(instanceId, now, lease)
+}
+
+func (r mockScheduleRepo) ClaimTick(flowName string, tick time.Time) (bool, error) {
+	return r.claimTick(flowName, tick)
+}
+
+func (r mockScheduleRepo) LastTick(flowName string) (time.Time, error) {
+	return r.lastTick(flowName)
+}
+
+func resetSchedule() {
+	scheduleRepo = scheduleMgoRepo{}
+	lastScheduleCheck = time.Time{}
+}
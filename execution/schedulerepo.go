@@ -0,0 +1,75 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/ExpediaGroup/flyte/mongo"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+const schedulerLeaderId = "scheduler"
+
+type scheduleMgoRepo struct{}
+
+func (scheduleMgoRepo) AcquireLeadership(instanceId string, now time.Time, lease time.Duration) (bool, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	// when another instance holds an unexpired lease the query does not match
+	// and the upsert fails on the duplicate id
+	_, err := s.DB(mongo.DbName).C(mongo.LeaderCollectionId).Upsert(
+		bson.M{"_id": schedulerLeaderId, "$or": []bson.M{{"holder": instanceId}, {"expiresAt": bson.M{"$lt": now}}}},
+		bson.M{"$set": bson.M{"holder": instanceId, "expiresAt": now.Add(lease)}},
+	)
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (scheduleMgoRepo) ClaimTick(flowName string, tick time.Time) (bool, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	_, err := s.DB(mongo.DbName).C(mongo.ScheduleCollectionId).Upsert(
+		bson.M{"_id": flowName, "tick": bson.M{"$lt": tick}},
+		bson.M{"$set": bson.M{"tick": tick}},
+	)
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (scheduleMgoRepo) LastTick(flowName string) (time.Time, error) {
+
+	s := mongo.GetSession()
+	defer s.Close()
+
+	var schedule struct {
+		Tick time.Time `bson:"tick"`
+	}
+	err := s.DB(mongo.DbName).C(mongo.ScheduleCollectionId).FindId(flowName).One(&schedule)
+	if err == mgo.ErrNotFound {
+		return time.Time{}, nil
+	}
+	return schedule.Tick, err
+}
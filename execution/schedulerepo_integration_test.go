@@ -0,0 +1,88 @@
+// +build integration
+
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var scheduleRepoT = scheduleMgoRepo{}
+
+func TestAcquireLeadership_ShouldOnlyBeGrantedToOneInstanceUntilLeaseExpires(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	leader, err := scheduleRepoT.AcquireLeadership("instanceA", now, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, leader)
+
+	leader, err = scheduleRepoT.AcquireLeadership("instanceB", now.Add(30*time.Second), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, leader)
+
+	leader, err = scheduleRepoT.AcquireLeadership("instanceA", now.Add(30*time.Second), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, leader)
+
+	leader, err = scheduleRepoT.AcquireLeadership("instanceB", now.Add(2*time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, leader)
+}
+
+func TestClaimTick_ShouldClaimEachTickOnce(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	tick := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	claimed, err := scheduleRepoT.ClaimTick("flowA", tick)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = scheduleRepoT.ClaimTick("flowA", tick)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	claimed, err = scheduleRepoT.ClaimTick("flowB", tick)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = scheduleRepoT.ClaimTick("flowA", tick.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestLastTick_ShouldReturnLastClaimedTickOfFlow(t *testing.T) {
+
+	mongoT.DropDatabase(t)
+	tick := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	_, err := scheduleRepoT.ClaimTick("flowA", tick)
+	require.NoError(t, err)
+
+	last, err := scheduleRepoT.LastTick("flowA")
+	require.NoError(t, err)
+	assert.True(t, tick.Equal(last))
+
+	last, err = scheduleRepoT.LastTick("flowB")
+	require.NoError(t, err)
+	assert.True(t, last.IsZero())
+}
@@ -0,0 +1,184 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	encodingjson "encoding/json"
+	"github.com/ExpediaGroup/flyte/httputil"
+	"github.com/ExpediaGroup/flyte/json"
+	"github.com/husobee/vestigo"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"time"
+)
+
+type simulationRequest struct {
+	Event Event `json:"event"`
+	// context of the flow execution, as recorded on the action that produced the event
+	Context map[string]string `json:"context"`
+	// states of the steps that have already been executed, keyed by step id
+	StepStates map[string]string `json:"stepStates"`
+	// result events of the steps that have already been executed, keyed by step id
+	StepResults map[string]Event `json:"stepResults"`
+}
+
+type simulationResponse struct {
+	Flow  string           `json:"flow"`
+	Steps []stepSimulation `json:"steps"`
+}
+
+type stepSimulation struct {
+	StepId      string            `json:"stepId"`
+	Executed    bool              `json:"executed"`
+	Reason      string            `json:"reason,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Context     map[string]string `json:"context,omitempty"`
+	CriteriaMet *bool             `json:"criteriaMet,omitempty"`
+	Command     *simulatedCommand `json:"command,omitempty"`
+	// the commands a forEach step would send, one for each item of its list
+	Iterations []simulatedIteration `json:"iterations,omitempty"`
+}
+
+type simulatedCommand struct {
+	Name       string            `json:"name"`
+	PackName   string            `json:"packName"`
+	PackLabels map[string]string `json:"packLabels,omitempty"`
+	Input      json.Json         `json:"input,omitempty"`
+	Priority   int               `json:"priority,omitempty"`
+}
+
+type simulatedIteration struct {
+	Index   int              `json:"index"`
+	Item    json.Json        `json:"item,omitempty"`
+	Command simulatedCommand `json:"command"`
+}
+
+// Shows what the flow would do with the event, nothing is stored in the action or audit collections.
+func SimulateFlow(w http.ResponseWriter, r *http.Request) {
+
+	flowName := vestigo.Param(r, "flowName")
+
+	defer r.Body.Close()
+	req := simulationRequest{}
+	if err := encodingjson.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Err(err).Msgf("Cannot read simulation request for flow=%s", flowName)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Event.Name == "" || req.Event.Pack.Name == "" {
+		log.Info().Msgf("Simulation request for flow=%s has no event name or pack name", flowName)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	flow, err := flowRepo.Get(flowName)
+	if err != nil {
+		switch err {
+		case FlowNotFoundErr:
+			log.Info().Msgf("Flow=%s not found", flowName)
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			log.Err(err).Msgf("Error getting flow=%s", flowName)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	event := req.Event
+	event.ReceivedAt = time.Now().UTC()
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = event.ReceivedAt
+	}
+
+	flow.context = req.Context
+	if flow.context == nil {
+		flow.context = map[string]string{}
+	}
+	flow.actions = req.executedSteps()
+
+	httputil.WriteResponse(w, r, simulationResponse{Flow: flow.Name, Steps: flow.simulate(event)})
+}
+
+// The actions of the executed steps. A step with a result but no state has succeeded, or failed with a FATAL result.
+func (req simulationRequest) executedSteps() map[string]Action {
+	actions := map[string]Action{}
+	for stepId, result := range req.StepResults {
+		state := stateSuccess
+		if result.isFatal() {
+			state = stateFatal
+		}
+		actions[stepId] = Action{StepId: stepId, State: State{Value: state}, Result: result}
+	}
+	for stepId, state := range req.StepStates {
+		a := actions[stepId]
+		a.StepId = stepId
+		a.State = State{Value: state}
+		actions[stepId] = a
+	}
+	return actions
+}
+
+func (f Flow) simulate(e Event) []stepSimulation {
+	simulations := []stepSimulation{}
+	for _, step := range f.Steps {
+		simulations = append(simulations, f.simulateStep(step, e))
+	}
+	return simulations
+}
+
+// runs the same checks as flowEventHandlerFn and executeStep, recording where the step stops
+func (f Flow) simulateStep(s Step, e Event) stepSimulation {
+
+	sim := stepSimulation{StepId: s.Id}
+	if sim.Reason = f.notCandidateReason(s, e); sim.Reason != "" {
+		return sim
+	}
+
+	a, trace, err := s.execute(e, f.context)
+	sim.Context = trace.context
+	sim.CriteriaMet = trace.criteriaMet
+	if err != nil {
+		sim.Error = err.Error()
+		return sim
+	}
+	if a == nil {
+		sim.Reason = trace.reason
+		return sim
+	}
+
+	sim.Executed = true
+	command := newSimulatedCommand(*a)
+	sim.Command = &command
+	for _, it := range a.iterations {
+		sim.Iterations = append(sim.Iterations, simulatedIteration{
+			Index:   it.Iteration.Index,
+			Item:    it.Iteration.Item,
+			Command: newSimulatedCommand(it),
+		})
+	}
+	return sim
+}
+
+func newSimulatedCommand(a Action) simulatedCommand {
+	return simulatedCommand{
+		Name:       a.Name,
+		PackName:   a.PackName,
+		PackLabels: a.PackLabels,
+		Input:      a.Input,
+		Priority:   a.Priority,
+	}
+}
@@ -0,0 +1,272 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	encodingjson "encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var simulatedFlow = Flow{
+	Name: "deploy",
+	Steps: []Step{
+		{
+			Id:       "announce",
+			Event:    EventDef{Name: "MessageReceived", PackName: "Slack"},
+			Context:  map[string]string{"channel": "{{ Event.Payload.channelId }}"},
+			Criteria: "{{ Event.Payload.message == 'deploy' }}",
+			Command: Command{
+				Name:     "SendMessage",
+				PackName: "Slack",
+				Input:    map[string]interface{}{"channelId": "{{ Context.channel }}", "message": "deploying {{ Context.app }}"},
+			},
+		},
+		{
+			Id:       "ignored",
+			Event:    EventDef{Name: "MessageReceived", PackName: "Slack"},
+			Criteria: "{{ Event.Payload.message == 'ignore' }}",
+			Command:  Command{Name: "SendMessage", PackName: "Slack"},
+		},
+		{
+			Id:        "deploy",
+			DependsOn: []string{"announce"},
+			Event:     EventDef{Name: "MessageSent", PackName: "Slack"},
+			Command:   Command{Name: "Deploy", PackName: "Jenkins"},
+		},
+		{
+			Id:      "labelled",
+			Event:   EventDef{Name: "MessageReceived", PackName: "Slack", PackLabels: map[string]string{"env": "prod"}},
+			Command: Command{Name: "SendMessage", PackName: "Slack"},
+		},
+		{
+			Id:       "broken",
+			Event:    EventDef{Name: "MessageReceived", PackName: "Slack"},
+			Criteria: "{{invalidTemplate",
+			Command:  Command{Name: "SendMessage", PackName: "Slack"},
+		},
+	},
+}
+
+func TestSimulateFlow_ShouldReturnOutcomeOfEachStep(t *testing.T) {
+
+	//Given
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		get: func(name string) (*Flow, error) {
+			require.Equal(t, "deploy", name)
+			f := simulatedFlow
+			return &f, nil
+		},
+	}
+	// nothing is stored, calling any of the repositories would panic
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{}
+
+	body := `{"event": {"event": "MessageReceived", "pack": {"name": "Slack"}, "payload": {"channelId": "123", "message": "deploy"}},
+		"context": {"app": "flyte"}}`
+
+	//When
+	w := httptest.NewRecorder()
+	SimulateFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows/deploy/simulate?:flowName=deploy", strings.NewReader(body)))
+
+	//Then
+	resp := w.Result()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	got := simulationResponse{}
+	require.NoError(t, encodingjson.NewDecoder(resp.Body).Decode(&got))
+	require.Len(t, got.Steps, 5)
+	assert.Equal(t, "deploy", got.Flow)
+
+	announce := got.Steps[0]
+	assert.True(t, announce.Executed)
+	assert.Equal(t, map[string]string{"app": "flyte", "channel": "123"}, announce.Context)
+	assert.True(t, *announce.CriteriaMet)
+	assert.Equal(t, "SendMessage", announce.Command.Name)
+	assert.Equal(t, map[string]interface{}{"channelId": "123", "message": "deploying flyte"}, announce.Command.Input)
+
+	assert.False(t, got.Steps[1].Executed)
+	assert.Equal(t, reasonCriteriaNotMet, got.Steps[1].Reason)
+	assert.False(t, *got.Steps[1].CriteriaMet)
+
+	assert.Equal(t, reasonEventNotMatched, got.Steps[2].Reason)
+	assert.Equal(t, reasonPackLabelsNotMatched, got.Steps[3].Reason)
+
+	assert.False(t, got.Steps[4].Executed)
+	assert.Contains(t, got.Steps[4].Error, "error resolving criteria")
+}
+
+func TestSimulateFlow_ShouldUseStepStatesForDependsOn(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		get: func(name string) (*Flow, error) {
+			f := simulatedFlow
+			return &f, nil
+		},
+	}
+
+	simulate := func(body string) simulationResponse {
+		w := httptest.NewRecorder()
+		SimulateFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows/deploy/simulate?:flowName=deploy", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		got := simulationResponse{}
+		require.NoError(t, encodingjson.NewDecoder(w.Result().Body).Decode(&got))
+		return got
+	}
+	event := `"event": {"event": "MessageSent", "pack": {"name": "Slack"}}`
+
+	assert.Equal(t, reasonDependsOnNotMet, simulate(`{` + event + `}`).Steps[2].Reason)
+	assert.Equal(t, reasonDependsOnNotMet, simulate(`{` + event + `, "stepStates": {"announce": "PENDING"}}`).Steps[2].Reason)
+	assert.True(t, simulate(`{` + event + `, "stepStates": {"announce": "SUCCESS"}}`).Steps[2].Executed)
+	assert.Equal(t, reasonAlreadyExecuted, simulate(`{` + event + `, "stepStates": {"announce": "SUCCESS", "deploy": "FATAL"}}`).Steps[2].Reason)
+}
+
+func TestSimulateFlow_ShouldUseStepResultsForRepeatAndDependsOn(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		get: func(name string) (*Flow, error) {
+			return &Flow{Name: "poll", Steps: []Step{
+				{
+					Id:      "check",
+					Event:   EventDef{Name: "Tick", PackName: "Timer"},
+					Command: Command{Name: "CheckStatus", PackName: "Jenkins"},
+					Repeat:  &Repeat{Until: "{{ Event.Payload.status == 'done' }}"},
+				},
+				{
+					Id:        "notify",
+					DependsOn: []string{"check"},
+					Event:     EventDef{Name: "Tick", PackName: "Timer"},
+					Command:   Command{Name: "SendMessage", PackName: "Slack"},
+				},
+			}}, nil
+		},
+	}
+
+	simulate := func(body string) simulationResponse {
+		w := httptest.NewRecorder()
+		SimulateFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows/poll/simulate?:flowName=poll", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		got := simulationResponse{}
+		require.NoError(t, encodingjson.NewDecoder(w.Result().Body).Decode(&got))
+		return got
+	}
+	event := `"event": {"event": "Tick", "pack": {"name": "Timer"}}`
+
+	running := simulate(`{` + event + `, "stepResults": {"check": {"event": "StatusChecked", "payload": {"status": "running"}}}}`)
+	assert.True(t, running.Steps[0].Executed)
+	assert.True(t, running.Steps[1].Executed)
+
+	done := simulate(`{` + event + `, "stepResults": {"check": {"event": "StatusChecked", "payload": {"status": "done"}}}}`)
+	assert.Equal(t, reasonAlreadyExecuted, done.Steps[0].Reason)
+
+	fatal := simulate(`{` + event + `, "stepResults": {"check": {"event": "FATAL", "payload": {"status": "done"}}}}`)
+	assert.True(t, fatal.Steps[1].Executed)
+
+	pending := simulate(`{` + event + `, "stepStates": {"check": "PENDING"}, "stepResults": {"check": {"event": "StatusChecked"}}}`)
+	assert.Equal(t, reasonAlreadyExecuted, pending.Steps[0].Reason)
+	assert.Equal(t, reasonDependsOnNotMet, pending.Steps[1].Reason)
+}
+
+func TestSimulateFlow_ShouldReturnIterationsOfForEachStep(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		get: func(name string) (*Flow, error) {
+			return &Flow{Name: "restart", Steps: []Step{
+				{
+					Id:      "restart",
+					Event:   EventDef{Name: "HostsFound", PackName: "Inventory"},
+					ForEach: "{{ Event.Payload.hosts }}",
+					Command: Command{
+						Name:     "Restart",
+						PackName: "Shell",
+						Input:    map[string]interface{}{"host": "{{ item }}", "index": "{{ index }}"},
+					},
+				},
+			}}, nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	body := `{"event": {"event": "HostsFound", "pack": {"name": "Inventory"}, "payload": {"hosts": ["a", "b"]}}}`
+	SimulateFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows/restart/simulate?:flowName=restart", strings.NewReader(body)))
+
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	got := simulationResponse{}
+	require.NoError(t, encodingjson.NewDecoder(w.Result().Body).Decode(&got))
+
+	restart := got.Steps[0]
+	assert.True(t, restart.Executed)
+	assert.Equal(t, "Restart", restart.Command.Name)
+	require.Len(t, restart.Iterations, 2)
+	assert.Equal(t, 0, restart.Iterations[0].Index)
+	assert.Equal(t, "a", restart.Iterations[0].Item)
+	assert.Equal(t, map[string]interface{}{"host": "a", "index": "0"}, restart.Iterations[0].Command.Input)
+	assert.Equal(t, 1, restart.Iterations[1].Index)
+	assert.Equal(t, "b", restart.Iterations[1].Item)
+	assert.Equal(t, map[string]interface{}{"host": "b", "index": "1"}, restart.Iterations[1].Command.Input)
+	assert.Equal(t, "Shell", restart.Iterations[1].Command.PackName)
+}
+
+func TestSimulateFlow_ShouldReturn400ForInvalidRequest(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		get: func(name string) (*Flow, error) {
+			t.Fatal("Should not get here")
+			return nil, nil
+		},
+	}
+
+	for _, body := range []string{"{", `{"event": {"event": "MessageSent"}}`} {
+		w := httptest.NewRecorder()
+		SimulateFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows/deploy/simulate?:flowName=deploy", strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, body)
+	}
+}
+
+func TestSimulateFlow_ShouldMapFlowRepoErrorsToStatusCodes(t *testing.T) {
+
+	defer resetFlowRepo()
+	cases := map[error]int{
+		FlowNotFoundErr:      http.StatusNotFound,
+		errors.New("db err"): http.StatusInternalServerError,
+	}
+	for err, status := range cases {
+		flowRepo = mockFlowRepo{
+			get: func(name string) (*Flow, error) {
+				return nil, err
+			},
+		}
+
+		w := httptest.NewRecorder()
+		SimulateFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows/deploy/simulate?:flowName=deploy",
+			strings.NewReader(`{"event": {"event": "MessageSent", "pack": {"name": "Slack"}}}`)))
+
+		assert.Equal(t, status, w.Result().StatusCode, err.Error())
+	}
+}
@@ -23,16 +23,24 @@ import (
 	"github.com/ExpediaGroup/flyte/template"
 	"gopkg.in/mgo.v2/bson"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Step struct {
 	Id        string            `bson:"id,omitempty"`
 	DependsOn []string          `bson:"dependsOn,omitempty"`
+	Join      string            `bson:"join,omitempty"`
 	Event     EventDef          `bson:"event"`
 	Context   map[string]string `bson:"context,omitempty"`
 	Criteria  string            `bson:"criteria,omitempty"`
 	Command   Command           `bson:"command"`
+
+	// template resolving to a list, the command is executed once for each item
+	ForEach     string `bson:"forEach,omitempty"`
+	ForEachJoin string `bson:"forEachJoin,omitempty"`
+
+	Repeat *Repeat `bson:"repeat,omitempty"`
 }
 
 type EventDef struct {
@@ -46,6 +54,11 @@ type Command struct {
 	PackName   string            `bson:"packName"`
 	PackLabels map[string]string `bson:"packLabels,omitempty"`
 	Input      json.Json         `bson:"input,omitempty"`
+	Timeout    Timeout           `bson:"timeout,omitempty"`
+	Retry      Retry             `bson:"retry,omitempty"`
+	Priority   interface{}       `bson:"priority,omitempty"`
+	// how many actions of the step can be in flight across the executions of the flow, 0 is no limit
+	MaxInFlight int `bson:"maxInFlight,omitempty"`
 }
 
 func (s Step) Execute(e Event, parentCtx map[string]string) (*Action, error) {
@@ -55,25 +68,60 @@ func (s Step) Execute(e Event, parentCtx map[string]string) (*Action, error) {
 var stepExecutor = executeStep
 
 func executeStep(s Step, e Event, parentCtx map[string]string) (*Action, error) {
-	ctx, err := s.resolveContext(e, parentCtx)
+	a, _, err := s.execute(e, parentCtx)
+	return a, err
+}
+
+// why a step is not executed with an event
+const (
+	reasonEventNotMatched      = "event does not match"
+	reasonAlreadyExecuted      = "step has already been executed"
+	reasonDependsOnNotMet      = "dependsOn is not satisfied"
+	reasonPackLabelsNotMatched = "event pack labels do not match"
+	reasonCriteriaNotMet       = "criteria is not met"
+)
+
+// what has been resolved while executing a step, and why no action has been created when the step is not executed
+type stepTrace struct {
+	context     map[string]string
+	criteriaMet *bool
+	reason      string
+}
+
+func (s Step) execute(e Event, parentCtx map[string]string) (*Action, stepTrace, error) {
 
+	trace := stepTrace{}
+	ctx, err := s.resolveContext(e, parentCtx)
 	if err != nil {
-		return nil, err
+		return nil, trace, err
 	}
+	trace.context = ctx
 
 	if match, err := s.matchesEvent(e, ctx); err != nil || !match {
-		return nil, err
+		trace.reason = reasonPackLabelsNotMatched
+		return nil, trace, err
 	}
 
-	if criteriaMet, err := s.isCriteriaMet(e, ctx); err != nil || !criteriaMet {
-		return nil, err
+	criteriaMet, err := s.isCriteriaMet(e, ctx)
+	if err != nil {
+		return nil, trace, err
+	}
+	trace.criteriaMet = &criteriaMet
+	if !criteriaMet {
+		trace.reason = reasonCriteriaNotMet
+		return nil, trace, nil
+	}
+
+	if s.ForEach != "" {
+		a, err := s.executeForEach(e, ctx)
+		return a, trace, err
 	}
 
 	a, err := s.Command.createAction(e, ctx)
 	if a != nil {
 		a.StepId = s.Id
 	}
-	return a, err
+	return a, trace, err
 }
 
 func (s Step) resolveContext(e Event, parentCtx map[string]string) (map[string]string, error) {
@@ -91,7 +139,7 @@ func (s Step) matchesEvent(e Event, ctx map[string]string) (bool, error) {
 		return false, nil
 	}
 
-	packLabels, err := resolveLabels(s.Event.PackLabels, e, ctx)
+	packLabels, err := resolveLabels(s.Event.PackLabels, templateContext(e, ctx))
 	if err != nil {
 		return false, err
 	}
@@ -112,45 +160,89 @@ func (s Step) isCriteriaMet(e Event, ctx map[string]string) (bool, error) {
 }
 
 func (c Command) createAction(e Event, ctx map[string]string) (*Action, error) {
+	return c.createActionIn(templateContext(e, ctx), e, ctx)
+}
+
+// creates the action with the command templates resolved in the template context
+func (c Command) createActionIn(tctx template.Context, e Event, ctx map[string]string) (*Action, error) {
+
+	packLabels, err := resolveLabels(c.PackLabels, tctx)
+	if err != nil {
+		return nil, err
+	}
 
-	packLabels, err := resolveLabels(c.PackLabels, e, ctx)
+	input, err := c.resolveInput(tctx)
 	if err != nil {
 		return nil, err
 	}
 
-	input, err := c.resolveInput(e, ctx)
+	priority, err := c.resolvePriority(tctx)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := c.Timeout.validate(); err != nil {
+		return nil, err
+	}
+	if err := c.Retry.validate(); err != nil {
+		return nil, err
+	}
+
 	state := State{Value: stateNew, Time: time.Now().UTC()}
 
 	return &Action{
-		Id:         bson.NewObjectId().Hex(),
-		Name:       c.Name,
-		PackName:   c.PackName,
-		PackLabels: packLabels,
-		Input:      input,
-		State:      state,
-		States:     []State{state},
-		Trigger:    e,
-		Context:    ctx,
+		Id:          bson.NewObjectId().Hex(),
+		Name:        c.Name,
+		PackName:    c.PackName,
+		PackLabels:  packLabels,
+		Input:       input,
+		State:       state,
+		States:      []State{state},
+		Timeout:     c.Timeout,
+		ExpiresAt:   c.Timeout.takeDeadline(state.Time),
+		Retry:       c.Retry,
+		Attempt:     1,
+		Priority:    priority,
+		MaxInFlight: c.MaxInFlight,
+		Trigger:     e,
+		Context:     ctx,
 	}, nil
 }
 
-func (c Command) resolveInput(e Event, ctx map[string]string) (json.Json, error) {
+func (c Command) resolveInput(tctx template.Context) (json.Json, error) {
 
-	input, err := template.Resolve(c.Input, templateContext(e, ctx))
+	input, err := template.Resolve(c.Input, tctx)
 	if err != nil {
-		return nil, fmt.Errorf("error resolving command input with event=%+v and ctx=%v: %v", e, ctx, err)
+		return nil, fmt.Errorf("error resolving command input with event=%+v and ctx=%v: %v", tctx["Event"], tctx["Context"], err)
 	}
 	return input, nil
 }
 
-func resolveLabels(labelsTmpl map[string]string, e Event, ctx map[string]string) (map[string]string, error) {
-	labels, err := template.Resolve(labelsTmpl, templateContext(e, ctx))
+func (c Command) resolvePriority(tctx template.Context) (int, error) {
+
+	if c.Priority == nil {
+		return 0, nil
+	}
+	// priority can be a number or a template
+	resolved, err := template.Resolve(fmt.Sprint(c.Priority), tctx)
+	if err != nil {
+		return 0, fmt.Errorf("error resolving command priority with event=%+v and ctx=%v: %v", tctx["Event"], tctx["Context"], err)
+	}
+	v := strings.TrimSpace(fmt.Sprint(resolved))
+	if v == "" {
+		return 0, nil
+	}
+	priority, err := strconv.Atoi(v)
+	if err != nil || priority < 0 {
+		return 0, fmt.Errorf("invalid command priority=%q, it must be a non-negative integer", v)
+	}
+	return priority, nil
+}
+
+func resolveLabels(labelsTmpl map[string]string, tctx template.Context) (map[string]string, error) {
+	labels, err := template.Resolve(labelsTmpl, tctx)
 	if err != nil {
-		return nil, fmt.Errorf("error resolving pack labels with event=%+v and ctx=%v: %v", e, ctx, err)
+		return nil, fmt.Errorf("error resolving pack labels with event=%+v and ctx=%v: %v", tctx["Event"], tctx["Context"], err)
 	}
 	return labels.(map[string]string), nil
 }
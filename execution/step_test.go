@@ -20,6 +20,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestStepExecute_ShouldReturnAction(t *testing.T) {
@@ -77,6 +78,7 @@ func TestStepExecute_ShouldReturnAction(t *testing.T) {
 		},
 		State: state,
 		States: []State{state},
+		Attempt: 1,
 
 		Context: map[string]string{
 			"parentContextEnv": "dev",
@@ -198,6 +200,79 @@ func TestStepExecute_ShouldReturnErrorWhenThereIsAnErrorResolvingCriteria(t *tes
 	assert.Contains(t, err.Error(), "error resolving criteria")
 }
 
+func TestStepExecute_ShouldSetActionTakeDeadline_WhenCommandHasTakeTimeout(t *testing.T) {
+
+	step := Step{
+		Event:   EventDef{Name: "eventA", PackName: "packA"},
+		Command: Command{Name: "actionA", PackName: "packB", Timeout: Timeout{Take: "5m", Complete: "1h"}},
+	}
+
+	got, err := step.Execute(newEventT("eventA", "packA"), map[string]string{})
+	require.NoError(t, err)
+
+	assert.Equal(t, Timeout{Take: "5m", Complete: "1h"}, got.Timeout)
+	assert.Equal(t, got.State.Time.Add(5*time.Minute), got.ExpiresAt)
+}
+
+func TestStepExecute_ShouldReturnErrorWhenCommandTimeoutIsInvalid(t *testing.T) {
+
+	step := Step{
+		Event:   EventDef{Name: "eventA", PackName: "packA"},
+		Command: Command{Name: "actionA", PackName: "packB", Timeout: Timeout{Complete: "tomorrow"}},
+	}
+
+	_, err := step.Execute(newEventT("eventA", "packA"), map[string]string{})
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "invalid command timeout")
+}
+
+func TestStepExecute_ShouldSetActionPriority(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		priority interface{}
+		want     int
+	}{
+		{name: "no priority", priority: nil, want: 0},
+		{name: "number", priority: 10, want: 10},
+		{name: "number decoded from json", priority: float64(5), want: 5},
+		{name: "template", priority: "{{ Event.Payload.priority }}", want: 7},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step := Step{
+				Event:   EventDef{Name: "eventA", PackName: "packA"},
+				Command: Command{Name: "actionA", PackName: "packB", Priority: test.priority},
+			}
+			event := newEventT("eventA", "packA")
+			event.Payload = map[string]interface{}{"priority": 7}
+
+			got, err := step.Execute(event, map[string]string{})
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, got.Priority)
+		})
+	}
+}
+
+func TestStepExecute_ShouldReturnErrorWhenCommandPriorityIsInvalid(t *testing.T) {
+
+	for _, priority := range []interface{}{-1, "high", "{{ Event.Payload.priority }}"} {
+		step := Step{
+			Event:   EventDef{Name: "eventA", PackName: "packA"},
+			Command: Command{Name: "actionA", PackName: "packB", Priority: priority},
+		}
+		event := newEventT("eventA", "packA")
+		event.Payload = map[string]interface{}{"priority": "urgent"}
+
+		_, err := step.Execute(event, map[string]string{})
+		require.Error(t, err, priority)
+
+		assert.Contains(t, err.Error(), "invalid command priority")
+	}
+}
+
 // --- helpers ---
 
 func newEventT(name, packName string) Event {
@@ -0,0 +1,119 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	encodingjson "encoding/json"
+	"fmt"
+	"github.com/ExpediaGroup/flyte/httputil"
+	"github.com/husobee/vestigo"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net/http"
+	"time"
+)
+
+// how often a keep-alive comment is sent when there are no actions, so proxies do not close an idle stream
+var streamKeepAliveInterval = 15 * time.Second
+
+// Streams new actions to the pack as server-sent events. Each action is taken just before it is sent,
+// exactly as if the pack had taken it via the take action endpoint.
+func StreamActions(w http.ResponseWriter, r *http.Request) {
+
+	packId := vestigo.Param(r, "packId")
+	pack, err := packRepo.Get(packId)
+	if err != nil {
+		switch err {
+		case PackNotFoundErr:
+			log.Info().Msgf("Pack packId=%s not found", packId)
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			log.Err(err).Send()
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Error().Msgf("Cannot stream actions to packId=%s, response cannot be flushed", pack.Id)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(httputil.HeaderContentType, httputil.ContentTypeEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	actionName := r.FormValue("actionName")
+	log.Info().Msgf("Streaming actions to packId=%s", pack.Id)
+
+	for {
+		pack.UpdateLastSeen()
+
+		actions, err := pack.WaitAndTakeActions(r.Context(), actionName, 1, streamKeepAliveInterval)
+		if err != nil {
+			log.Err(err).Msgf("Could not take action for packId=%s and actionName=%s, closing stream", pack.Id, actionName)
+			return
+		}
+
+		if r.Context().Err() != nil {
+			requeueUndelivered(actions)
+			log.Info().Msgf("Pack packId=%s closed action stream", pack.Id)
+			return
+		}
+
+		if len(actions) == 0 {
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+			continue
+		}
+
+		for i, a := range actions {
+			if err := writeActionEvent(w, r, pack.Id, a); err != nil {
+				log.Err(err).Msgf("Could not send actionId=%s to packId=%s, closing stream", a.Id, pack.Id)
+				requeueUndelivered(actions[i:])
+				return
+			}
+			flusher.Flush()
+			log.Info().Msgf("Action actionId=%s taken", a.Id)
+		}
+	}
+}
+
+func writeActionEvent(w io.Writer, r *http.Request, packId string, action Action) error {
+	data, err := encodingjson.Marshal(toActionResponse(r, packId, action))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: action\ndata: %s\n\n", action.Id, data)
+	return err
+}
+
+// actions taken for a pack that went away are returned to the NEW state, so they are not stuck in PENDING
+func requeueUndelivered(actions []Action) {
+	for _, a := range actions {
+		if err := a.requeue(); err != nil {
+			log.Err(err).Msgf("Error re-queueing undelivered actionId=%s", a.Id)
+			continue
+		}
+		newActions.notify()
+	}
+}
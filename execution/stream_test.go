@@ -0,0 +1,132 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"context"
+	"github.com/ExpediaGroup/flyte/httputil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamActions_ShouldSendTakenActionsAsEventsUntilPackDisconnects(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id, Name: "Slack"}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	defer resetWaitAndTakeActions()
+	calls := 0
+	waitAndTakeActions = func(ctx context.Context, p Pack, actionName string, max int, wait time.Duration) ([]Action, error) {
+		calls++
+		switch calls {
+		case 1:
+			return []Action{{Id: "596759ef", PackName: p.Name, Name: actionName}}, nil
+		case 2:
+			return nil, nil
+		default:
+			disconnect()
+			return nil, nil
+		}
+	}
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/v1/packs/Slack/actions/stream?:packId=Slack&actionName=SendMessage", nil).WithContext(ctx)
+	httputil.SetProtocolAndHostIn(request)
+	StreamActions(w, request)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, httputil.ContentTypeEventStream, resp.Header.Get(httputil.HeaderContentType))
+	expectedBody := "id: 596759ef\n" +
+		"event: action\n" +
+		`data: {"command":"SendMessage","input":null,"links":[{"href":"http://example.com/v1/packs/Slack/actions/596759ef/result","rel":"http://example.com/swagger#/actionResult"}]}` + "\n\n" +
+		": keep-alive\n\n"
+	assert.Equal(t, expectedBody, w.Body.String())
+}
+
+func TestStreamActions_ShouldRequeueActionTakenAfterPackDisconnected(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return &Pack{Id: id, Name: "Slack"}, nil
+		},
+		updateLastSeen: func(id string) error {
+			return nil
+		},
+	}
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	defer resetWaitAndTakeActions()
+	pendingState := State{Value: statePending}
+	waitAndTakeActions = func(ctx context.Context, p Pack, actionName string, max int, wait time.Duration) ([]Action, error) {
+		disconnect()
+		return []Action{{Id: "596759ef", State: pendingState, States: []State{pendingState}}}, nil
+	}
+
+	defer resetActionRepo()
+	var requeued []Action
+	actionRepo = mockActionRepo{
+		update: func(a Action) error {
+			requeued = append(requeued, a)
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{
+		update: func(a Action) error {
+			return nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	StreamActions(w, httptest.NewRequest(http.MethodGet, "/v1/packs/Slack/actions/stream?:packId=Slack", nil).WithContext(ctx))
+
+	assert.Empty(t, w.Body.String())
+	if assert.Len(t, requeued, 1) {
+		assert.Equal(t, stateNew, requeued[0].State.Value)
+		assert.Equal(t, 1, requeued[0].Redeliveries)
+	}
+}
+
+func TestStreamActions_ShouldReturn404WhenPackDoesNotExist(t *testing.T) {
+
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		get: func(id string) (*Pack, error) {
+			return nil, PackNotFoundErr
+		},
+	}
+
+	w := httptest.NewRecorder()
+	StreamActions(w, httptest.NewRequest(http.MethodGet, "/v1/packs/Slack/actions/stream?:packId=Slack", nil))
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"fmt"
+	"github.com/jasonlvhit/gocron"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+type Timeout struct {
+	Take     string `bson:"take,omitempty"`
+	Complete string `bson:"complete,omitempty"`
+}
+
+func (t Timeout) validate() error {
+	for _, d := range []string{t.Take, t.Complete} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			return fmt.Errorf("invalid command timeout=%q: %v", d, err)
+		}
+	}
+	return nil
+}
+
+func (t Timeout) takeDeadline(from time.Time) time.Time {
+	return deadline(from, t.Take)
+}
+
+func (t Timeout) completeDeadline(from time.Time) time.Time {
+	return deadline(from, t.Complete)
+}
+
+// returns zero time when there is no (valid) timeout, zero time is not stored in mongo
+func deadline(from time.Time, timeout string) time.Time {
+	if timeout == "" {
+		return time.Time{}
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return time.Time{}
+	}
+	return from.Add(d)
+}
+
+// Checks every interval for actions that have not been taken or completed in time
+// and moves them to the TIMEOUT state.
+func ScheduleActionTimeoutCheck(intervalInSeconds int) (*gocron.Scheduler, chan bool) {
+	s := gocron.NewScheduler()
+	s.Every(uint64(intervalInSeconds)).Seconds().Do(expireActions)
+	sc := s.Start()
+	return s, sc
+}
+
+func expireActions() {
+
+	actions, err := actionRepo.FindExpired(currentTime())
+	if err != nil {
+		log.Err(err).Msg("Error finding expired actions")
+		return
+	}
+
+	for _, a := range actions {
+		// another flyte instance may have expired or completed the action in the meantime, in which case update fails
+		if err := a.expire(); err != nil {
+			log.Err(err).Msgf("Error expiring actionId=%s", a.Id)
+			continue
+		}
+
+		log.Info().
+			Str("ActionId", a.Id).
+			Str("CorrelationId", a.CorrelationId).
+			Str("FlowName", a.FlowName).
+			Str("StepId", a.StepId).
+			Str("State", a.prevState.Value).
+			Int("Attempt", a.Attempt).
+			Msg("Action timed out")
+
+		if a.isRetrying() {
+			continue
+		}
+		flowSvc.HandleAction(a)
+	}
+}
+
+var currentTime = currentTimeFn
+
+func currentTimeFn() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,142 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestExpireActions_ShouldMoveExpiredActionsToTimeoutStateAndHandleThem(t *testing.T) {
+
+	defer resetCurrentTime()
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+
+	defer resetActionRepo()
+	var findExpiredTime time.Time
+	var updated []Action
+	newState := State{Value: stateNew}
+	pendingState := State{Value: statePending}
+	actionRepo = mockActionRepo{
+		findExpired: func(t time.Time) ([]Action, error) {
+			findExpiredTime = t
+			return []Action{
+				{Id: "notTaken", PackName: "packA", State: newState, States: []State{newState}},
+				{Id: "notCompleted", PackName: "packB", PackLabels: map[string]string{"env": "dev"}, State: pendingState, States: []State{pendingState}},
+			}, nil
+		},
+		update: func(a Action) error {
+			updated = append(updated, a)
+			return nil
+		},
+	}
+
+	defer resetAuditRepo()
+	var audited []Action
+	auditRepo = mockAuditRepo{
+		update: func(a Action) error {
+			audited = append(audited, a)
+			return nil
+		},
+	}
+
+	defer resetFlowService()
+	var handled []Action
+	flowSvc = mockFlowService{
+		handleAction: func(a Action) {
+			handled = append(handled, a)
+		},
+	}
+
+	expireActions()
+
+	assert.Equal(t, now, findExpiredTime)
+	assert.Len(t, updated, 2)
+	assert.Equal(t, updated, audited)
+	assert.Equal(t, updated, handled)
+
+	assert.Equal(t, stateTimeout, handled[0].State.Value)
+	assert.Equal(t, []State{newState, handled[0].State}, handled[0].States)
+	assert.Equal(t, timeoutEventName, handled[0].Result.Name)
+	assert.Equal(t, Pack{Name: "packA"}, handled[0].Result.Pack)
+
+	assert.Equal(t, stateTimeout, handled[1].State.Value)
+	assert.Equal(t, statePending, handled[1].prevState.Value)
+	assert.Equal(t, Pack{Name: "packB", Labels: map[string]string{"env": "dev"}}, handled[1].Result.Pack)
+}
+
+func TestExpireActions_ShouldNotHandleActionThatCouldNotBeUpdated(t *testing.T) {
+
+	defer resetActionRepo()
+	pendingState := State{Value: statePending}
+	actionRepo = mockActionRepo{
+		findExpired: func(t time.Time) ([]Action, error) {
+			return []Action{{Id: "completedMeanwhile", State: pendingState}}, nil
+		},
+		update: func(a Action) error {
+			return errors.New("not found")
+		},
+	}
+
+	defer resetFlowService()
+	handled := false
+	flowSvc = mockFlowService{
+		handleAction: func(a Action) {
+			handled = true
+		},
+	}
+
+	expireActions()
+
+	assert.False(t, handled)
+}
+
+func TestExpireActions_ShouldDoNothingWhenFindingExpiredActionsFails(t *testing.T) {
+
+	defer resetActionRepo()
+	actionRepo = mockActionRepo{
+		findExpired: func(t time.Time) ([]Action, error) {
+			return nil, errors.New("mongo is down")
+		},
+	}
+
+	defer resetFlowService()
+	handled := false
+	flowSvc = mockFlowService{
+		handleAction: func(a Action) {
+			handled = true
+		},
+	}
+
+	expireActions()
+
+	assert.False(t, handled)
+}
+
+func TestActionExpire_ShouldReturnErrorForFinishedAction(t *testing.T) {
+
+	action := Action{State: State{Value: stateSuccess}}
+
+	err := action.expire()
+
+	assert.EqualError(t, err, "action is not in NEW or PENDING state, cannot set to TIMEOUT")
+}
+
+func resetCurrentTime() { currentTime = currentTimeFn }
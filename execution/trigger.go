@@ -0,0 +1,101 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	"errors"
+	"github.com/ExpediaGroup/flyte/auth"
+	"github.com/ExpediaGroup/flyte/flytepath"
+	"github.com/ExpediaGroup/flyte/httputil"
+	"github.com/husobee/vestigo"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/mgo.v2/bson"
+	"net/http"
+)
+
+var FlowNotTriggeredErr = errors.New("event does not start any step of the flow")
+
+type triggerResponse struct {
+	CorrelationId string `json:"correlationId"`
+}
+
+// Starts a new execution of the flow with the event, other flows are not triggered by it.
+func TriggerFlow(w http.ResponseWriter, r *http.Request) {
+
+	flowName := vestigo.Param(r, "flowName")
+
+	defer r.Body.Close()
+	event, err := toEvent(Pack{}, r.Body)
+	if err != nil {
+		log.Err(err).Msgf("Cannot read event to trigger flow=%s", flowName)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if event.Name == "" || event.Pack.Name == "" {
+		log.Info().Msgf("Event to trigger flow=%s has no event name or pack name", flowName)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	correlationId, err := triggerFlow(flowName, *event)
+	if err != nil {
+		switch err {
+		case FlowNotFoundErr:
+			log.Info().Msgf("Flow=%s not found", flowName)
+			w.WriteHeader(http.StatusNotFound)
+		case FlowNotTriggeredErr:
+			log.Info().Msgf("Event=%s from pack=%s does not start any step of flow=%s", event.Name, event.Pack.Name, flowName)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		default:
+			log.Err(err).Msgf("Error triggering flow=%s", flowName)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Info().
+		Str("FlowName", flowName).
+		Str("CorrelationId", correlationId).
+		Str("Event", event.Name).
+		Str("TriggeredBy", auth.User(r)).
+		Msg("Flow triggered")
+
+	w.Header().Set("Location", httputil.UriBuilder(r).Path(flytepath.AuditFlowPath, correlationId).Build())
+	w.WriteHeader(http.StatusCreated)
+	httputil.WriteResponse(w, r, triggerResponse{CorrelationId: correlationId})
+}
+
+var triggerFlow = triggerFlowFn
+
+func triggerFlowFn(flowName string, e Event) (string, error) {
+
+	flow, err := flowRepo.Get(flowName)
+	if err != nil {
+		return "", err
+	}
+
+	flow.correlationId = bson.NewObjectId().Hex()
+	flow.correlationKey = flow.correlationKeyOf(e)
+	flow.context = map[string]string{}
+	flow.actions = map[string]Action{}
+	flow.HandleEvent(e)
+
+	if len(flow.actions) == 0 {
+		return "", FlowNotTriggeredErr
+	}
+	return flow.correlationId, nil
+}
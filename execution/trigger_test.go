@@ -0,0 +1,156 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package execution
+
+import (
+	encodingjson "encoding/json"
+	"errors"
+	"github.com/ExpediaGroup/flyte/httputil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTriggerFlow_ShouldStartNewExecutionOfTheFlow(t *testing.T) {
+
+	//Given
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		get: func(name string) (*Flow, error) {
+			require.Equal(t, "rotate", name)
+			flow := newFlowT(newStepT("start", "RotateRequested", "Operator"), newStepT("other", "MessageSent", "Slack"))
+			flow.Name = name
+			return &flow, nil
+		},
+	}
+
+	defer resetStepExecutor()
+	rec := setupStepExecutorWithAction(nil)
+
+	defer resetActionRepo()
+	var added Action
+	actionRepo = mockActionRepo{
+		add: func(a Action) error {
+			added = a
+			return nil
+		},
+	}
+	defer resetAuditRepo()
+	auditRepo = mockAuditRepo{add: func(a Action) error { return nil }}
+
+	//When
+	correlationId, err := triggerFlowFn("rotate", Event{Name: "RotateRequested", Pack: Pack{Name: "Operator"}})
+
+	//Then
+	require.NoError(t, err)
+	assert.NotEmpty(t, correlationId)
+	assert.Equal(t, []Step{newStepT("start", "RotateRequested", "Operator")}, rec.steps())
+	assert.Equal(t, correlationId, added.CorrelationId)
+	assert.Equal(t, "rotate", added.FlowName)
+}
+
+func TestTriggerFlow_ShouldReturnFlowNotTriggeredErrWhenNoStepIsStarted(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		get: func(name string) (*Flow, error) {
+			flow := newFlowT(newStepT("start", "RotateRequested", "Operator"))
+			return &flow, nil
+		},
+	}
+
+	_, err := triggerFlowFn("rotate", Event{Name: "MessageSent", Pack: Pack{Name: "Slack"}})
+
+	assert.Equal(t, FlowNotTriggeredErr, err)
+}
+
+func TestTriggerFlowHandler_ShouldReturn201WithCorrelationId(t *testing.T) {
+
+	//Given
+	defer resetTriggerFlow()
+	var actual Event
+	triggerFlow = func(flowName string, e Event) (string, error) {
+		require.Equal(t, "rotate", flowName)
+		actual = e
+		return "abc", nil
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows/rotate/executions?:flowName=rotate",
+		strings.NewReader(`{"event": "RotateRequested", "pack": {"name": "Operator"}, "payload": {"service": "db"}}`))
+	httputil.SetProtocolAndHostIn(req)
+
+	//When
+	w := httptest.NewRecorder()
+	TriggerFlow(w, req)
+
+	//Then
+	resp := w.Result()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	location, err := resp.Location()
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/v1/audit/flows/abc", location.String())
+	got := triggerResponse{}
+	require.NoError(t, encodingjson.NewDecoder(resp.Body).Decode(&got))
+	assert.Equal(t, "abc", got.CorrelationId)
+
+	assert.Equal(t, "RotateRequested", actual.Name)
+	assert.Equal(t, "Operator", actual.Pack.Name)
+	assert.Equal(t, map[string]interface{}{"service": "db"}, actual.Payload)
+	assert.False(t, actual.ReceivedAt.IsZero())
+}
+
+func TestTriggerFlowHandler_ShouldReturn400ForInvalidEvent(t *testing.T) {
+
+	defer resetTriggerFlow()
+	triggerFlow = func(flowName string, e Event) (string, error) {
+		t.Fatal("Should not get here")
+		return "", nil
+	}
+
+	for _, body := range []string{"{", `{"event": "RotateRequested"}`, `{"pack": {"name": "Operator"}}`} {
+		w := httptest.NewRecorder()
+		TriggerFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows/rotate/executions?:flowName=rotate", strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, body)
+	}
+}
+
+func TestTriggerFlowHandler_ShouldMapErrorsToStatusCodes(t *testing.T) {
+
+	defer resetTriggerFlow()
+	cases := map[error]int{
+		FlowNotFoundErr:      http.StatusNotFound,
+		FlowNotTriggeredErr:  http.StatusUnprocessableEntity,
+		errors.New("db err"): http.StatusInternalServerError,
+	}
+	for err, status := range cases {
+		triggerFlow = func(flowName string, e Event) (string, error) {
+			return "", err
+		}
+
+		w := httptest.NewRecorder()
+		TriggerFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows/rotate/executions?:flowName=rotate",
+			strings.NewReader(`{"event": "RotateRequested", "pack": {"name": "Operator"}}`)))
+
+		assert.Equal(t, status, w.Result().StatusCode, err.Error())
+	}
+}
+
+func resetTriggerFlow() { triggerFlow = triggerFlowFn }
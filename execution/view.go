@@ -23,6 +23,7 @@ import (
 	"github.com/ExpediaGroup/flyte/json"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -40,26 +41,62 @@ func toEvent(pack Pack, reader io.Reader) (*Event, error) {
 }
 
 type actionResponse struct {
-	Command string          `json:"command"`
-	Input   json.Json       `json:"input"`
-	Links   []httputil.Link `json:"links,omitempty"`
+	Command        string          `json:"command"`
+	Input          json.Json       `json:"input"`
+	LeaseExpiresAt *time.Time      `json:"leaseExpiresAt,omitempty"`
+	LeaseToken     string          `json:"leaseToken,omitempty"`
+	Links          []httputil.Link `json:"links,omitempty"`
 }
 
 func toActionResponse(r *http.Request, packId string, action Action) actionResponse {
-	return actionResponse{
+	response := actionResponse{
 		Command: action.Name,
 		Input:   action.Input,
 		Links:   getTakeActionLinks(r, packId, action),
 	}
+	if !action.LeaseExpiresAt.IsZero() {
+		response.LeaseExpiresAt = &action.LeaseExpiresAt
+		response.LeaseToken = action.LeaseToken
+	}
+	return response
+}
+
+type actionsResponse struct {
+	Actions []actionResponse `json:"actions"`
+}
+
+func toActionsResponse(r *http.Request, packId string, actions []Action) actionsResponse {
+	response := actionsResponse{Actions: []actionResponse{}}
+	for _, a := range actions {
+		response.Actions = append(response.Actions, toActionResponse(r, packId, a))
+	}
+	return response
 }
 
 func getTakeActionLinks(r *http.Request, packId string, action Action) []httputil.Link {
+	// the lease token identifies the pack holding the lease, so it is passed on when the result or heartbeat is posted
+	query := ""
+	if action.LeaseToken != "" {
+		query = "?leaseToken=" + url.QueryEscape(action.LeaseToken)
+	}
+
 	link := httputil.Link{Href: httputil.UriBuilder(r).
 		Path(flytepath.TakeActionResultPath).
 		Replace(":packId", packId).
 		Replace(":actionId", action.Id).
-		Build(),
+		Build() + query,
 		Rel: httputil.UriBuilder(r).Path(flytepath.GetUriDocPathFor(flytepath.TakeActionResultDoc)).Build()}
 
-	return []httputil.Link{link}
+	if action.LeaseExpiresAt.IsZero() {
+		return []httputil.Link{link}
+	}
+
+	heartbeatLink := httputil.Link{Href: httputil.UriBuilder(r).
+		Path(flytepath.ActionHeartbeatPath).
+		Replace(":packId", packId).
+		Replace(":actionId", action.Id).
+		Build() + query,
+		Rel: httputil.UriBuilder(r).Path(flytepath.GetUriDocPathFor(flytepath.ActionHeartbeatDoc)).Build()}
+
+	return []httputil.Link{link, heartbeatLink}
 }
@@ -18,7 +18,10 @@ package flow
 
 import (
 	"errors"
+	"fmt"
 	"github.com/ExpediaGroup/flyte/json"
+	"github.com/adhocore/gronx"
+	"time"
 )
 
 // Flow uses two different collections, one contains latest flows (one per name)
@@ -26,19 +29,126 @@ import (
 // Because mongo does NOT allow to update `_id` field we have to use custom `uuid` field, so we can update this field
 // in flow collection to make replacing latest flow atomic. This uuid is the same in both collections for the latest flow.
 type Flow struct {
-	UUID        string `json:"-" bson:"uuid"`
-	Name        string `json:"name" bson:"name"`
-	Description string `json:"description,omitempty" bson:"description,omitempty"`
-	Steps       []Step `json:"steps,omitempty" bson:"steps,omitempty"`
+	UUID        string    `json:"-" bson:"uuid"`
+	Name        string    `json:"name" bson:"name"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	Schedule    *Schedule `json:"schedule,omitempty" bson:"schedule,omitempty"`
+	Steps       []Step    `json:"steps,omitempty" bson:"steps,omitempty"`
+	// OnError steps are executed when an action of the flow execution ends with a FATAL event, Finally steps when
+	// the flow execution has no running action left. Each of them is executed at most once in a flow execution.
+	OnError []Step `json:"onError,omitempty" bson:"onError,omitempty"`
+	Finally []Step `json:"finally,omitempty" bson:"finally,omitempty"`
+	// CorrelationKey is a template resolved with each event, e.g. "{{ Event.Payload.incidentId }}". An event whose key
+	// matches the latest execution of the flow with that key is handled by it, if any of its steps can handle the event.
+	CorrelationKey string `json:"correlationKey,omitempty" bson:"correlationKey,omitempty"`
+	// MaxConcurrentExecutions limits how many executions of the flow are running at once. New executions over the limit
+	// are queued and started, oldest first, as running ones finish.
+	MaxConcurrentExecutions int `json:"maxConcurrentExecutions,omitempty" bson:"maxConcurrentExecutions,omitempty"`
+}
+
+// Schedule starts the flow on a cron expression, evaluated in the timezone (UTC by default). On every tick flyte itself
+// sends a ScheduledEventName event from the BuiltinPackName pack to the flow, so it has to have a step listening to it.
+type Schedule struct {
+	Cron     string `json:"cron" bson:"cron"`
+	Timezone string `json:"timezone,omitempty" bson:"timezone,omitempty"`
+}
+
+const (
+	BuiltinPackName    = "Flyte"
+	ScheduledEventName = "Scheduled"
+)
+
+func (f Flow) validateSchedule() error {
+	if f.Schedule == nil {
+		return nil
+	}
+	gron := gronx.New()
+	if !gron.IsValid(f.Schedule.Cron) {
+		return fmt.Errorf("invalid schedule cron expression %q", f.Schedule.Cron)
+	}
+	if _, err := time.LoadLocation(f.Schedule.Timezone); err != nil {
+		return fmt.Errorf("invalid schedule timezone %q: %v", f.Schedule.Timezone, err)
+	}
+	for _, step := range f.Steps {
+		if step.Event.Name == ScheduledEventName && step.Event.PackName == BuiltinPackName && len(step.DependsOn) == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("scheduled flow has no step for event name=%s packName=%s", ScheduledEventName, BuiltinPackName)
+}
+
+// BuiltinCommandNames are the commands of the BuiltinPackName pack, flyte runs them itself
+var BuiltinCommandNames = map[string]bool{"Wait": true, "HttpRequest": true, "DatastorePut": true, "DatastoreDelete": true, "StartSubflow": true, "Approval": true}
+
+func (f Flow) validateBuiltinCommands() error {
+	for _, step := range f.allSteps() {
+		if step.Command.PackName != BuiltinPackName {
+			continue
+		}
+		if !BuiltinCommandNames[step.Command.Name] {
+			return fmt.Errorf("step=%s has unknown command name=%s of pack=%s", step.Id, step.Command.Name, BuiltinPackName)
+		}
+		if step.Command.Name == "Approval" && !hasApprovers(step.Command.Input) {
+			return fmt.Errorf("step=%s has no approvers in the input of command name=%s", step.Id, step.Command.Name)
+		}
+	}
+	return nil
+}
+
+// an approval without approvers could be decided by anyone, even without a token
+func hasApprovers(input json.Json) bool {
+	in, _ := input.(map[string]interface{})
+	approvers, _ := in["approvers"].(map[string]interface{})
+	for _, values := range approvers {
+		if v, ok := values.([]interface{}); ok && len(v) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// onError and finally steps share the step ids of the flow execution with its steps
+func (f Flow) validateStepIds() error {
+	ids := map[string]bool{}
+	for _, step := range f.allSteps() {
+		if step.Id == "" {
+			continue
+		}
+		if ids[step.Id] {
+			return fmt.Errorf("step id=%s is not unique", step.Id)
+		}
+		ids[step.Id] = true
+	}
+	return nil
+}
+
+func (f Flow) allSteps() []Step {
+	var steps []Step
+	steps = append(steps, f.Steps...)
+	steps = append(steps, f.OnError...)
+	return append(steps, f.Finally...)
 }
 
 type Step struct {
 	Id        string            `json:"id,omitempty" bson:"id,omitempty"`
 	DependsOn []string          `json:"dependsOn,omitempty" bson:"dependsOn,omitempty"`
+	Join      string            `json:"join,omitempty" bson:"join,omitempty"`
 	Event     Event             `json:"event" bson:"event"`
 	Context   map[string]string `json:"context,omitempty" bson:"context,omitempty"`
 	Criteria  string            `json:"criteria,omitempty" bson:"criteria,omitempty"`
 	Command   Command           `json:"command" bson:"command"`
+	// ForEach is a template resolving to a list, the command is executed for each item. The step completes
+	// with a "ForEachCompleted" event from the BuiltinPackName pack once all the iterations have finished, or any of them with ForEachJoin "any".
+	ForEach     string  `json:"forEach,omitempty" bson:"forEach,omitempty"`
+	ForEachJoin string  `json:"forEachJoin,omitempty" bson:"forEachJoin,omitempty"`
+	Repeat      *Repeat `json:"repeat,omitempty" bson:"repeat,omitempty"`
+}
+
+// Repeat lets a step be executed again in the same flow execution once its latest action has finished, at most Max times
+// (including the first one) and until the Until criteria is met by the result of its latest action.
+type Repeat struct {
+	Max   int    `json:"max,omitempty" bson:"max,omitempty"`
+	Until string `json:"until,omitempty" bson:"until,omitempty"`
 }
 
 type Event struct {
@@ -52,6 +162,29 @@ type Command struct {
 	PackName   string            `json:"packName" bson:"packName"`
 	PackLabels map[string]string `json:"packLabels,omitempty" bson:"packLabels,omitempty"`
 	Input      json.Json         `json:"input" bson:"input"`
+	Timeout    *Timeout          `json:"timeout,omitempty" bson:"timeout,omitempty"`
+	Retry      *Retry            `json:"retry,omitempty" bson:"retry,omitempty"`
+	// Priority is a non-negative integer or a template resolving to one, higher priority actions are taken first.
+	Priority interface{} `json:"priority,omitempty" bson:"priority,omitempty"`
+	// MaxInFlight limits how many actions of the step are new or taken at once across the executions of the flow.
+	// Actions over the limit are queued and released, highest priority and oldest first, as others finish.
+	MaxInFlight int `json:"maxInFlight,omitempty" bson:"maxInFlight,omitempty"`
+}
+
+// Timeouts are durations in go format e.g. "90s" or "1h30m".
+// Take is counted from when the action is created, complete from when it is taken by a pack.
+type Timeout struct {
+	Take     string `json:"take,omitempty" bson:"take,omitempty"`
+	Complete string `json:"complete,omitempty" bson:"complete,omitempty"`
+}
+
+// Retry re-creates an action that finished with one of the On events (FATAL by default).
+// MaxAttempts includes the first attempt; backoff is either "fixed" (default) or "exponential" starting from Delay.
+type Retry struct {
+	MaxAttempts int      `json:"maxAttempts" bson:"maxAttempts"`
+	Backoff     string   `json:"backoff,omitempty" bson:"backoff,omitempty"`
+	Delay       string   `json:"delay,omitempty" bson:"delay,omitempty"`
+	On          []string `json:"on,omitempty" bson:"on,omitempty"`
 }
 
 type Repository interface {
@@ -56,6 +56,24 @@ func PostFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := flow.validateSchedule(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Err(err).Msgf("Invalid schedule of flowName=%s", flow.Name)
+		return
+	}
+
+	if err := flow.validateBuiltinCommands(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Err(err).Msgf("Invalid command of flowName=%s", flow.Name)
+		return
+	}
+
+	if err := flow.validateStepIds(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Err(err).Msgf("Invalid step of flowName=%s", flow.Name)
+		return
+	}
+
 	if err := flowRepo.Add(flow); err != nil {
 		log.Err(err).Msgf("Cannot add flow to repo flowName=%s", flow.Name)
 		w.WriteHeader(http.StatusInternalServerError)
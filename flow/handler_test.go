@@ -87,6 +87,418 @@ func TestPostFlow_ShouldReturn500ForInvalidRequest(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 }
 
+func TestPostFlow_ShouldAcceptStepJoin(t *testing.T) {
+
+	defer resetFlowRepo()
+	var actualFlow Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			actualFlow = flow
+			return nil
+		},
+	}
+
+	for _, join := range []string{"all", "any", "hipchat_start.SUCCESS && !jira_start.FATAL"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(joinFlow, `"dependsOn": ["hipchat_start"], "join": "`+join+`",`)))
+		httputil.SetProtocolAndHostIn(req)
+		w := httptest.NewRecorder()
+		PostFlow(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode, join)
+		assert.Equal(t, join, actualFlow.Steps[0].Join)
+	}
+}
+
+func TestPostFlow_ShouldReturn500ForInvalidStepJoin(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(joinFlow, `"dependsOn": ["hipchat_start"], "join": "{{ Event.Name }}",`)))
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestPostFlow_ShouldReturn500ForStepJoinWithoutDependsOn(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(joinFlow, `"join": "all",`)))
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestPostFlow_ShouldAcceptStepForEach(t *testing.T) {
+
+	defer resetFlowRepo()
+	var actualFlow Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			actualFlow = flow
+			return nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(joinFlow, `"forEach": "{{ Event.Payload.hosts }}", "forEachJoin": "any",`)))
+	httputil.SetProtocolAndHostIn(req)
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	assert.Equal(t, "{{ Event.Payload.hosts }}", actualFlow.Steps[0].ForEach)
+	assert.Equal(t, "any", actualFlow.Steps[0].ForEachJoin)
+}
+
+func TestPostFlow_ShouldReturn500ForInvalidStepForEachJoin(t *testing.T) {
+
+	for _, forEach := range []string{`"forEach": "{{ Event.Payload.hosts }}", "forEachJoin": "first",`, `"forEachJoin": "all",`} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(joinFlow, forEach)))
+		w := httptest.NewRecorder()
+		PostFlow(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode, forEach)
+	}
+}
+
+func TestPostFlow_ShouldAcceptStepRepeat(t *testing.T) {
+
+	defer resetFlowRepo()
+	var actualFlow Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			actualFlow = flow
+			return nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(joinFlow, `"repeat": {"max": 5, "until": "{{ Event.Payload.done }}"},`)))
+	httputil.SetProtocolAndHostIn(req)
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	assert.Equal(t, &Repeat{Max: 5, Until: "{{ Event.Payload.done }}"}, actualFlow.Steps[0].Repeat)
+}
+
+func TestPostFlow_ShouldReturn500ForInvalidStepRepeat(t *testing.T) {
+
+	for _, repeat := range []string{`"repeat": {},`, `"repeat": {"max": 0},`, `"repeat": {"until": ""},`} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(joinFlow, repeat)))
+		w := httptest.NewRecorder()
+		PostFlow(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode, repeat)
+	}
+}
+
+func TestPostFlow_ShouldAcceptOnErrorAndFinallySteps(t *testing.T) {
+
+	defer resetFlowRepo()
+	var actualFlow Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			actualFlow = flow
+			return nil
+		},
+	}
+
+	onError := `{"id": "notify", "criteria": "{{ Failure.StepId == 'deploy' }}", "command": {"packName": "Slack", "name": "SendMessage", "input": "{{ Failure.Result.Payload }}"}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(handlerFlow, onError)))
+	httputil.SetProtocolAndHostIn(req)
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Len(t, actualFlow.OnError, 1)
+	assert.Equal(t, "notify", actualFlow.OnError[0].Id)
+	assert.Equal(t, "{{ Failure.StepId == 'deploy' }}", actualFlow.OnError[0].Criteria)
+	assert.Equal(t, Command{PackName: "Slack", Name: "SendMessage", Input: "{{ Failure.Result.Payload }}"}, actualFlow.OnError[0].Command)
+	require.Len(t, actualFlow.Finally, 1)
+	assert.Equal(t, "clean_up", actualFlow.Finally[0].Id)
+}
+
+func TestPostFlow_ShouldReturn500ForInvalidOnErrorStep(t *testing.T) {
+
+	cases := map[string]string{
+		"missing id":      `{"command": {"packName": "Slack", "name": "SendMessage"}}`,
+		"missing command": `{"id": "notify"}`,
+		"with event":      `{"id": "notify", "event": {"packName": "Argo", "name": "ArtifactUpdated"}, "command": {"packName": "Slack", "name": "SendMessage"}}`,
+	}
+	for name, onError := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(handlerFlow, onError)))
+		w := httptest.NewRecorder()
+		PostFlow(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode, name)
+	}
+}
+
+func TestPostFlow_ShouldReturn400ForDuplicateStepIds(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			t.Fatal("Should not get here")
+			return nil
+		},
+	}
+
+	for _, id := range []string{"deploy", "clean_up"} {
+		onError := fmt.Sprintf(`{"id": "%s", "command": {"packName": "Slack", "name": "SendMessage"}}`, id)
+		w := httptest.NewRecorder()
+		PostFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(handlerFlow, onError))))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, id)
+	}
+}
+
+func TestPostFlow_ShouldAcceptCorrelationKey(t *testing.T) {
+
+	defer resetFlowRepo()
+	var actualFlow Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			actualFlow = flow
+			return nil
+		},
+	}
+
+	flow := strings.Replace(fmt.Sprintf(joinFlow, ""), `"name": "join_flow",`, `"name": "join_flow", "correlationKey": "{{ Event.Payload.incidentId }}",`, 1)
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow))
+	httputil.SetProtocolAndHostIn(req)
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	assert.Equal(t, "{{ Event.Payload.incidentId }}", actualFlow.CorrelationKey)
+}
+
+func TestPostFlow_ShouldReturn500ForEmptyCorrelationKey(t *testing.T) {
+
+	flow := strings.Replace(fmt.Sprintf(joinFlow, ""), `"name": "join_flow",`, `"name": "join_flow", "correlationKey": "",`, 1)
+	w := httptest.NewRecorder()
+	PostFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow)))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestPostFlow_ShouldAcceptConcurrencyLimits(t *testing.T) {
+
+	defer resetFlowRepo()
+	var actualFlow Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			actualFlow = flow
+			return nil
+		},
+	}
+
+	flow := strings.Replace(redeployFlow, `"name": "PutArtifact",`, `"name": "PutArtifact", "maxInFlight": 10,`, 1)
+	flow = strings.Replace(flow, `"steps": [`, `"maxConcurrentExecutions": 5, "steps": [`, 1)
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow))
+	httputil.SetProtocolAndHostIn(req)
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	assert.Equal(t, 5, actualFlow.MaxConcurrentExecutions)
+	assert.Equal(t, 10, actualFlow.Steps[0].Command.MaxInFlight)
+}
+
+func TestPostFlow_ShouldReturn500ForConcurrencyLimitsBelowOne(t *testing.T) {
+
+	flows := []string{
+		strings.Replace(redeployFlow, `"name": "PutArtifact",`, `"name": "PutArtifact", "maxInFlight": 0,`, 1),
+		strings.Replace(redeployFlow, `"steps": [`, `"maxConcurrentExecutions": 0, "steps": [`, 1),
+	}
+	for _, flow := range flows {
+		w := httptest.NewRecorder()
+		PostFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow)))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode, flow)
+	}
+}
+
+func TestPostFlow_ShouldReturn500ForInvalidCommandTimeout(t *testing.T) {
+
+	flow := strings.Replace(redeployFlow, `"name": "PutArtifact",`, `"name": "PutArtifact", "timeout": {"take": "5m", "complete": "an hour"},`, 1)
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow))
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestPostFlow_ShouldAcceptCommandTimeout(t *testing.T) {
+
+	defer resetFlowRepo()
+	var actualFlow Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			actualFlow = flow
+			return nil
+		},
+	}
+
+	flow := strings.Replace(redeployFlow, `"name": "PutArtifact",`, `"name": "PutArtifact", "timeout": {"take": "5m", "complete": "1h30m"},`, 1)
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow))
+	httputil.SetProtocolAndHostIn(req)
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	assert.Equal(t, &Timeout{Take: "5m", Complete: "1h30m"}, actualFlow.Steps[0].Command.Timeout)
+}
+
+func TestPostFlow_ShouldAcceptCommandPriority(t *testing.T) {
+
+	defer resetFlowRepo()
+	var actualFlow Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			actualFlow = flow
+			return nil
+		},
+	}
+
+	for _, priority := range []string{`10`, `"{{ Event.Payload.priority }}"`} {
+		flow := strings.Replace(redeployFlow, `"name": "PutArtifact",`, `"name": "PutArtifact", "priority": `+priority+`,`, 1)
+		req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow))
+		httputil.SetProtocolAndHostIn(req)
+		w := httptest.NewRecorder()
+		PostFlow(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode, priority)
+		assert.NotNil(t, actualFlow.Steps[0].Command.Priority, priority)
+	}
+}
+
+func TestPostFlow_ShouldReturn500ForNegativeCommandPriority(t *testing.T) {
+
+	flow := strings.Replace(redeployFlow, `"name": "PutArtifact",`, `"name": "PutArtifact", "priority": -1,`, 1)
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow))
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestPostFlow_ShouldAcceptSchedule(t *testing.T) {
+
+	defer resetFlowRepo()
+	var actualFlow Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			actualFlow = flow
+			return nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/flows",
+		strings.NewReader(fmt.Sprintf(scheduledFlow, `{"cron": "0 9 * * 1-5", "timezone": "Europe/London"}`, "Flyte")))
+	httputil.SetProtocolAndHostIn(req)
+	w := httptest.NewRecorder()
+	PostFlow(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	assert.Equal(t, &Schedule{Cron: "0 9 * * 1-5", Timezone: "Europe/London"}, actualFlow.Schedule)
+}
+
+func TestPostFlow_ShouldReturn400ForInvalidSchedule(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			t.Fatal("Should not get here")
+			return nil
+		},
+	}
+
+	cases := map[string]string{
+		"invalid cron":         fmt.Sprintf(scheduledFlow, `{"cron": "every day"}`, "Flyte"),
+		"invalid timezone":     fmt.Sprintf(scheduledFlow, `{"cron": "0 9 * * *", "timezone": "Mars/Olympus"}`, "Flyte"),
+		"no step for schedule": fmt.Sprintf(scheduledFlow, `{"cron": "0 9 * * *"}`, "Slack"),
+	}
+	for name, flow := range cases {
+		w := httptest.NewRecorder()
+		PostFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, name)
+	}
+}
+
+func TestPostFlow_ShouldReturn400ForUnknownBuiltinCommand(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			t.Fatal("Should not get here")
+			return nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	PostFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(builtinCommandFlow, "Sleep"))))
+
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestPostFlow_ShouldAcceptBuiltinCommand(t *testing.T) {
+
+	defer resetFlowRepo()
+	var added Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			added = flow
+			return nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	PostFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(fmt.Sprintf(builtinCommandFlow, "Wait"))))
+
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	assert.Equal(t, "Wait", added.Steps[0].Command.Name)
+}
+
+func TestPostFlow_ShouldReturn400ForApprovalWithoutApprovers(t *testing.T) {
+
+	defer resetFlowRepo()
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			t.Fatal("Should not get here")
+			return nil
+		},
+	}
+
+	for _, input := range []string{`{}`, `{"approvers": {}}`, `{"approvers": {"groups": []}}`} {
+		flow := strings.Replace(fmt.Sprintf(builtinCommandFlow, "Approval"), `{"duration": "10m"}`, input, 1)
+
+		w := httptest.NewRecorder()
+		PostFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, input)
+	}
+}
+
+func TestPostFlow_ShouldAcceptApprovalWithApprovers(t *testing.T) {
+
+	defer resetFlowRepo()
+	var added Flow
+	flowRepo = mockFlowRepo{
+		add: func(flow Flow) error {
+			added = flow
+			return nil
+		},
+	}
+	flow := strings.Replace(fmt.Sprintf(builtinCommandFlow, "Approval"), `{"duration": "10m"}`, `{"approvers": {"groups": ["release-managers"]}}`, 1)
+
+	w := httptest.NewRecorder()
+	PostFlow(w, httptest.NewRequest(http.MethodPost, "/v1/flows", strings.NewReader(flow)))
+
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	assert.Equal(t, "Approval", added.Steps[0].Command.Name)
+}
+
 func TestPostFlow_ShouldReturn500_WhenErrorHappens(t *testing.T) {
 	defer resetFlowRepo()
 	flowRepo = mockFlowRepo{
@@ -386,6 +798,87 @@ const redeployFlow = `{
     ]
 }`
 
+const joinFlow = `{
+    "name": "join_flow",
+    "steps": [
+        {
+            "id" : "argo_to_hipchat",
+            %s
+            "event": {
+                "packName": "Argo",
+                "name": "ArtifactUpdated"
+            },
+            "command": {
+                "packName": "Hipchat",
+                "name": "SendMessage"
+            }
+        }
+    ]
+}`
+
+const handlerFlow = `{
+    "name": "handler_flow",
+    "steps": [
+        {
+            "id" : "deploy",
+            "event": {
+                "packName": "Argo",
+                "name": "ArtifactUpdated"
+            },
+            "command": {
+                "packName": "Shell",
+                "name": "Deploy"
+            }
+        }
+    ],
+    "onError": [%s],
+    "finally": [
+        {
+            "id" : "clean_up",
+            "command": {
+                "packName": "Shell",
+                "name": "CleanUp"
+            }
+        }
+    ]
+}`
+
+const scheduledFlow = `{
+    "name": "scheduled_flow",
+    "schedule": %s,
+    "steps": [
+        {
+            "id" : "daily_report",
+            "event": {
+                "packName": "%s",
+                "name": "Scheduled"
+            },
+            "command": {
+                "packName": "Slack",
+                "name": "SendMessage"
+            }
+        }
+    ]
+}`
+
+const builtinCommandFlow = `{
+    "name": "delayed_flow",
+    "steps": [
+        {
+            "id" : "wait",
+            "event": {
+                "packName": "Slack",
+                "name": "MessageReceived"
+            },
+            "command": {
+                "packName": "Flyte",
+                "name": "%s",
+                "input": {"duration": "10m"}
+            }
+        }
+    ]
+}`
+
 const validJsonWithMissingField = `{
   "description": "Get some help on what you can do with argo and flyte",
   "steps": [
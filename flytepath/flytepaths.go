@@ -20,10 +20,15 @@ const (
 	VersionPath = "/v1"
 
 	// audit
-	AuditFlowPath = VersionPath + "/audit/flows"
-	AuditGetFlow  = VersionPath + "/audit/flows/:correlationId"
-	AuditDoc      = "auditDoc"
-	AuditFlowsDoc = "auditFlowsDoc"
+	AuditFlowPath       = VersionPath + "/audit/flows"
+	AuditGetFlow        = VersionPath + "/audit/flows/:correlationId"
+	AuditCancelFlowPath = VersionPath + "/audit/flows/:correlationId/cancel"
+	AuditRerunStepPath  = VersionPath + "/audit/flows/:correlationId/steps/:stepId/rerun"
+	AuditDoc            = "auditDoc"
+	AuditFlowsDoc       = "auditFlowsDoc"
+
+	// approvals
+	ApprovalPath = VersionPath + "/approvals/:actionId"
 
 	// datastore
 	DatastorePath     = VersionPath + "/datastore"
@@ -34,6 +39,8 @@ const (
 	// flow
 	FlowsPath           = VersionPath + "/flows"
 	FlowPath            = VersionPath + "/flows/:flowName"
+	FlowSimulatePath    = VersionPath + "/flows/:flowName/simulate"
+	FlowExecutionsPath  = VersionPath + "/flows/:flowName/executions"
 	FlowExecutionDoc    = "flowExecution"
 	TakeActionResultDoc = "takeActionResult"
 
@@ -61,13 +68,18 @@ const (
 	TakeActionPath            = PackPath + "/actions/take"
 	TakeActionWithCommandPath = PackPath + "/actions/take?commandName=:commandName"
 	TakeActionResultPath      = PackPath + "/actions/:actionId/result"
+	ActionHeartbeatPath       = PackPath + "/actions/:actionId/heartbeat"
+	StreamActionsPath         = PackPath + "/actions/stream"
 
-	GetPacksDoc   = "GetPacksDoc"
-	PostEventDoc  = "PostEventDoc"
-	TakeActionDoc = "TakeActionDoc"
+	GetPacksDoc        = "GetPacksDoc"
+	PostEventDoc       = "PostEventDoc"
+	TakeActionDoc      = "TakeActionDoc"
+	ActionHeartbeatDoc = "ActionHeartbeatDoc"
+	StreamActionsDoc   = "StreamActionsDoc"
 )
 
 var flyteDocPaths = map[string]string{
+	ActionHeartbeatDoc:    "/swagger#!/action/actionHeartbeat",
 	AuditDoc:              "/swagger#/flowExecs",
 	AuditFlowsDoc:         "/swagger#!/flowAudit/findFlows",
 	DatastoreDoc:          "/swagger#/datastore",
@@ -83,6 +95,7 @@ var flyteDocPaths = map[string]string{
 	ListPacksDoc:          "/swagger#!/pack/listPacks",
 	PostEventDoc:          "/swagger#/event",
 	VersionDocPath:        VersionPath + "/swagger",
+	StreamActionsDoc:      "/swagger#!/action/streamActions",
 	SwaggerRootDoc:        "/swagger",
 	TakeActionDoc:         "/swagger#!/action/takeAction",
 	TakeActionResultDoc:   "/swagger#/actionResult",
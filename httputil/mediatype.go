@@ -20,9 +20,11 @@ const (
 	HeaderAccept      = "Accept"
 	HeaderContentType = "Content-Type"
 
-	MediaTypeJson = "application/json"
-	MediaTypeYaml = "application/x-yaml"
+	MediaTypeJson        = "application/json"
+	MediaTypeYaml        = "application/x-yaml"
+	MediaTypeEventStream = "text/event-stream"
 
-	ContentTypeYaml = MediaTypeYaml + "; charset=utf-8"
-	ContentTypeJson = MediaTypeJson + "; charset=utf-8"
+	ContentTypeYaml        = MediaTypeYaml + "; charset=utf-8"
+	ContentTypeJson        = MediaTypeJson + "; charset=utf-8"
+	ContentTypeEventStream = MediaTypeEventStream + "; charset=utf-8"
 )
@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"github.com/ExpediaGroup/flyte/execution"
 	"github.com/ExpediaGroup/flyte/pack"
 	"github.com/ExpediaGroup/flyte/server"
 	"github.com/rs/zerolog"
@@ -25,6 +26,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 func main() {
@@ -49,6 +51,35 @@ func main() {
 
 	flyteServer := server.NewFlyteServer(c.Port, c.MongoHost, c.FlyteTTL)
 
+	execution.MigrateActionPackLabels()
+
+	log.Info().Msgf("action timeouts are checked every '%v' seconds.", c.ActionTimeoutCheckIntervalSeconds)
+	execution.ScheduleActionTimeoutCheck(c.ActionTimeoutCheckIntervalSeconds)
+
+	log.Info().Msgf("flow schedules are checked every '%v' seconds.", c.ScheduleCheckIntervalSeconds)
+	execution.ScheduleFlowTriggers(c.ScheduleCheckIntervalSeconds)
+
+	log.Info().Msgf("built-in commands are run every '%v' seconds.", c.BuiltinCheckIntervalSeconds)
+	execution.ScheduleBuiltinCommands(c.BuiltinCheckIntervalSeconds)
+
+	log.Info().Msgf("queued actions are checked every '%v' seconds.", c.QueueCheckIntervalSeconds)
+	execution.ScheduleQueuedActionRelease(c.QueueCheckIntervalSeconds)
+
+	if c.requireActionLeases() {
+		log.Info().Msgf("taken actions are leased for '%v' seconds, expired leases are checked every '%v' seconds.", c.ActionLeaseInSeconds, c.ActionLeaseCheckIntervalSeconds)
+		execution.EnableActionLeases(time.Duration(c.ActionLeaseInSeconds)*time.Second, c.ActionLeaseCheckIntervalSeconds)
+	}
+
+	if len(c.HttpRequestAllowedHosts) > 0 {
+		log.Info().Msgf("HttpRequest actions can only call hosts %v.", c.HttpRequestAllowedHosts)
+		execution.AllowHttpRequestHosts(c.HttpRequestAllowedHosts)
+	}
+
+	if c.ActionFlowFairness {
+		log.Info().Msg("actions of the same priority are taken round-robin across flows.")
+		execution.EnableFlowFairness()
+	}
+
 	if c.requireAuth() {
 		flyteServer.EnableAuth(c.AuthPolicyPath, c.OidcIssuerURL, c.OidcIssuerClientID)
 	}
@@ -30,6 +30,10 @@ const (
 	ActionCollectionId    = "action"
 	AuditCollectionId     = "audit"
 	DatastoreCollectionId = "datastore"
+	LeaderCollectionId    = "leader"
+	ScheduleCollectionId  = "schedule"
+	ExecutionCollectionId = "execution"
+	LimitsCollectionId    = "limits"
 )
 
 var (
@@ -64,9 +68,17 @@ func InitSession(url string, ttl int) {
 
 	EnsureIndexExists(ActionCollectionId, "actionCorrelationId", []string{"correlationId"})
 	EnsureIndexExists(ActionCollectionId, "actionCompound", []string{"packName", "state.value", "name", "state.time"})
+	EnsureIndexExists(ActionCollectionId, "actionPriority", []string{"packName", "state.value", "-priority", "state.time"})
+	EnsureIndexExists(ActionCollectionId, "actionNew", []string{"packName", "state.value", "packLabelKey", "-priority", "state.time"})
+	EnsureIndexExists(ActionCollectionId, "actionExpiresAt", []string{"expiresAt"})
+	EnsureIndexExists(ActionCollectionId, "actionLeaseExpiresAt", []string{"leaseExpiresAt"})
+	EnsureIndexExists(ActionCollectionId, "actionInFlight", []string{"flowName", "state.value", "stepId"})
 	EnsureTTLIndexExists(ActionCollectionId, "actionTTL", []string{"state.time"}, ttl)
 	EnsureIndexExists(AuditCollectionId, "auditCorrelationId", []string{"correlationId"})
 	EnsureTTLIndexExists(AuditCollectionId, "auditTTL", []string{"state.time"}, auditTTL)
+	EnsureIndexExists(ExecutionCollectionId, "executionStatus", []string{"status"})
+	EnsureIndexExists(ExecutionCollectionId, "executionCorrelationKey", []string{"flowName", "correlationKey"})
+	EnsureTTLIndexExists(ExecutionCollectionId, "executionTTL", []string{"lastActivityAt"}, auditTTL)
 }
 
 func dial(url string) *mgo.Session {
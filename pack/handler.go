@@ -19,6 +19,7 @@ package pack
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/ExpediaGroup/flyte/flow"
 	"github.com/ExpediaGroup/flyte/flytepath"
 	"github.com/ExpediaGroup/flyte/httputil"
 	"github.com/husobee/vestigo"
@@ -28,7 +29,7 @@ import (
 	"time"
 )
 
-var hateoasRegex, _ = regexp.Compile("up|self|/actionResult$|/takeAction$|/event$")
+var hateoasRegex, _ = regexp.Compile("up|self|/actionResult$|/takeAction$|/streamActions$|/event$")
 
 func PostPack(w http.ResponseWriter, r *http.Request) {
 
@@ -41,6 +42,12 @@ func PostPack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if pack.Name == flow.BuiltinPackName {
+		log.Info().Msgf("Cannot register pack, packName=%s is reserved for built-in commands", pack.Name)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	if err := validateLinks(pack); err != nil {
 		log.Err(err).Msg("invalid links found")
 		w.WriteHeader(http.StatusBadRequest)
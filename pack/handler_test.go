@@ -94,6 +94,10 @@ func TestPostPack_should_fail_with_bad_request(t *testing.T) {
 			name: "'Rel' attribute ends with 'takeAction'",
 			link: httputil.Link{Href: "http://somewhere.com", Rel: "somewhere.com/takeAction"},
 		},
+		{
+			name: "'Rel' attribute ends with 'streamActions'",
+			link: httputil.Link{Href: "http://somewhere.com", Rel: "somewhere.com/streamActions"},
+		},
 		{
 			name: "'Rel' attribute ends with '/event'",
 			link: httputil.Link{Href: "http://somewhere.com", Rel: "somewhere.com/something/event"},
@@ -131,6 +135,23 @@ func TestPostPack_should_fail_with_bad_request(t *testing.T) {
 	}
 }
 
+func TestPostPack_ShouldReturn400ForBuiltinPackName(t *testing.T) {
+	defer resetPackRepo()
+	packRepo = mockPackRepo{
+		add: func(pack Pack) error {
+			t.Fatal("pack should not be registered")
+			return nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/packs", strings.NewReader(`{"name": "Flyte"}`))
+	w := httptest.NewRecorder()
+	PostPack(w, req)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
 func TestPostPack_ShouldReturn400ForInvalidRequest(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/v1/packs", strings.NewReader(`--- invalid json ---`))
 	w := httptest.NewRecorder()
@@ -422,6 +443,10 @@ var slackPackResponse = strings.Replace(strings.Replace(`
             "href": "http://example.com/v1/packs/Slack/actions/take",
             "rel": "http://example.com/swagger#!/action/takeAction"
         },
+        {
+            "href": "http://example.com/v1/packs/Slack/actions/stream",
+            "rel": "http://example.com/swagger#!/action/streamActions"
+        },
         {
             "href": "http://example.com/v1/packs/Slack/events",
             "rel": "http://example.com/swagger#/event"
@@ -56,12 +56,16 @@ func Handler() http.Handler {
 	router.Post(flytepath.TakeActionPath, execution.TakeAction, YamlHandler)
 	router.Post(flytepath.PostEventPath, execution.PostEvent, YamlHandler)
 	router.Post(flytepath.TakeActionResultPath, execution.CompleteAction, YamlHandler)
+	router.Post(flytepath.ActionHeartbeatPath, execution.Heartbeat)
+	router.Get(flytepath.StreamActionsPath, execution.StreamActions)
 
 	// --- flow ---
 	router.Get(flytepath.FlowsPath, flow.GetFlows)
 	router.Post(flytepath.FlowsPath, flow.PostFlow, YamlHandler)
 	router.Get(flytepath.FlowPath, flow.GetFlow)
 	router.Delete(flytepath.FlowPath, flow.DeleteFlow)
+	router.Post(flytepath.FlowSimulatePath, execution.SimulateFlow, YamlHandler)
+	router.Post(flytepath.FlowExecutionsPath, execution.TriggerFlow, YamlHandler)
 
 	// --- datastore ---
 	router.Get(flytepath.DatastorePath, datastore.GetItems)
@@ -72,6 +76,11 @@ func Handler() http.Handler {
 	// --- audit ---
 	router.Get(flytepath.AuditFlowPath, audit.GetFlows)
 	router.Get(flytepath.AuditGetFlow, audit.GetFlow)
+	router.Post(flytepath.AuditCancelFlowPath, execution.CancelFlow)
+	router.Post(flytepath.AuditRerunStepPath, execution.RerunStep)
+
+	// --- approvals ---
+	router.Post(flytepath.ApprovalPath, execution.DecideApproval)
 
 	return wrapRequestInterceptorAround(router)
 }
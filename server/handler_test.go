@@ -57,6 +57,38 @@ func TestPostingTakeAction_shouldProcessRequestThroughHandler(t *testing.T) {
 	assert.Equal(t, numInvocations, 1)
 }
 
+func TestPostingFlowSimulation_shouldProcessRequestThroughHandler(t *testing.T) {
+	numInvocations := 0
+	cleanupFunc := mockYamlHandler(
+		func(http.ResponseWriter, *http.Request) { numInvocations++ },
+	)
+	defer cleanupFunc()
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	_, err := http.DefaultClient.Post(server.URL+flytepath.FlowSimulatePath, "any content type", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, numInvocations, 1)
+}
+
+func TestPostingFlowExecution_shouldProcessRequestThroughHandler(t *testing.T) {
+	numInvocations := 0
+	cleanupFunc := mockYamlHandler(
+		func(http.ResponseWriter, *http.Request) { numInvocations++ },
+	)
+	defer cleanupFunc()
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	_, err := http.DefaultClient.Post(server.URL+flytepath.FlowExecutionsPath, "any content type", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, numInvocations, 1)
+}
+
 func TestPostingEvent_shouldProcessRequestThroughHandler(t *testing.T) {
 	numInvocations := 0
 	cleanupFunc := mockYamlHandler(
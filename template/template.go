@@ -18,7 +18,9 @@ package template
 
 import (
 	"fmt"
+	"github.com/flosch/pongo2"
 	"reflect"
+	"regexp"
 )
 
 type Context map[string]interface{}
@@ -49,6 +51,37 @@ func Resolve(v interface{}, c Context) (out interface{}, err error) {
 	return out, nil
 }
 
+var singleExpressionRegex = regexp.MustCompile(`^\s*{{((?s:.)*)}}\s*$`)
+var tagRegex = regexp.MustCompile(`{{|}}|{%`)
+
+// Evaluates a template holding a single expression, e.g. "{{ Event.Payload.hosts }}", to the value of the expression
+// instead of its string representation, so lists and maps can be used as they are. Other templates are resolved to a string.
+func Evaluate(expression string, c Context) (out interface{}, err error) {
+
+	m := singleExpressionRegex.FindStringSubmatch(expression)
+	if m == nil || tagRegex.MatchString(m[1]) {
+		return Resolve(expression, c)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("error while evaluating expression: '%s': %v", expression, r)
+		}
+	}()
+
+	ctx := Context{}
+	for k, v := range c {
+		ctx[k] = v
+	}
+	ctx["__value"] = func(v *pongo2.Value) string {
+		out = v.Interface()
+		return ""
+	}
+	if _, err := execute("{{ __value("+m[1]+") }}", ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func resolveValue(v reflect.Value, context Context) reflect.Value {
 
 	switch v.Kind() {
@@ -233,3 +233,31 @@ func testContext() Context {
 		},
 	}
 }
+
+func TestEvaluateShouldReturnValueOfSingleExpression(t *testing.T) {
+
+	ctx := Context{"Event": map[string]interface{}{"hosts": []interface{}{"a", "b"}, "csv": "x,y"}}
+
+	hosts, err := Evaluate("{{ Event.hosts }}", ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, hosts)
+
+	split, err := Evaluate(` {{ Event.csv|split:"," }} `, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x", "y"}, split)
+}
+
+func TestEvaluateShouldResolveOtherTemplatesToString(t *testing.T) {
+
+	out, err := Evaluate(`["{{ host }}", "b"]`, Context{"host": "a"})
+
+	require.NoError(t, err)
+	assert.Equal(t, `["a", "b"]`, out)
+}
+
+func TestEvaluateShouldReturnErrorForInvalidExpression(t *testing.T) {
+
+	_, err := Evaluate("{{ Event.hosts| }}", nil)
+
+	assert.Error(t, err)
+}